@@ -0,0 +1,63 @@
+// Command migrate-content moves paste bodies still held inline in the database to the
+// content store, indexes for search the full bodies of pastes stored before full bodies
+// were indexed, and compresses stored bodies that were written before compression was
+// enabled. It reads the same configuration as the web server, works in batches and can
+// run while the server is serving requests.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/Caixetadev/snippet/config"
+	"github.com/Caixetadev/snippet/internal/infra/db/postgres"
+	repository "github.com/Caixetadev/snippet/internal/infra/db/postgres/repositories"
+	"github.com/Caixetadev/snippet/internal/services"
+)
+
+func main() {
+	batchSize := flag.Int("batch-size", 100, "rows migrated per transaction")
+	flag.Parse()
+
+	if *batchSize <= 0 {
+		log.Fatal("batch-size must be positive")
+	}
+
+	cfg, err := config.NewConfig(".")
+	if err != nil {
+		log.Fatalf("Config error: %s", err)
+	}
+
+	contents, err := services.NewContentStore(cfg)
+	if err != nil {
+		log.Fatal(fmt.Errorf("migrate-content - services.NewContentStore: %w", err))
+	}
+
+	compression, err := services.NewContentCompression(cfg)
+	if err != nil {
+		log.Fatal(fmt.Errorf("migrate-content - services.NewContentCompression: %w", err))
+	}
+
+	db, err := postgres.New(cfg.DBURL)
+	if err != nil {
+		log.Fatal(fmt.Errorf("migrate-content - postgres.New: %w", err))
+	}
+
+	defer db.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	migrator := services.NewContentMigrator(repository.NewPostRepository(db, contents, compression), *batchSize)
+
+	migration, err := migrator.Run(ctx)
+	if err != nil {
+		log.Fatalf("migrate-content: %s (moved %d, indexed %d, compressed %d)", err, migration.Moved, migration.Indexed, migration.Compressed)
+	}
+
+	log.Printf("migrate-content: done, moved %d bodies, indexed %d and compressed %d", migration.Moved, migration.Indexed, migration.Compressed)
+}
@@ -1,17 +1,21 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
 	"github.com/Caixetadev/snippet/config"
 	_ "github.com/Caixetadev/snippet/docs"
 	"github.com/Caixetadev/snippet/internal/app"
+	"github.com/Caixetadev/snippet/internal/entity"
 	"github.com/Caixetadev/snippet/internal/infra/db/postgres"
+	repository "github.com/Caixetadev/snippet/internal/infra/db/postgres/repositories"
+	"github.com/Caixetadev/snippet/internal/infra/memory"
+	"github.com/Caixetadev/snippet/internal/services"
 	"github.com/Caixetadev/snippet/internal/token"
 	"github.com/Caixetadev/snippet/pkg/middleware/http"
 	"github.com/Caixetadev/snippet/pkg/validation"
-	"github.com/gin-gonic/gin"
 	validatorv10 "github.com/go-playground/validator/v10"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -41,9 +45,45 @@ func main() {
 
 	validation := validation.NewValidator(validatorv10.New())
 
-	tokenMaker, err := token.NewPasetoMaker(cfg.TokenSymmetricKey)
+	var tokenMaker token.Maker
+
+	if len(cfg.TokenKeys) > 0 {
+		keyring, err := token.ParseKeyring(cfg.TokenActiveKeyID, cfg.TokenKeys)
+		if err != nil {
+			log.Fatal(fmt.Errorf("app - Run - token.ParseKeyring: %w", err))
+		}
+
+		tokenMaker = token.NewPasetoKeyringMaker(keyring)
+	} else {
+		tokenMaker, err = token.NewPasetoMaker(cfg.TokenSymmetricKey)
+		if err != nil {
+			log.Fatal(fmt.Errorf("app - Run - token.NewPasetoMaker: %w", err))
+		}
+	}
+
+	passwordHasher, err := services.NewPasswordHasher(cfg)
+	if err != nil {
+		log.Fatal(fmt.Errorf("app - Run - services.NewPasswordHasher: %w", err))
+	}
+
+	contents, err := services.NewContentStore(cfg)
+	if err != nil {
+		log.Fatal(fmt.Errorf("app - Run - services.NewContentStore: %w", err))
+	}
+
+	compression, err := services.NewContentCompression(cfg)
 	if err != nil {
-		log.Fatal(fmt.Errorf("app - Run - token.NewPasetoMaker: %w", err))
+		log.Fatal(fmt.Errorf("app - Run - services.NewContentCompression: %w", err))
+	}
+
+	contentKeys, err := services.NewContentKeys(cfg)
+	if err != nil {
+		log.Fatal(fmt.Errorf("app - Run - services.NewContentKeys: %w", err))
+	}
+
+	mailer, err := services.NewMailTransport(cfg)
+	if err != nil {
+		log.Fatal(fmt.Errorf("app - Run - services.NewMailTransport: %w", err))
 	}
 
 	db, err := postgres.New(cfg.DBURL)
@@ -53,13 +93,75 @@ func main() {
 
 	defer db.Close()
 
-	router := gin.Default()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sweeper := services.NewExpirationSweeper(
+		repository.NewPostRepository(db, contents, compression),
+		cfg.ExpirationSweepInterval,
+		cfg.ExpirationSweepBatchSize,
+		cfg.ExpirationSweepArchive,
+	)
+
+	go sweeper.Run(ctx)
+
+	var attemptStore services.AttemptStore
+
+	switch cfg.LockoutBackend {
+	case "memory":
+		attemptStore = memory.NewAttemptStore()
+	case "postgres":
+		attemptStore = repository.NewAttemptRepository(db)
+	default:
+		log.Fatalf("Config error: unknown lockout backend %q", cfg.LockoutBackend)
+	}
+
+	lockout := services.NewLockoutService(attemptStore, services.LockoutPolicies(cfg))
+
+	go lockout.Run(ctx, cfg.LockoutWindow)
+
+	var rateLimitStore services.RateLimitStore
+
+	switch cfg.RateLimitBackend {
+	case "memory":
+		rateLimitStore = memory.NewRateLimitStore()
+	case "postgres":
+		rateLimitStore = repository.NewRateLimitRepository(db)
+	default:
+		log.Fatalf("Config error: unknown rate limit backend %q", cfg.RateLimitBackend)
+	}
+
+	rateLimitPolicies, err := services.RateLimitPolicies(cfg)
+	if err != nil {
+		log.Fatalf("Config error: %s", err)
+	}
+
+	limiter := services.NewRateLimitService(rateLimitStore, rateLimitPolicies)
+
+	go limiter.Run(ctx, cfg.RateLimitPruneInterval)
+
+	mailService := services.NewMailService(mailer, cfg)
+
+	emailWorker := services.NewJobWorker(
+		repository.NewJobRepository(db),
+		entity.EmailQueue,
+		mailService.Deliver,
+		cfg.JobPollInterval,
+		cfg.JobBatchSize,
+	)
+
+	go emailWorker.Run(ctx)
+
+	router, err := app.NewRouter(cfg)
+	if err != nil {
+		log.Fatalf("Config error: TRUSTED_PROXIES: %s", err)
+	}
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 
 	router.Use(http.ErrorHandler())
 
-	app.Run(cfg, db, router, validation, tokenMaker)
+	app.Run(cfg, db, router, validation, tokenMaker, passwordHasher, lockout, limiter, contents, compression, contentKeys)
 
 	router.Run(":8080")
 }
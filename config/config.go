@@ -1,20 +1,119 @@
 package config
 
 import (
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	DBURL                string        `mapstructure:"PG_URL"`
-	TokenSymmetricKey    string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	DBURL             string `mapstructure:"PG_URL"`
+	TokenSymmetricKey string `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	// TokenKeys replaces TokenSymmetricKey with a keyring of <id>:<key> entries. New tokens
+	// are encrypted with TokenActiveKeyID, the other keys only verify tokens issued before
+	// a rotation. The README describes how to rotate keys.
+	TokenKeys            []string      `mapstructure:"TOKEN_KEYS"`
+	TokenActiveKeyID     string        `mapstructure:"TOKEN_ACTIVE_KEY_ID"`
 	AccessTokenDuration  time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
 	RefreshTokenDuration time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
 	AWSSecretKey         string        `mapstructure:"AWS_SECRET_KEY"`
 	AWSAccessKey         string        `mapstructure:"AWS_ACCESS_KEY"`
 	AWSRegion            string        `mapstructure:"AWS_REGION"`
 	AWSSenderEmail       string        `mapstructure:"AWS_SENDER_EMAIL"`
+
+	// MailDriver selects how emails are delivered: ses, smtp or file
+	MailDriver    string `mapstructure:"MAIL_DRIVER"`
+	MailFrom      string `mapstructure:"MAIL_FROM"`
+	MailOutboxDir string `mapstructure:"MAIL_OUTBOX_DIR"`
+	SMTPHost      string `mapstructure:"SMTP_HOST"`
+	SMTPPort      int    `mapstructure:"SMTP_PORT"`
+	SMTPUsername  string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword  string `mapstructure:"SMTP_PASSWORD"`
+	SMTPStartTLS  bool   `mapstructure:"SMTP_STARTTLS"`
+
+	// ContentStoreDriver selects where paste bodies are stored: file or s3. The s3 driver
+	// uses AWS_REGION and the AWS keys; ContentS3Endpoint points it at another S3-compatible
+	// service. ContentMaxSize limits each body and ContentMaxPostSize all bodies of a post.
+	ContentStoreDriver      string `mapstructure:"CONTENT_STORE_DRIVER"`
+	ContentStoreDir         string `mapstructure:"CONTENT_STORE_DIR"`
+	ContentS3Bucket         string `mapstructure:"CONTENT_S3_BUCKET"`
+	ContentS3Prefix         string `mapstructure:"CONTENT_S3_PREFIX"`
+	ContentS3Endpoint       string `mapstructure:"CONTENT_S3_ENDPOINT"`
+	ContentS3ForcePathStyle bool   `mapstructure:"CONTENT_S3_FORCE_PATH_STYLE"`
+	// ContentCompression compresses bodies of at least ContentCompressionThreshold bytes at
+	// rest: gzip or identity to store them as is
+	ContentCompression          string `mapstructure:"CONTENT_COMPRESSION"`
+	ContentCompressionThreshold int    `mapstructure:"CONTENT_COMPRESSION_THRESHOLD"`
+	// ContentMasterKey is a base64 encoded 32 byte key that encrypts the bodies of private
+	// posts. Password-protected posts are encrypted with their password, using the Argon2
+	// parameters below. Without a master key private posts are stored unencrypted.
+	ContentMasterKey   string `mapstructure:"CONTENT_MASTER_KEY"`
+	ContentMaxSize     int    `mapstructure:"CONTENT_MAX_SIZE"`
+	ContentMaxPostSize int    `mapstructure:"CONTENT_MAX_POST_SIZE"`
+
+	ExpirationSweepInterval  time.Duration `mapstructure:"EXPIRATION_SWEEP_INTERVAL"`
+	ExpirationSweepBatchSize int           `mapstructure:"EXPIRATION_SWEEP_BATCH_SIZE"`
+	ExpirationSweepArchive   bool          `mapstructure:"EXPIRATION_SWEEP_ARCHIVE"`
+
+	// PasswordHashAlgorithm is used for new password hashes: argon2id or bcrypt. Hashes made
+	// with another algorithm or weaker parameters are upgraded when the password is next used.
+	PasswordHashAlgorithm string `mapstructure:"PASSWORD_HASH_ALGORITHM"`
+	BcryptCost            int    `mapstructure:"BCRYPT_COST"`
+	Argon2Memory          uint32 `mapstructure:"ARGON2_MEMORY"`
+	Argon2Iterations      uint32 `mapstructure:"ARGON2_ITERATIONS"`
+	Argon2Parallelism     uint8  `mapstructure:"ARGON2_PARALLELISM"`
+
+	// LockoutBackend stores failed signin, second factor and paste password attempts:
+	// postgres or memory. Accounts, second factors and pastes are locked after
+	// LockoutThreshold failures, client addresses after LockoutIPThreshold. Locks start at LockoutBaseDelay and double up to LockoutMaxDelay.
+	LockoutBackend     string        `mapstructure:"LOCKOUT_BACKEND"`
+	LockoutThreshold   int           `mapstructure:"LOCKOUT_THRESHOLD"`
+	LockoutIPThreshold int           `mapstructure:"LOCKOUT_IP_THRESHOLD"`
+	LockoutBaseDelay   time.Duration `mapstructure:"LOCKOUT_BASE_DELAY"`
+	LockoutMaxDelay    time.Duration `mapstructure:"LOCKOUT_MAX_DELAY"`
+	LockoutWindow      time.Duration `mapstructure:"LOCKOUT_WINDOW"`
+
+	// RateLimitBackend stores rate limit buckets: memory, or postgres when several instances
+	// must share limits. RateLimits are written as <policy>.<kind>=<burst>/<period>, where
+	// policy is auth, api, post_create or search and kind is anonymous, user or token.
+	// Kinds without an entry are not limited.
+	RateLimitBackend string   `mapstructure:"RATE_LIMIT_BACKEND"`
+	RateLimits       []string `mapstructure:"RATE_LIMITS"`
+
+	// TrustedProxies lists the addresses or CIDR ranges of the reverse proxies whose
+	// X-Forwarded-For header is believed. Client addresses identify anonymous callers for
+	// rate limits and lockouts, so by default no proxy is trusted and the address of the
+	// connection is used.
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
+
+	// RateLimitPruneInterval is how often full buckets are removed from the store
+	RateLimitPruneInterval time.Duration `mapstructure:"RATE_LIMIT_PRUNE_INTERVAL"`
+
+	// TOTPIssuer names the service in authenticator apps
+	TOTPIssuer string `mapstructure:"TOTP_ISSUER"`
+
+	// OIDCProviders names the identity providers users can sign in with. Each one is
+	// configured with OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and _REDIRECT_URL.
+	OIDCProviders []string                      `mapstructure:"OIDC_PROVIDERS"`
+	OIDC          map[string]OIDCProviderConfig `mapstructure:"-"`
+
+	JobPollInterval time.Duration `mapstructure:"JOB_POLL_INTERVAL"`
+	JobBatchSize    int           `mapstructure:"JOB_BATCH_SIZE"`
+
+	// UnverifiedRestrictions lists the actions denied to users whose email is not
+	// verified: signin, create_posts, private_posts and password_reset
+	UnverifiedRestrictions []string `mapstructure:"UNVERIFIED_USER_RESTRICTIONS"`
+}
+
+type OIDCProviderConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
 }
 
 func NewConfig(path string) (config *Config, err error) {
@@ -25,14 +124,95 @@ func NewConfig(path string) (config *Config, err error) {
 
 	viper.AutomaticEnv()
 
+	viper.SetDefault("MAIL_DRIVER", "ses")
+	viper.SetDefault("MAIL_OUTBOX_DIR", "./tmp/outbox")
+	viper.SetDefault("SMTP_PORT", 587)
+	viper.SetDefault("SMTP_STARTTLS", true)
+	viper.SetDefault("CONTENT_STORE_DRIVER", "file")
+	viper.SetDefault("CONTENT_STORE_DIR", "./tmp/content")
+	viper.SetDefault("CONTENT_COMPRESSION", "gzip")
+	viper.SetDefault("CONTENT_COMPRESSION_THRESHOLD", 1024)
+	viper.SetDefault("CONTENT_MAX_SIZE", 1<<20)
+	viper.SetDefault("CONTENT_MAX_POST_SIZE", 4<<20)
+	viper.SetDefault("EXPIRATION_SWEEP_INTERVAL", time.Minute)
+	viper.SetDefault("EXPIRATION_SWEEP_BATCH_SIZE", 500)
+	viper.SetDefault("EXPIRATION_SWEEP_ARCHIVE", false)
+	viper.SetDefault("PASSWORD_HASH_ALGORITHM", "argon2id")
+	viper.SetDefault("BCRYPT_COST", 10)
+	viper.SetDefault("ARGON2_MEMORY", 19*1024)
+	viper.SetDefault("ARGON2_ITERATIONS", 2)
+	viper.SetDefault("ARGON2_PARALLELISM", 1)
+	viper.SetDefault("LOCKOUT_BACKEND", "postgres")
+	viper.SetDefault("LOCKOUT_THRESHOLD", 5)
+	viper.SetDefault("LOCKOUT_IP_THRESHOLD", 20)
+	viper.SetDefault("LOCKOUT_BASE_DELAY", 30*time.Second)
+	viper.SetDefault("LOCKOUT_MAX_DELAY", time.Hour)
+	viper.SetDefault("LOCKOUT_WINDOW", time.Hour)
+	viper.SetDefault("RATE_LIMIT_BACKEND", "memory")
+	viper.SetDefault("RATE_LIMIT_PRUNE_INTERVAL", 10*time.Minute)
+	viper.SetDefault("TRUSTED_PROXIES", []string{})
+	viper.SetDefault("RATE_LIMITS", []string{
+		"auth.anonymous=30/1m",
+		"api.anonymous=300/1m",
+		"api.user=600/1m",
+		"api.token=600/1m",
+		"post_create.anonymous=10/1h",
+		"post_create.user=60/1h",
+		"post_create.token=60/1h",
+		"search.anonymous=30/1m",
+		"search.user=60/1m",
+		"search.token=60/1m",
+	})
+	viper.SetDefault("TOTP_ISSUER", "Paste")
+	viper.SetDefault("JOB_POLL_INTERVAL", 5*time.Second)
+	viper.SetDefault("JOB_BATCH_SIZE", 10)
+	viper.SetDefault("UNVERIFIED_USER_RESTRICTIONS", []string{"private_posts", "password_reset"})
+
 	err = viper.ReadInConfig()
 	if err != nil {
 		return nil, err
 	}
 
 	err = viper.Unmarshal(&config)
+	if err != nil {
+		return nil, err
+	}
 
 	viper.SetConfigType("env")
 
+	config.OIDC = make(map[string]OIDCProviderConfig, len(config.OIDCProviders))
+
+	for _, name := range config.OIDCProviders {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		provider := OIDCProviderConfig{
+			Issuer:       viper.GetString(prefix + "ISSUER"),
+			ClientID:     viper.GetString(prefix + "CLIENT_ID"),
+			ClientSecret: viper.GetString(prefix + "CLIENT_SECRET"),
+			RedirectURL:  viper.GetString(prefix + "REDIRECT_URL"),
+			Scopes:       viper.GetStringSlice(prefix + "SCOPES"),
+		}
+
+		if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return nil, fmt.Errorf("oidc provider %s: %sISSUER, %sCLIENT_ID and %sREDIRECT_URL are required", name, prefix, prefix, prefix)
+		}
+
+		config.OIDC[strings.ToLower(name)] = provider
+	}
+
 	return
 }
+
+// RestrictsUnverified reports whether action is denied to users with an unverified email
+func (c *Config) RestrictsUnverified(action string) bool {
+	return slices.Contains(c.UnverifiedRestrictions, action)
+}
+
+// Sender returns the From address of outgoing emails
+func (c *Config) Sender() string {
+	if c.MailFrom != "" {
+		return c.MailFrom
+	}
+
+	return c.AWSSenderEmail
+}
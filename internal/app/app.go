@@ -2,9 +2,15 @@ package app
 
 import (
 	"github.com/Caixetadev/snippet/config"
+	"github.com/Caixetadev/snippet/internal/entity"
+	repository "github.com/Caixetadev/snippet/internal/infra/db/postgres/repositories"
 	"github.com/Caixetadev/snippet/internal/middleware"
 	"github.com/Caixetadev/snippet/internal/routes"
+	"github.com/Caixetadev/snippet/internal/services"
 	"github.com/Caixetadev/snippet/internal/token"
+	"github.com/Caixetadev/snippet/pkg/contentcrypto"
+	"github.com/Caixetadev/snippet/pkg/contentstore"
+	"github.com/Caixetadev/snippet/pkg/passwordhash"
 	"github.com/Caixetadev/snippet/pkg/validation"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -12,14 +18,32 @@ import (
 
 const BASE_PATH = "/api/v1"
 
-func Run(cfg *config.Config, db *pgxpool.Pool, router *gin.Engine, validation validation.Validator, tokenMaker token.Maker) {
+// NewRouter returns the engine serving the API. Forwarded client addresses are only
+// believed from cfg.TrustedProxies, so callers cannot pick the address they are
+// rate limited and locked out by.
+func NewRouter(cfg *config.Config) (*gin.Engine, error) {
+	router := gin.Default()
+
+	err := router.SetTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	return router, nil
+}
+
+func Run(cfg *config.Config, db *pgxpool.Pool, router *gin.Engine, validation validation.Validator, tokenMaker token.Maker, passwordHasher passwordhash.PasswordHasher, lockout *services.LockoutService, limiter *services.RateLimitService, contents contentstore.Store, compression contentstore.Compression, contentKeys contentcrypto.Keys) {
 	publicRouter := router.Group(BASE_PATH)
+	publicRouter.Use(middleware.RateLimit(limiter, entity.RateLimitAuth))
 
-	routes.NewAuthRouter(cfg, db, publicRouter, validation, tokenMaker)
+	routes.NewAuthRouter(cfg, db, publicRouter, validation, tokenMaker, passwordHasher, lockout)
 
 	protectedRouter := router.Group(BASE_PATH)
 
-	protectedRouter.Use(middleware.AuthPostMiddleware(tokenMaker))
-	routes.NewPostRouter(cfg, db, protectedRouter, validation)
-	routes.NewUserRouter(cfg, db, protectedRouter, validation, tokenMaker)
+	accessTokenService := services.NewAccessTokenService(repository.NewAccessTokenRepository(db), validation)
+
+	protectedRouter.Use(middleware.AuthPostMiddleware(tokenMaker, accessTokenService))
+	protectedRouter.Use(middleware.RateLimit(limiter, entity.RateLimitAPI))
+	routes.NewPostRouter(cfg, db, protectedRouter, validation, tokenMaker, passwordHasher, lockout, limiter, contents, compression, contentKeys)
+	routes.NewUserRouter(cfg, db, protectedRouter, validation, tokenMaker, passwordHasher, lockout)
 }
@@ -0,0 +1,86 @@
+package entity
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/utils"
+	"github.com/google/uuid"
+)
+
+// AccessTokenPrefix marks a bearer token as a personal access token rather than a PASETO token
+const AccessTokenPrefix = "pst_"
+
+const (
+	ScopePostRead  = "post:read"
+	ScopePostWrite = "post:write"
+	ScopeUserRead  = "user:read"
+)
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"-"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateAccessTokenRequest struct {
+	Name      string     `json:"name" validate:"required,max=255" binding:"required"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=post:read post:write user:read" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type CreateAccessTokenResponse struct {
+	Token string               `json:"token"`
+	Info  *PersonalAccessToken `json:"info"`
+}
+
+// NewPersonalAccessToken creates a token record and returns it along with the
+// plaintext token, which is only ever shown to the user once
+func NewPersonalAccessToken(userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*PersonalAccessToken, string, error) {
+	secret, err := utils.GenerateSecureRandomString(40)
+	if err != nil {
+		return nil, "", err
+	}
+
+	plaintext := AccessTokenPrefix + secret
+
+	uuidGenerator := UUIDGeneratorImpl{}
+
+	return &PersonalAccessToken{
+		ID:        uuidGenerator.Generate(),
+		UserID:    userID,
+		Name:      name,
+		TokenHash: HashAccessToken(plaintext),
+		Prefix:    plaintext[:len(AccessTokenPrefix)+4],
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}, plaintext, nil
+}
+
+// HashAccessToken returns the SHA-256 digest under which a token is stored
+func HashAccessToken(token string) string {
+	return HashToken(token)
+}
+
+// IsAccessToken reports whether a bearer token is a personal access token
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
+
+// HasScope reports whether scopes grants scope
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,6 +2,8 @@ package entity
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 
 	"github.com/Caixetadev/snippet/internal/utils"
@@ -46,28 +48,79 @@ func NewRefreshToken(user *User) *RefreshToken {
 }
 
 type Session struct {
-	ID           uuid.UUID
-	Name         string
-	RefreshToken string
-	UserAgent    string
-	ClientIp     string
-	IsBlocked    bool
-	IsRevoked    bool
-	ExpiresAt    time.Time
+	ID           uuid.UUID `json:"id"`
+	UserID       uuid.UUID `json:"-"`
+	FamilyID     uuid.UUID `json:"-"`
+	Name         string    `json:"-"`
+	RefreshToken string    `json:"-"`
+	UserAgent    string    `json:"user_agent"`
+	ClientIp     string    `json:"client_ip"`
+	IsBlocked    bool      `json:"-"`
+	IsRevoked    bool      `json:"-"`
+	RevokedFor   string    `json:"-"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
+// NewSession starts a new token family. Rotated sessions inherit the family of
+// the session they replace.
 func NewSession(ctx context.Context, payload *Payload, refreshToken string) *Session {
 	return &Session{
 		ID:           payload.ID,
+		UserID:       payload.UserID,
+		FamilyID:     payload.ID,
 		Name:         payload.Username,
 		RefreshToken: refreshToken,
 		UserAgent:    ctx.(*gin.Context).Request.UserAgent(),
 		ClientIp:     ctx.(*gin.Context).ClientIP(),
 		IsBlocked:    false,
 		ExpiresAt:    payload.ExpiredAt,
+		CreatedAt:    time.Now(),
 	}
 }
 
+// Reasons a session is revoked for. Only a rotated session presented again is refresh
+// token reuse; signed out sessions are simply no longer valid.
+const (
+	SessionRotated   = "rotated"
+	SessionReused    = "reuse"
+	SessionSignedOut = "signout"
+)
+
+const SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+
+// SecurityEvent records suspicious activity on an account
+type SecurityEvent struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Type      string
+	SessionID uuid.UUID
+	FamilyID  uuid.UUID
+	UserAgent string
+	ClientIp  string
+	CreatedAt time.Time
+}
+
+func NewSecurityEvent(ctx context.Context, eventType string, session *Session) *SecurityEvent {
+	uuidGenerator := UUIDGeneratorImpl{}
+
+	event := &SecurityEvent{
+		ID:        uuidGenerator.Generate(),
+		UserID:    session.UserID,
+		Type:      eventType,
+		SessionID: session.ID,
+		FamilyID:  session.FamilyID,
+		CreatedAt: time.Now(),
+	}
+
+	if ginCtx, ok := ctx.(*gin.Context); ok && ginCtx.Request != nil {
+		event.UserAgent = ginCtx.Request.UserAgent()
+		event.ClientIp = ginCtx.ClientIP()
+	}
+
+	return event
+}
+
 func NewVerificationData(userID uuid.UUID, email string, code string) *VerificationData {
 	uuidGenerator := UUIDGeneratorImpl{}
 
@@ -80,6 +133,39 @@ func NewVerificationData(userID uuid.UUID, email string, code string) *Verificat
 	}
 }
 
+// EmailVerificationTTL is how long a signup verification link stays valid
+const EmailVerificationTTL = 24 * time.Hour
+
+type EmailVerification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+func NewEmailVerification(userID uuid.UUID, token string) *EmailVerification {
+	uuidGenerator := UUIDGeneratorImpl{}
+
+	return &EmailVerification{
+		ID:        uuidGenerator.Generate(),
+		UserID:    userID,
+		TokenHash: HashToken(token),
+		ExpiresAt: time.Now().Add(EmailVerificationTTL),
+		CreatedAt: time.Now(),
+	}
+}
+
+// HashToken returns the SHA-256 digest of a secret token so that only the digest is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email" binding:"required"`
+}
+
 type ForgotPasswordRequest struct {
 	Email string `json:"email" validate:"required,email" binding:"required"`
 }
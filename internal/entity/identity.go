@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OIDCStateTTL is how long a user has to complete the login at the identity provider
+const OIDCStateTTL = 10 * time.Minute
+
+// Identity links a user to their account at an external identity provider
+type Identity struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
+
+func NewIdentity(userID uuid.UUID, provider, subject, email string) *Identity {
+	uuidGenerator := UUIDGeneratorImpl{}
+
+	return &Identity{
+		ID:        uuidGenerator.Generate(),
+		UserID:    userID,
+		Provider:  provider,
+		Subject:   subject,
+		Email:     email,
+		CreatedAt: time.Now(),
+	}
+}
+
+// OIDCState holds what the callback of a login started at the identity provider
+// needs to check: the nonce expected in the ID token and the PKCE code verifier
+type OIDCState struct {
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+func NewOIDCState(provider, state, nonce, verifier string) *OIDCState {
+	return &OIDCState{
+		StateHash:    HashToken(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(OIDCStateTTL),
+	}
+}
@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Job states. A job that keeps failing until it runs out of attempts is moved to
+// JobDead and is no longer picked up by workers.
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobDead    = "dead"
+)
+
+// DefaultJobMaxAttempts is how many times a job is tried before it is dead-lettered
+const DefaultJobMaxAttempts = 8
+
+const EmailQueue = "email"
+
+type Job struct {
+	ID          uuid.UUID
+	Queue       string
+	Payload     json.RawMessage
+	Status      string
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+	LastError   string
+	CreatedAt   time.Time
+}
+
+// NewJob creates a pending job that is due immediately
+func NewJob(queue string, payload any) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	uuidGenerator := UUIDGeneratorImpl{}
+
+	return &Job{
+		ID:          uuidGenerator.Generate(),
+		Queue:       queue,
+		Payload:     data,
+		Status:      JobPending,
+		MaxAttempts: DefaultJobMaxAttempts,
+		RunAt:       time.Now(),
+		CreatedAt:   time.Now(),
+	}, nil
+}
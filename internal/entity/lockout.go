@@ -0,0 +1,91 @@
+package entity
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Scopes of failed attempt tracking, each with its own LockoutPolicy
+const (
+	AttemptScopeAccount   = "account"
+	AttemptScopeIP        = "ip"
+	AttemptScopePost      = "post"
+	AttemptScopeTwoFactor = "two_factor"
+)
+
+// AttemptKey identifies what failed attempts are counted against
+type AttemptKey struct {
+	Scope string
+	Value string
+}
+
+func AccountAttemptKey(email string) AttemptKey {
+	return AttemptKey{Scope: AttemptScopeAccount, Value: strings.ToLower(strings.TrimSpace(email))}
+}
+
+func IPAttemptKey(ip string) AttemptKey {
+	return AttemptKey{Scope: AttemptScopeIP, Value: ip}
+}
+
+func PostAttemptKey(id string) AttemptKey {
+	return AttemptKey{Scope: AttemptScopePost, Value: id}
+}
+
+// TwoFactorAttemptKey counts the wrong second factor codes of a user across sign-ins
+func TwoFactorAttemptKey(userID string) AttemptKey {
+	return AttemptKey{Scope: AttemptScopeTwoFactor, Value: userID}
+}
+
+func (k AttemptKey) String() string {
+	return k.Scope + ":" + k.Value
+}
+
+// FailedAttempts is the failure count of one key and the lock it caused
+type FailedAttempts struct {
+	Key         string
+	Failures    int
+	LockedUntil time.Time
+	UpdatedAt   time.Time
+}
+
+// LockoutPolicy locks a key once Threshold failures happened within Window of each other.
+// The lock starts at BaseDelay and doubles with every further failure, up to MaxDelay.
+type LockoutPolicy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+// LockFor returns how long a key with the given number of failures is locked
+func (p LockoutPolicy) LockFor(failures int) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold {
+		return 0
+	}
+
+	delay := p.BaseDelay
+
+	for i := p.Threshold; i < failures; i++ {
+		delay *= 2
+
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+
+	return min(delay, p.MaxDelay)
+}
+
+// ClientIP returns the address of the client when ctx is a gin request context. The
+// forwarded address is only used when the request came through a trusted proxy, see
+// app.NewRouter, so callers cannot spread attempts over addresses of their choosing.
+func ClientIP(ctx context.Context) string {
+	if ginCtx, ok := ctx.(*gin.Context); ok && ginCtx.Request != nil {
+		return ginCtx.ClientIP()
+	}
+
+	return ""
+}
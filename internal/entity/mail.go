@@ -5,15 +5,14 @@ import (
 	"html/template"
 	"log"
 	"os"
+	texttemplate "text/template"
 
 	"github.com/Caixetadev/snippet/internal/utils"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/ses"
 )
 
 type MailData struct {
-	Username string
-	Code     string
+	Username string `json:"username"`
+	Code     string `json:"code"`
 }
 
 func NewMailData(username string) MailData {
@@ -23,44 +22,44 @@ func NewMailData(username string) MailData {
 	}
 }
 
-const charset = "UTF-8"
-
-func NewEmail(to string, htmlBody string, subject string, sourceEmail string) *ses.SendEmailInput {
-	return &ses.SendEmailInput{
-		Destination: &ses.Destination{
-			ToAddresses: []*string{aws.String(to)},
-		},
-		Message: &ses.Message{
-			Body: &ses.Body{
-				Html: &ses.Content{
-					Charset: aws.String(charset),
-					Data:    aws.String(htmlBody),
-				},
-			},
-			Subject: &ses.Content{
-				Charset: aws.String(charset),
-				Data:    aws.String(subject),
-			},
-		},
-		Source: aws.String(sourceEmail),
-	}
+// Email is the payload of a job on EmailQueue
+type Email struct {
+	To       string   `json:"to"`
+	Subject  string   `json:"subject"`
+	Template string   `json:"template"`
+	Data     MailData `json:"data"`
 }
 
-type EmailService interface {
-	SendResetPasswordEmail(user *User) (string, error)
+// NewEmailJob creates a job that renders template with data and mails it to the given address
+func NewEmailJob(to string, subject string, template string, data MailData) (*Job, error) {
+	return NewJob(EmailQueue, Email{
+		To:       to,
+		Subject:  subject,
+		Template: template,
+		Data:     data,
+	})
 }
 
-func GetHTMLTemplate(emailData MailData) string {
+// Email templates in ./web/template. Each has an .html and a .txt variant.
+const (
+	PasswordRecoveryTemplate  = "password_recovery"
+	EmailVerificationTemplate = "email_verification"
+)
+
+// GetHTMLTemplate renders the HTML variant of one of the templates in ./web/template
+func GetHTMLTemplate(name string, emailData MailData) string {
 	var templateBuffer bytes.Buffer
 
-	htmlData, err := os.ReadFile("./web/template/password_recovery.html")
+	name += ".html"
+
+	htmlData, err := os.ReadFile("./web/template/" + name)
 	if err != nil {
 		return ""
 	}
 
-	htmlTemplate := template.Must(template.New("password_recovery.html").Parse(string(htmlData)))
+	htmlTemplate := template.Must(template.New(name).Parse(string(htmlData)))
 
-	err = htmlTemplate.ExecuteTemplate(&templateBuffer, "password_recovery.html", emailData)
+	err = htmlTemplate.ExecuteTemplate(&templateBuffer, name, emailData)
 
 	if err != nil {
 		log.Fatal(err)
@@ -69,3 +68,27 @@ func GetHTMLTemplate(emailData MailData) string {
 
 	return templateBuffer.String()
 }
+
+// GetTextTemplate renders the plain text variant of one of the templates in ./web/template
+func GetTextTemplate(name string, emailData MailData) string {
+	var templateBuffer bytes.Buffer
+
+	name += ".txt"
+
+	textData, err := os.ReadFile("./web/template/" + name)
+	if err != nil {
+		return ""
+	}
+
+	textTemplate, err := texttemplate.New(name).Parse(string(textData))
+	if err != nil {
+		return ""
+	}
+
+	err = textTemplate.Execute(&templateBuffer, emailData)
+	if err != nil {
+		return ""
+	}
+
+	return templateBuffer.String()
+}
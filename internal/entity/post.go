@@ -2,6 +2,7 @@ package entity
 
 import (
 	"time"
+	"unicode/utf8"
 
 	"github.com/Caixetadev/snippet/internal/utils"
 )
@@ -14,36 +15,154 @@ const (
 	Unlisted Visibility = "unlisted"
 )
 
+// ContentEncryption says how the bodies of a post are encrypted at rest
+type ContentEncryption string
+
+const (
+	Unencrypted ContentEncryption = ""
+	// EncryptedWithPassword posts have their data key wrapped with a key derived from
+	// the post password
+	EncryptedWithPassword ContentEncryption = "password"
+	// EncryptedWithMasterKey posts have their data key wrapped with the master key
+	EncryptedWithMasterKey ContentEncryption = "master_key"
+)
+
+// ManagementTokenPrefix marks a token that manages an anonymous post
+const ManagementTokenPrefix = "pmt_"
+
+// MinPostPasswordLength is the shortest password that can protect a post
+const MinPostPasswordLength = 8
+
+// ContentPreviewSize is how many bytes of a post body are kept in the posts table for
+// listings and substring search. The full body lives in the content store.
+const ContentPreviewSize = 512
+
+// ContentLimits bounds the size in bytes of a single body and of all bodies of a post.
+// Zero means no limit.
+type ContentLimits struct {
+	MaxSize     int
+	MaxPostSize int
+}
+
+// ContentPreview returns the start of content, cut at a character boundary
+func ContentPreview(content string) string {
+	return TruncateContent(content, ContentPreviewSize)
+}
+
+// TruncateContent returns at most size bytes of content, cut at a character boundary
+func TruncateContent(content string, size int) string {
+	if len(content) <= size {
+		return content
+	}
+
+	end := size
+	for end > 0 && !utf8.RuneStart(content[end]) {
+		end--
+	}
+
+	return content[:end]
+}
+
 type PostInput struct {
 	ID              string     `json:"id"`
 	UserID          *string    `json:"-"`
 	Title           string     `json:"title" validate:"required" binding:"required"`
-	Content         string     `json:"content,omitempty" validate:"required" binding:"required"`
+	Content         string     `json:"content,omitempty" validate:"required_without=Files" binding:"required_without=Files"`
+	Language        string     `json:"language,omitempty" validate:"max=64"`
+	Files           []PostFile `json:"files,omitempty" validate:"omitempty,dive"`
 	CreatedAt       time.Time  `json:"created_at"`
 	ExpirationAt    time.Time  `json:"expiration_at,omitempty"`
 	Password        string     `json:"password,omitempty"`
 	HasPassword     bool       `json:"has_password"`
 	Visibility      Visibility `json:"visibility" validate:"required,oneof=private public unlisted"`
 	DeleteAfterView bool       `json:"delete_after_view"`
+	ForkedFrom      *string    `json:"-"`
+	// Encrypted posts are encrypted by the client. Content and the file contents are
+	// pastecrypt envelopes the server cannot read.
+	Encrypted bool `json:"encrypted"`
+	// ManagementToken is returned once to the creator of an anonymous post, only its
+	// hash is stored
+	ManagementToken     string `json:"-"`
+	ManagementTokenHash string `json:"-"`
+	// Encryption and the wrapped DataKey are set when the bodies are sealed
+	Encryption ContentEncryption `json:"-"`
+	DataKey    []byte            `json:"-"`
 }
 
 type PostOutput struct {
-	ID              string     `json:"id"`
-	UserID          *string    `json:"user_id"`
-	Title           string     `json:"title" validate:"required" binding:"required"`
-	Content         string     `json:"content,omitempty" validate:"required" binding:"required"`
-	CreatedAt       time.Time  `json:"created_at"`
-	ExpirationAt    time.Time  `json:"expiration_at"`
-	Password        string     `json:"-"`
-	HasPassword     bool       `json:"has_password"`
-	Visibility      Visibility `json:"visibility,omitempty"`
-	DeleteAfterView bool       `json:"delete_after_view"`
+	ID              string            `json:"id"`
+	UserID          *string           `json:"user_id"`
+	Title           string            `json:"title" validate:"required" binding:"required"`
+	Content         string            `json:"content,omitempty" validate:"required" binding:"required"`
+	Language        string            `json:"language,omitempty"`
+	Files           []PostFile        `json:"files,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	ExpirationAt    time.Time         `json:"expiration_at"`
+	Password        string            `json:"-"`
+	HasPassword     bool              `json:"has_password"`
+	Visibility      Visibility        `json:"visibility,omitempty"`
+	DeleteAfterView bool              `json:"delete_after_view"`
+	Revision        int               `json:"revision"`
+	ForkedFrom      *string           `json:"forked_from"`
+	ForkCount       int               `json:"fork_count"`
+	Highlight       string            `json:"highlight,omitempty"`
+	Encrypted       bool              `json:"encrypted"`
+	Encryption      ContentEncryption `json:"-"`
+	DataKey         []byte            `json:"-"`
+	// ManagementTokenHash is set on anonymous posts that can still be managed by token
+	ManagementTokenHash string `json:"-"`
+}
+
+// PostCreateOutput identifies a new post. ManagementToken is only set for anonymous
+// posts and cannot be retrieved again.
+type PostCreateOutput struct {
+	ID              string `json:"id"`
+	ManagementToken string `json:"management_token,omitempty"`
+}
+
+type ForkPostInput struct {
+	Title      string     `json:"title" validate:"max=255"`
+	Visibility Visibility `json:"visibility" validate:"omitempty,oneof=private public unlisted"`
 }
 
 type PostUpdateInput struct {
-	ID      string `json:"-"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	ID       string `json:"-"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Language string `json:"language"`
+	// Password is required to change the content of a password-protected post
+	Password   string            `json:"password,omitempty"`
+	Encrypted  bool              `json:"-"`
+	Encryption ContentEncryption `json:"-"`
+}
+
+// PostFile is one named file of a multi-file post
+type PostFile struct {
+	Filename string `json:"filename" validate:"required,max=255"`
+	Language string `json:"language,omitempty" validate:"max=64"`
+	Content  string `json:"content" validate:"required"`
+}
+
+// PostArchive is a post bundled into a downloadable archive
+type PostArchive struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// PostRevision is an immutable snapshot of a post's title and content
+type PostRevision struct {
+	PostID    string    `json:"post_id"`
+	Revision  int       `json:"revision"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PostDiffOutput struct {
+	From int    `json:"from"`
+	To   int    `json:"to"`
+	Diff string `json:"diff"`
 }
 
 type GetPostInput struct {
@@ -57,12 +176,25 @@ type PaginationInfo struct {
 	Count int     `json:"count"`
 }
 
-func NewPost(userID *string, title string, content string, password string, hasPassword bool, visibility Visibility, expirationAt time.Time, deleteAfterView bool) *PostInput {
+// NewManagementToken returns a new post management token and its hash
+func NewManagementToken() (string, string, error) {
+	secret, err := utils.GenerateSecureRandomString(40)
+	if err != nil {
+		return "", "", err
+	}
+
+	token := ManagementTokenPrefix + secret
+
+	return token, HashToken(token), nil
+}
+
+func NewPost(userID *string, title string, content string, language string, password string, hasPassword bool, visibility Visibility, expirationAt time.Time, deleteAfterView bool) *PostInput {
 	return &PostInput{
 		ID:              utils.GenerateRandomString(8),
 		UserID:          userID,
 		Title:           title,
 		Content:         content,
+		Language:        language,
 		Password:        password,
 		HasPassword:     hasPassword,
 		Visibility:      visibility,
@@ -0,0 +1,32 @@
+package entity
+
+import "github.com/Caixetadev/snippet/pkg/ratelimit"
+
+// Route groups with their own rate limit policy
+const (
+	RateLimitAuth       = "auth"
+	RateLimitAPI        = "api"
+	RateLimitPostCreate = "post_create"
+	RateLimitSearch     = "search"
+)
+
+// Kinds of callers a policy sets limits for
+const (
+	RateLimitAnonymous = "anonymous"
+	RateLimitUser      = "user"
+	RateLimitToken     = "token"
+)
+
+// RateLimitIdentity is who a request is counted against: the client address of anonymous
+// requests, the user of session requests or the personal access token
+type RateLimitIdentity struct {
+	Kind  string
+	Value string
+}
+
+func (i RateLimitIdentity) String() string {
+	return i.Kind + ":" + i.Value
+}
+
+// RateLimitPolicy holds the limit of each kind of caller. Kinds without a limit are not limited.
+type RateLimitPolicy map[string]ratelimit.Limit
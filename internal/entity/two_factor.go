@@ -0,0 +1,91 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// SigninChallengeTTL is how long a user has to enter their second factor after the password
+	SigninChallengeTTL = 5 * time.Minute
+
+	// SigninChallengeMaxAttempts is how many wrong codes a challenge accepts before it is discarded
+	SigninChallengeMaxAttempts = 5
+
+	// RecoveryCodeCount is how many recovery codes are issued when 2FA is enabled
+	RecoveryCodeCount = 10
+)
+
+// TwoFactor is the TOTP state of a user. Secret is set during setup and Enabled
+// once the user confirmed a code generated from it.
+type TwoFactor struct {
+	UserID   uuid.UUID
+	Secret   string
+	Enabled  bool
+	LastStep *int64
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+}
+
+func NewRecoveryCode(userID uuid.UUID, code string) *RecoveryCode {
+	uuidGenerator := UUIDGeneratorImpl{}
+
+	return &RecoveryCode{
+		ID:        uuidGenerator.Generate(),
+		UserID:    userID,
+		CodeHash:  HashToken(code),
+		CreatedAt: time.Now(),
+	}
+}
+
+// SigninChallenge is issued after a correct password when the account has 2FA enabled
+type SigninChallenge struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	Attempts  int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+func NewSigninChallenge(userID uuid.UUID, token string) *SigninChallenge {
+	uuidGenerator := UUIDGeneratorImpl{}
+
+	return &SigninChallenge{
+		ID:        uuidGenerator.Generate(),
+		UserID:    userID,
+		TokenHash: HashToken(token),
+		ExpiresAt: time.Now().Add(SigninChallengeTTL),
+		CreatedAt: time.Now(),
+	}
+}
+
+type TwoFactorSetupResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauthUri"`
+}
+
+type TwoFactorConfirmRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorConfirmResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type SigninChallengeResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	ChallengeToken    string `json:"challengeToken"`
+}
+
+// SigninTwoFactorRequest completes a sign-in with either a TOTP code or a recovery code
+type SigninTwoFactorRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
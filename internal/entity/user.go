@@ -12,8 +12,20 @@ type User struct {
 	Name     string    `json:"name"         validate:"required"       binding:"required"`
 	Email    string    `json:"email"        validate:"required,email" binding:"required"`
 	Password string    `json:"password,omitempty"     validate:"required"       binding:"required"`
+
+	EmailVerified    bool `json:"email_verified"`
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
 }
 
+// Actions that can be withheld from users who have not verified their email,
+// see config.Config.UnverifiedRestrictions
+const (
+	RestrictSignin        = "signin"
+	RestrictCreatePosts   = "create_posts"
+	RestrictPrivatePosts  = "private_posts"
+	RestrictPasswordReset = "password_reset"
+)
+
 func NewUser(name, email, password string) *User {
 	uuidGenerator := UUIDGeneratorImpl{}
 
@@ -33,11 +45,19 @@ type UserService interface {
 	CreateAccessToken(user *User, expiry time.Duration) (string, *Payload, error)
 	CreateRefreshToken(ctx context.Context, user *User, expiry time.Duration) (string, *Payload, error)
 	CompareHashAndPassword(passwordInDatabase, passwordRequest string) error
-	StoreVerificationData(ctx context.Context, userID uuid.UUID, email string, code string) error
+	RehashPassword(ctx context.Context, user *User, password string)
+	RequestPasswordReset(ctx context.Context, user *User) error
 	VerifyCodeToResetPassword(ctx context.Context, code string) (uuid.UUID, error)
 	UpdatePassword(ctx context.Context, password string, passwordConfirmation string, id uuid.UUID) error
 	VerifyToken(ctx context.Context, token string) (*Payload, error)
 	GetSession(ctx context.Context, refreshPayload *Payload, refreshToken string) (*Session, error)
 	CreateSession(ctx context.Context, payload *Payload, token string) error
+	RotateSession(ctx context.Context, current *Session, payload *Payload, token string) error
+	RequestEmailVerification(ctx context.Context, user *User) error
+	VerifyEmail(ctx context.Context, token string) error
+	IsEmailVerified(ctx context.Context, userID uuid.UUID) (bool, error)
 	RevokeRefreshToken(ctx context.Context, token string) error
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]*Session, error)
+	RevokeSession(ctx context.Context, userID uuid.UUID, id uuid.UUID) error
+	RevokeAllSessions(ctx context.Context, userID uuid.UUID) error
 }
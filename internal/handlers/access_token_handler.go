@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Caixetadev/snippet/config"
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AccessTokenService interface {
+	Create(ctx context.Context, userID uuid.UUID, input *entity.CreateAccessTokenRequest) (*entity.CreateAccessTokenResponse, error)
+	List(ctx context.Context, userID uuid.UUID) ([]*entity.PersonalAccessToken, error)
+	Revoke(ctx context.Context, userID uuid.UUID, id uuid.UUID) error
+}
+
+type AccessTokenHandler struct {
+	AccessTokenService AccessTokenService
+	Env                *config.Config
+}
+
+// @Summary		Create a personal access token
+// @Schemes		http
+// @Description	Create a long-lived personal access token for CLI and CI usage. The token is only shown once.
+// @Tags			User
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			request	body		entity.CreateAccessTokenRequest	true	"Token"
+// @Success		201		{object}	entity.Response					"Token created successfully"
+// @Failure		400		{object}	typesystem.Http					"Bad Request"
+// @Failure		401		{object}	typesystem.Http					"Unauthorized"
+// @Router			/user/tokens [post]
+func (ah *AccessTokenHandler) CreateToken(ctx *gin.Context) {
+	var payload entity.CreateAccessTokenRequest
+
+	err := ctx.ShouldBindJSON(&payload)
+	if err != nil {
+		ctx.Error(typesystem.BadRequest)
+		return
+	}
+
+	userID, err := uuid.Parse(ctx.GetString("x-user-id"))
+	if err != nil {
+		ctx.Error(typesystem.Unauthorized)
+		return
+	}
+
+	token, err := ah.AccessTokenService.Create(ctx, userID, &payload)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := entity.Response{
+		Status:  http.StatusCreated,
+		Message: "Token created successfully",
+		Data:    token,
+	}
+
+	ctx.JSON(http.StatusCreated, response)
+}
+
+// @Summary		List personal access tokens
+// @Schemes		http
+// @Description	List the active personal access tokens of the authenticated user
+// @Tags			User
+// @Produce		json
+// @Security		BearerAuth
+// @Success		200	{object}	entity.Response	"Tokens retrieved successfully"
+// @Failure		401	{object}	typesystem.Http	"Unauthorized"
+// @Router			/user/tokens [get]
+func (ah *AccessTokenHandler) GetTokens(ctx *gin.Context) {
+	userID, err := uuid.Parse(ctx.GetString("x-user-id"))
+	if err != nil {
+		ctx.Error(typesystem.Unauthorized)
+		return
+	}
+
+	tokens, err := ah.AccessTokenService.List(ctx, userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := entity.Response{
+		Status:  http.StatusOK,
+		Message: "Tokens retrieved successfully",
+		Data:    tokens,
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// @Summary		Revoke a personal access token
+// @Schemes		http
+// @Description	Revoke a personal access token of the authenticated user
+// @Tags			User
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id	path		string			true	"Token ID"
+// @Success		200	{object}	entity.Response	"Token revoked successfully"
+// @Failure		401	{object}	typesystem.Http	"Unauthorized"
+// @Failure		404	{object}	typesystem.Http	"Token not found"
+// @Router			/user/tokens/{id} [delete]
+func (ah *AccessTokenHandler) RevokeToken(ctx *gin.Context) {
+	userID, err := uuid.Parse(ctx.GetString("x-user-id"))
+	if err != nil {
+		ctx.Error(typesystem.Unauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(typesystem.NotFound)
+		return
+	}
+
+	err = ah.AccessTokenService.Revoke(ctx, userID, id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := entity.Response{
+		Status:  http.StatusOK,
+		Message: "Token revoked successfully",
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
@@ -1,18 +1,38 @@
 package handlers
 
 import (
+	"context"
+	"crypto/subtle"
 	"net/http"
+	"strings"
 
 	"github.com/Caixetadev/snippet/config"
 	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
 	"github.com/Caixetadev/snippet/pkg/typesystem"
 	"github.com/gin-gonic/gin"
 )
 
+type OIDCService interface {
+	Start(ctx context.Context, provider string) (string, string, error)
+	Callback(ctx context.Context, provider string, state string, code string) (*entity.User, error)
+}
+
+type LockoutService interface {
+	Check(ctx context.Context, keys ...entity.AttemptKey) error
+	Fail(ctx context.Context, keys ...entity.AttemptKey) error
+	Reset(ctx context.Context, keys ...entity.AttemptKey) error
+}
+
+// oidcStateCookie binds a login at an identity provider to the browser that started it
+const oidcStateCookie = "oidc_state"
+
 type AuthHandler struct {
-	UserService  entity.UserService
-	EmailService entity.EmailService
-	Env          *config.Config
+	UserService      entity.UserService
+	TwoFactorService TwoFactorService
+	OIDCService      OIDCService
+	Lockout          LockoutService
+	Env              *config.Config
 }
 
 // @Summary	Create account
@@ -44,7 +64,13 @@ func (lc *AuthHandler) Signup(c *gin.Context) {
 		return
 	}
 
-	_, err = lc.UserService.Create(c, payload)
+	user, err := lc.UserService.Create(c, payload)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = lc.UserService.RequestEmailVerification(c, user)
 	if err != nil {
 		c.Error(err)
 		return
@@ -66,6 +92,8 @@ func (lc *AuthHandler) Signup(c *gin.Context) {
 // @Produce		json
 // @Param			request	body		entity.SigninRequest	true	"User"
 // @Success		200		{object}	entity.SigninResponse
+// @Failure		401		{object}	typesystem.Http	"Invalid credentials"
+// @Failure		429		{object}	typesystem.Http	"Too many failed attempts, see Retry-After"
 // @Router			/auth/signin [post]
 func (sc *AuthHandler) Signin(ctx *gin.Context) {
 	var payload entity.SigninRequest
@@ -76,44 +104,228 @@ func (sc *AuthHandler) Signin(ctx *gin.Context) {
 		return
 	}
 
-	user, err := sc.UserService.GetUserByEmail(ctx, payload.Email)
+	accountKey := entity.AccountAttemptKey(payload.Email)
+	ipKey := entity.IPAttemptKey(entity.ClientIP(ctx))
+
+	// A locked caller gets no answer about the password, right or wrong
+	err = sc.Lockout.Check(ctx, accountKey, ipKey)
 	if err != nil {
 		ctx.Error(err)
 		return
 	}
 
-	err = sc.UserService.CompareHashAndPassword(user.Password, payload.Password)
+	user, err := sc.UserService.GetUserByEmail(ctx, payload.Email)
+	if err != nil && err != typesystem.Unauthorized {
+		ctx.Error(err)
+		return
+	}
+
+	// Guesses against unknown emails count too
+	if err == nil {
+		err = sc.UserService.CompareHashAndPassword(user.Password, payload.Password)
+	}
+
 	if err != nil {
+		err = sc.Lockout.Fail(ctx, accountKey, ipKey)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
 		ctx.Error(typesystem.Unauthorized)
 		return
 	}
 
-	accessToken, _, err := sc.UserService.CreateAccessToken(user, sc.Env.AccessTokenDuration)
+	err = sc.Lockout.Reset(ctx, accountKey)
 	if err != nil {
-		ctx.Error(typesystem.BadRequest)
+		ctx.Error(err)
 		return
 	}
 
-	refreshToken, refreshPayload, err := sc.UserService.CreateRefreshToken(ctx, user, sc.Env.RefreshTokenDuration)
+	sc.UserService.RehashPassword(ctx, user, payload.Password)
+
+	if !user.EmailVerified && sc.Env.RestrictsUnverified(entity.RestrictSignin) {
+		ctx.Error(services.ErrEmailNotVerified)
+		return
+	}
+
+	sc.completeSignin(ctx, user)
+}
+
+// completeSignin issues the tokens of a user who proved their identity, or a
+// challenge for the second factor when the user has 2FA enabled
+func (sc *AuthHandler) completeSignin(ctx *gin.Context, user *entity.User) {
+	if user.TwoFactorEnabled {
+		challengeToken, err := sc.TwoFactorService.CreateChallenge(ctx, user.ID)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, entity.SigninChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+		})
+		return
+	}
+
+	signinResponse, err := sc.issueTokens(ctx, user)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, signinResponse)
+}
+
+// @Summary	Start signing in with an identity provider
+// @Schemes
+// @Description	Redirect to the login page of a configured OpenID Connect provider
+// @Tags			Auth
+// @Param			provider	path	string	true	"Provider name"
+// @Success		302
+// @Failure		404	{object}	entity.Response	"Unknown provider"
+// @Router			/auth/oidc/{provider}/start [get]
+func (sc *AuthHandler) OIDCStart(ctx *gin.Context) {
+	provider := ctx.Param("provider")
+
+	authURL, state, err := sc.OIDCService.Start(ctx, provider)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	// Lax, because the provider sends the user back with a cross-site redirect
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcStateCookie, state, int(entity.OIDCStateTTL.Seconds()), oidcCookiePath(ctx), "", isHTTPS(ctx), true)
+
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// @Summary	Complete signing in with an identity provider
+// @Schemes
+// @Description	Callback of the OpenID Connect provider. Creates the account or links it by verified email, then signs the user in like signin.
+// @Tags			Auth
+// @Produce		json
+// @Param			provider	path		string	true	"Provider name"
+// @Param			state		query		string	true	"State"
+// @Param			code		query		string	true	"Authorization code"
+// @Success		200			{object}	entity.SigninResponse
+// @Failure		401			{object}	entity.Response	"Login failed"
+// @Failure		409			{object}	entity.Response	"Email not verified by the provider"
+// @Router			/auth/oidc/{provider}/callback [get]
+func (sc *AuthHandler) OIDCCallback(ctx *gin.Context) {
+	provider := ctx.Param("provider")
+
+	state := ctx.Query("state")
+	code := ctx.Query("code")
+
+	if ctx.Query("error") != "" || state == "" || code == "" {
+		ctx.Error(services.ErrOIDCLoginFailed)
+		return
+	}
+
+	cookie, err := ctx.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+		ctx.Error(typesystem.TokenInvalidError)
+		return
+	}
+
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcStateCookie, "", -1, oidcCookiePath(ctx), "", isHTTPS(ctx), true)
+
+	user, err := sc.OIDCService.Callback(ctx, provider, state, code)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if !user.EmailVerified && sc.Env.RestrictsUnverified(entity.RestrictSignin) {
+		ctx.Error(services.ErrEmailNotVerified)
+		return
+	}
+
+	sc.completeSignin(ctx, user)
+}
+
+// oidcCookiePath scopes the state cookie to the routes of one provider, wherever the
+// auth routes are mounted
+func oidcCookiePath(ctx *gin.Context) string {
+	path := strings.TrimSuffix(ctx.FullPath(), "/start")
+	path = strings.TrimSuffix(path, "/callback")
+
+	return strings.Replace(path, ":provider", ctx.Param("provider"), 1)
+}
+
+func isHTTPS(ctx *gin.Context) bool {
+	return ctx.Request.TLS != nil || ctx.GetHeader("X-Forwarded-Proto") == "https"
+}
+
+// @Summary	Complete a two-factor sign-in
+// @Schemes
+// @Description	Exchange the challenge token returned by signin and a TOTP or recovery code for the access and refresh tokens
+// @Tags			Auth
+// @Accept			json
+// @Produce		json
+// @Param			request	body		entity.SigninTwoFactorRequest	true	"Challenge"
+// @Success		200		{object}	entity.SigninResponse
+// @Failure		400		{object}	entity.Response	"Bad Request"
+// @Failure		401		{object}	entity.Response	"Invalid code or challenge"
+// @Failure		429		{object}	typesystem.Http	"Too many failed attempts, see Retry-After"
+// @Router			/auth/signin/2fa [post]
+func (sc *AuthHandler) SigninTwoFactor(ctx *gin.Context) {
+	var payload entity.SigninTwoFactorRequest
+
+	err := ctx.ShouldBindJSON(&payload)
 	if err != nil {
 		ctx.Error(typesystem.BadRequest)
 		return
 	}
 
-	err = sc.UserService.CreateSession(ctx, refreshPayload, refreshToken)
+	userID, err := sc.TwoFactorService.VerifyChallenge(ctx, payload.ChallengeToken, payload.Code)
 	if err != nil {
 		ctx.Error(err)
 		return
 	}
 
-	signinResponse := entity.SigninResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+	user, err := sc.UserService.GetUserByID(ctx, userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	signinResponse, err := sc.issueTokens(ctx, user)
+	if err != nil {
+		ctx.Error(err)
+		return
 	}
 
 	ctx.JSON(http.StatusOK, signinResponse)
 }
 
+// issueTokens creates the access and refresh tokens of a new session
+func (sc *AuthHandler) issueTokens(ctx *gin.Context, user *entity.User) (*entity.SigninResponse, error) {
+	accessToken, _, err := sc.UserService.CreateAccessToken(user, sc.Env.AccessTokenDuration)
+	if err != nil {
+		return nil, typesystem.BadRequest
+	}
+
+	refreshToken, refreshPayload, err := sc.UserService.CreateRefreshToken(ctx, user, sc.Env.RefreshTokenDuration)
+	if err != nil {
+		return nil, typesystem.BadRequest
+	}
+
+	err = sc.UserService.CreateSession(ctx, refreshPayload, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.SigninResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
 // @Summary		Submit a request to reset the user's password
 // @Description	Submit a request to reset the user's password by providing their email address.
 // @Tags			Auth
@@ -141,13 +353,12 @@ func (ac *AuthHandler) ForgotPassword(ctx *gin.Context) {
 		return
 	}
 
-	code, err := ac.EmailService.SendResetPasswordEmail(user)
-	if err != nil {
-		ctx.Error(err)
+	if !user.EmailVerified && ac.Env.RestrictsUnverified(entity.RestrictPasswordReset) {
+		ctx.Error(services.ErrEmailNotVerified)
 		return
 	}
 
-	err = ac.UserService.StoreVerificationData(ctx, user.ID, user.Email, code)
+	err = ac.UserService.RequestPasswordReset(ctx, user)
 	if err != nil {
 		ctx.Error(err)
 		return
@@ -249,13 +460,7 @@ func (ac *AuthHandler) RefreshToken(ctx *gin.Context) {
 		return
 	}
 
-	err = ac.UserService.CreateSession(ctx, refreshPayload, refreshToken)
-	if err != nil {
-		ctx.Error(err)
-		return
-	}
-
-	err = ac.UserService.RevokeRefreshToken(ctx, session.RefreshToken)
+	err = ac.UserService.RotateSession(ctx, session, refreshPayload, refreshToken)
 	if err != nil {
 		ctx.Error(err)
 		return
@@ -272,3 +477,149 @@ func (ac *AuthHandler) RefreshToken(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, response)
 }
+
+// @Summary		Log out
+// @Description	Revoke the session that owns the provided refresh token.
+// @Tags			Auth
+// @Produce		json
+// @Param			refresh	header		string			true	"refresh token"
+// @Success		200		{object}	entity.Response	"Logged out successfully"
+// @Failure		400		{object}	entity.Response	"Bad Request"
+// @Failure		401		{object}	entity.Response	"Unauthorized"
+// @Failure		500		{object}	entity.Response	"Internal Server Error"
+// @Router			/auth/logout [post]
+func (ac *AuthHandler) Logout(ctx *gin.Context) {
+	session, err := ac.currentSession(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = ac.UserService.RevokeSession(ctx, session.UserID, session.ID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := entity.Response{
+		Status:  http.StatusOK,
+		Message: "Logged out successfully",
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// @Summary		Log out everywhere
+// @Description	Revoke every session of the user that owns the provided refresh token.
+// @Tags			Auth
+// @Produce		json
+// @Param			refresh	header		string			true	"refresh token"
+// @Success		200		{object}	entity.Response	"Logged out of all sessions successfully"
+// @Failure		400		{object}	entity.Response	"Bad Request"
+// @Failure		401		{object}	entity.Response	"Unauthorized"
+// @Failure		500		{object}	entity.Response	"Internal Server Error"
+// @Router			/auth/logout-all [post]
+func (ac *AuthHandler) LogoutAll(ctx *gin.Context) {
+	session, err := ac.currentSession(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = ac.UserService.RevokeAllSessions(ctx, session.UserID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := entity.Response{
+		Status:  http.StatusOK,
+		Message: "Logged out of all sessions successfully",
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// currentSession resolves the active session from the refresh header
+func (ac *AuthHandler) currentSession(ctx *gin.Context) (*entity.Session, error) {
+	refreshToken := ctx.Request.Header.Get("refresh")
+	if refreshToken == "" {
+		return nil, typesystem.BadRequest
+	}
+
+	refreshPayload, err := ac.UserService.VerifyToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return ac.UserService.GetSession(ctx, refreshPayload, refreshToken)
+}
+
+// @Summary		Verify email address
+// @Description	Confirm the email address of an account with the token sent on signup.
+// @Tags			Auth
+// @Produce		json
+// @Param			token	path		string			true	"verification token"
+// @Success		200		{object}	entity.Response	"Email verified successfully"
+// @Failure		401		{object}	entity.Response	"Token invalid"
+// @Failure		500		{object}	entity.Response	"Internal Server Error"
+// @Router			/auth/verify-email/{token} [post]
+func (ac *AuthHandler) VerifyEmail(ctx *gin.Context) {
+	err := ac.UserService.VerifyEmail(ctx, ctx.Param("token"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := entity.Response{
+		Status:  http.StatusOK,
+		Message: "Email verified successfully",
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// @Summary		Resend the verification email
+// @Description	Send a new verification link. The response is the same whether or not the account exists.
+// @Tags			Auth
+// @Accept			json
+// @Produce		json
+// @Param			request	body		entity.ResendVerificationRequest	true	"User's email"
+// @Success		200		{object}	entity.Response						"Verification email sent"
+// @Failure		400		{object}	entity.Response						"Bad Request"
+// @Failure		500		{object}	entity.Response						"Internal Server Error"
+// @Router			/auth/resend-verification [post]
+func (ac *AuthHandler) ResendVerification(ctx *gin.Context) {
+	var payload entity.ResendVerificationRequest
+
+	err := ctx.ShouldBindJSON(&payload)
+	if err != nil {
+		ctx.Error(typesystem.BadRequest)
+		return
+	}
+
+	response := entity.Response{
+		Status:  http.StatusOK,
+		Message: "If the account exists and is not verified, a verification email has been sent",
+	}
+
+	user, err := ac.UserService.GetUserByEmail(ctx, payload.Email)
+	if err != nil {
+		if err == typesystem.Unauthorized {
+			ctx.JSON(http.StatusOK, response)
+			return
+		}
+		ctx.Error(err)
+		return
+	}
+
+	if !user.EmailVerified {
+		err = ac.UserService.RequestEmailVerification(ctx, user)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
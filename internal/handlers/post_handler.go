@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
 
 	"github.com/Caixetadev/snippet/config"
 	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
 	"github.com/Caixetadev/snippet/pkg/typesystem"
 	"github.com/google/uuid"
 
@@ -15,11 +20,51 @@ import (
 type PostService interface {
 	Create(ctx context.Context, post *entity.PostInput) error
 	GetPosts(ctx context.Context, id uuid.UUID, page string) ([]*entity.PostOutput, *entity.PaginationInfo, error)
-	GetAllPublics(ctx context.Context, page string) ([]*entity.PostOutput, *entity.PaginationInfo, error)
-	DeletePost(ctx context.Context, id string, userID uuid.UUID) error
-	UpdatePost(ctx context.Context, post *entity.PostUpdateInput, userID uuid.UUID, id string) error
-	SearchPost(ctx context.Context, query string, page string) ([]*entity.PostOutput, *entity.PaginationInfo, error)
+	GetAllPublics(ctx context.Context, page string, language string) ([]*entity.PostOutput, *entity.PaginationInfo, error)
+	DeletePost(ctx context.Context, id string, userID uuid.UUID, managementToken string) error
+	UpdatePost(ctx context.Context, post *entity.PostUpdateInput, userID uuid.UUID, id string, managementToken string) error
+	ClaimPost(ctx context.Context, id string, userID uuid.UUID, managementToken string) error
+	SearchPost(ctx context.Context, query string, page string, language string) ([]*entity.PostOutput, *entity.PaginationInfo, error)
 	GetPost(ctx context.Context, id string, userID string, password string) (*entity.PostOutput, error)
+	GetRevisions(ctx context.Context, id string, userID string, password string) ([]*entity.PostRevision, error)
+	GetRevision(ctx context.Context, id string, revision string, userID string, password string) (*entity.PostRevision, error)
+	DiffRevisions(ctx context.Context, id string, from string, to string, userID string, password string) (*entity.PostDiffOutput, error)
+	Fork(ctx context.Context, id string, userID string, password string, input *entity.ForkPostInput) (*entity.PostOutput, error)
+	RenderPost(ctx context.Context, id string, userID string, password string) (string, error)
+	GetPostFile(ctx context.Context, id string, filename string, userID string, password string) (*entity.PostFile, error)
+	ArchivePost(ctx context.Context, id string, format string, userID string, password string) (*entity.PostArchive, error)
+}
+
+// postPasswordHeader carries the password of a protected post on GET requests
+const postPasswordHeader = "X-Post-Password"
+
+// postPassword reads the post password from the header or the password query parameter
+func postPassword(ctx *gin.Context) string {
+	if password := ctx.GetHeader(postPasswordHeader); password != "" {
+		return password
+	}
+
+	return ctx.Query("password")
+}
+
+// managementTokenHeader carries the token that manages an anonymous post
+const managementTokenHeader = "X-Management-Token"
+
+// postManager returns the signed in user, or uuid.Nil and the management token for
+// anonymous callers that present one
+func postManager(ctx *gin.Context) (uuid.UUID, string, error) {
+	managementToken := ctx.GetHeader(managementTokenHeader)
+
+	id, err := uuid.Parse(ctx.GetString("x-user-id"))
+	if err != nil {
+		if managementToken == "" {
+			return uuid.Nil, "", typesystem.Unauthorized
+		}
+
+		return uuid.Nil, managementToken, nil
+	}
+
+	return id, managementToken, nil
 }
 
 type PostHandler struct {
@@ -27,6 +72,31 @@ type PostHandler struct {
 	Env         *config.Config
 }
 
+// postRequestOverhead leaves room in a post request for the JSON around its bodies
+const postRequestOverhead = 64 << 10
+
+// bindPost binds the JSON body of a request that creates or updates a post. The request
+// is cut off once it is larger than the bodies of a post can be, escaping included,
+// instead of being read whole: the exact limit is checked by the service.
+func (ps *PostHandler) bindPost(ctx *gin.Context, payload any) error {
+	if ps.Env.ContentMaxPostSize > 0 {
+		limit := 2*int64(ps.Env.ContentMaxPostSize) + postRequestOverhead
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, limit)
+	}
+
+	err := ctx.ShouldBindJSON(payload)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return services.ErrContentTooLarge
+		}
+
+		return typesystem.BadRequest
+	}
+
+	return nil
+}
+
 // @Summary				Create a post
 // @Schemes
 // @Description	create a post on the platform
@@ -40,14 +110,20 @@ func (ps *PostHandler) Post(ctx *gin.Context) {
 	var payload entity.PostInput
 	userID := ctx.GetString("x-user-id")
 
-	err := ctx.ShouldBindJSON(&payload)
+	err := ps.bindPost(ctx, &payload)
 	if err != nil {
-		ctx.Error(typesystem.BadRequest)
+		ctx.Error(err)
 		return
 	}
 
 	payload.UserID = &userID
 
+	err = ps.checkUnverified(ctx, payload.Visibility)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
 	err = ps.PostService.Create(ctx, &payload)
 	if err != nil {
 		ctx.Error(err)
@@ -57,6 +133,10 @@ func (ps *PostHandler) Post(ctx *gin.Context) {
 	response := entity.Response{
 		Status:  http.StatusCreated,
 		Message: "Post created successfully",
+		Data: entity.PostCreateOutput{
+			ID:              payload.ID,
+			ManagementToken: payload.ManagementToken,
+		},
 	}
 
 	ctx.JSON(http.StatusCreated, response)
@@ -97,10 +177,21 @@ func (ps *PostHandler) GetPosts(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, response)
 }
 
+// @Summary		Get all public posts
+// @Schemes		http
+// @Description	Get all public posts on the platform, optionally filtered by language
+// @Tags			Post
+// @Accept			json
+// @Produce		json
+// @Param			page		query		int				true	"Page"
+// @Param			language	query		string			false	"Language"
+// @Success		200			{object}	entity.Response	"Posts retrieved successfully"
+// @Router			/post/all [get]
 func (ps *PostHandler) GetAllPublics(ctx *gin.Context) {
 	pageStr := ctx.Query("page")
+	language := ctx.Query("language")
 
-	posts, paginationInfo, err := ps.PostService.GetAllPublics(ctx, pageStr)
+	posts, paginationInfo, err := ps.PostService.GetAllPublics(ctx, pageStr, language)
 	if err != nil {
 		ctx.Error(err)
 		return
@@ -118,25 +209,25 @@ func (ps *PostHandler) GetAllPublics(ctx *gin.Context) {
 
 // @Summary		Delete a post by ID
 // @Schemes		http
-// @Description	Delete a post belonging to the logged-in user on the platform
+// @Description	Delete a post belonging to the logged-in user, or an anonymous post with its management token
 // @Tags			Post
 // @Accept			json
 // @Produce		json
 // @Security		BearerAuth
-// @Param			id	path		string			true	"Post ID"
-// @Success		200	{object}	entity.Response	"Post deleted successfully"
+// @Param			id					path		string			true	"Post ID"
+// @Param			X-Management-Token	header		string			false	"Management token of an anonymous post"
+// @Success		200					{object}	entity.Response	"Post deleted successfully"
 // @Router			/post/{id} [delete]
 func (ps *PostHandler) DeletePost(ctx *gin.Context) {
-	userID := ctx.GetString("x-user-id")
 	postID := ctx.Param("id")
 
-	id, err := uuid.Parse(userID)
+	id, managementToken, err := postManager(ctx)
 	if err != nil {
-		ctx.Error(typesystem.Unauthorized)
+		ctx.Error(err)
 		return
 	}
 
-	err = ps.PostService.DeletePost(ctx, postID, id)
+	err = ps.PostService.DeletePost(ctx, postID, id, managementToken)
 	if err != nil {
 		ctx.Error(err)
 		return
@@ -150,34 +241,34 @@ func (ps *PostHandler) DeletePost(ctx *gin.Context) {
 
 // @Summary		Update a post by ID
 // @Schemes		http
-// @Description	Update a post belonging to the logged-in user on the platform
+// @Description	Update a post belonging to the logged-in user, or an anonymous post with its management token
 // @Tags			Post
 // @Accept			json
 // @Produce		json
 // @Security		BearerAuth
-// @Param			id		path		string					true	"Post ID"
-// @Param			request	body		entity.PostUpdateInput	true	"Post"
-// @Success		200		{object}	entity.Response			"Post updated successfully"
+// @Param			id					path		string					true	"Post ID"
+// @Param			X-Management-Token	header		string					false	"Management token of an anonymous post"
+// @Param			request				body		entity.PostUpdateInput	true	"Post"
+// @Success		200					{object}	entity.Response			"Post updated successfully"
 // @Router			/post/{id} [patch]
 func (ps *PostHandler) UpdatePost(ctx *gin.Context) {
 	var payload entity.PostUpdateInput
 
-	err := ctx.ShouldBindJSON(&payload)
+	err := ps.bindPost(ctx, &payload)
 	if err != nil {
-		ctx.Error(typesystem.BadRequest)
+		ctx.Error(err)
 		return
 	}
 
-	userID := ctx.GetString("x-user-id")
 	postID := ctx.Param("id")
 
-	id, err := uuid.Parse(userID)
+	id, managementToken, err := postManager(ctx)
 	if err != nil {
-		ctx.Error(typesystem.Unauthorized)
+		ctx.Error(err)
 		return
 	}
 
-	err = ps.PostService.UpdatePost(ctx, &payload, id, postID)
+	err = ps.PostService.UpdatePost(ctx, &payload, id, postID, managementToken)
 	if err != nil {
 		ctx.Error(err)
 		return
@@ -191,20 +282,54 @@ func (ps *PostHandler) UpdatePost(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, response)
 }
 
+// @Summary		Claim an anonymous post
+// @Schemes		http
+// @Description	Move an anonymous post into the account of the logged-in user. Its management token stops working.
+// @Tags			Post
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id					path		string			true	"Post ID"
+// @Param			X-Management-Token	header		string			true	"Management token of the post"
+// @Success		200					{object}	entity.Response	"Post claimed successfully"
+// @Failure		401					{object}	typesystem.Http	"Unauthorized"
+// @Failure		403					{object}	typesystem.Http	"Forbidden"
+// @Failure		409					{object}	typesystem.Http	"Post already belongs to an account"
+// @Router			/post/{id}/claim [post]
+func (ps *PostHandler) ClaimPost(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.GetString("x-user-id"))
+	if err != nil {
+		ctx.Error(typesystem.Unauthorized)
+		return
+	}
+
+	err = ps.PostService.ClaimPost(ctx, ctx.Param("id"), id, ctx.GetHeader(managementTokenHeader))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entity.Response{
+		Status:  http.StatusOK,
+		Message: "Post claimed successfully",
+	})
+}
+
 // @Summary		Search a post
 // @Schemes		http
 // @Description	Search a post on the platform
 // @Tags			Post
 // @Accept			json
 // @Produce		json
-// @Param			q	query		string			true	"Query"
-// @Success		200	{object}	entity.Response	"Post updated successfully"
+// @Param			q			query		string			true	"Query"
+// @Param			language	query		string			false	"Language"
+// @Success		200			{object}	entity.Response	"Post updated successfully"
 // @Router			/post/search   [get]
 func (ps *PostHandler) SearchPost(ctx *gin.Context) {
 	query := ctx.Query("q")
 	page := ctx.Query("page")
+	language := ctx.Query("language")
 
-	post, paginationInfo, err := ps.PostService.SearchPost(ctx, query, page)
+	post, paginationInfo, err := ps.PostService.SearchPost(ctx, query, page, language)
 	if err != nil {
 		ctx.Error(err)
 		return
@@ -263,3 +388,281 @@ func (ps *PostHandler) GetPost(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, response)
 }
+
+// @Summary		List revisions of a post
+// @Schemes		http
+// @Description	List every stored revision of a post, newest first
+// @Tags			Post
+// @Accept			json
+// @Produce		json
+// @Param			id				path		string			true	"Post ID"
+// @Param			X-Post-Password	header		string			false	"Post password"
+// @Success		200				{object}	entity.Response	"Revisions retrieved successfully"
+// @Failure		401				{object}	typesystem.Http	"Unauthorized"
+// @Failure		404				{object}	typesystem.Http	"Post not found"
+// @Router			/post/{id}/revisions [get]
+func (ps *PostHandler) GetRevisions(ctx *gin.Context) {
+	userID := ctx.GetString("x-user-id")
+	id := ctx.Param("id")
+
+	revisions, err := ps.PostService.GetRevisions(ctx, id, userID, postPassword(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := entity.Response{
+		Status:  http.StatusOK,
+		Message: "Revisions retrieved successfully",
+		Data:    revisions,
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// @Summary		Get a revision of a post
+// @Schemes		http
+// @Description	Get the title and content of a post as they were at the given revision
+// @Tags			Post
+// @Accept			json
+// @Produce		json
+// @Param			id				path		string			true	"Post ID"
+// @Param			n				path		int				true	"Revision number"
+// @Param			X-Post-Password	header		string			false	"Post password"
+// @Success		200				{object}	entity.Response	"Revision retrieved successfully"
+// @Failure		400				{object}	typesystem.Http	"Bad Request"
+// @Failure		404				{object}	typesystem.Http	"Revision not found"
+// @Router			/post/{id}/revisions/{n} [get]
+func (ps *PostHandler) GetRevision(ctx *gin.Context) {
+	userID := ctx.GetString("x-user-id")
+	id := ctx.Param("id")
+	revisionStr := ctx.Param("n")
+
+	revision, err := ps.PostService.GetRevision(ctx, id, revisionStr, userID, postPassword(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := entity.Response{
+		Status:  http.StatusOK,
+		Message: "Revision retrieved successfully",
+		Data:    revision,
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// @Summary		Diff two revisions of a post
+// @Schemes		http
+// @Description	Get a unified diff between two revisions of a post. Defaults to the latest revision against its predecessor.
+// @Tags			Post
+// @Accept			json
+// @Produce		json
+// @Param			id				path		string			true	"Post ID"
+// @Param			from			query		int				false	"Base revision"
+// @Param			to				query		int				false	"Target revision"
+// @Param			X-Post-Password	header		string			false	"Post password"
+// @Success		200				{object}	entity.Response	"Diff generated successfully"
+// @Failure		400				{object}	typesystem.Http	"Bad Request"
+// @Failure		404				{object}	typesystem.Http	"Revision not found"
+// @Router			/post/{id}/diff [get]
+func (ps *PostHandler) DiffRevisions(ctx *gin.Context) {
+	userID := ctx.GetString("x-user-id")
+	id := ctx.Param("id")
+
+	diff, err := ps.PostService.DiffRevisions(ctx, id, ctx.Query("from"), ctx.Query("to"), userID, postPassword(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := entity.Response{
+		Status:  http.StatusOK,
+		Message: "Diff generated successfully",
+		Data:    diff,
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// @Summary		Fork a post
+// @Schemes		http
+// @Description	Copy a post the caller can read into a new post owned by the caller
+// @Tags			Post
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id				path		string					true	"Post ID"
+// @Param			X-Post-Password	header		string					false	"Source post password"
+// @Param			request			body		entity.ForkPostInput	false	"Fork options"
+// @Success		201				{object}	entity.Response			"Post forked successfully"
+// @Failure		401				{object}	typesystem.Http			"Unauthorized"
+// @Failure		403				{object}	typesystem.Http			"Forbidden"
+// @Failure		404				{object}	typesystem.Http			"Post not found"
+// @Router			/post/{id}/fork [post]
+func (ps *PostHandler) Fork(ctx *gin.Context) {
+	var payload entity.ForkPostInput
+
+	if ctx.Request.ContentLength != 0 {
+		err := ctx.ShouldBindJSON(&payload)
+		if err != nil {
+			ctx.Error(typesystem.BadRequest)
+			return
+		}
+	}
+
+	err := ps.checkUnverified(ctx, payload.Visibility)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetString("x-user-id")
+	id := ctx.Param("id")
+
+	post, err := ps.PostService.Fork(ctx, id, userID, postPassword(ctx), &payload)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := entity.Response{
+		Status:  http.StatusCreated,
+		Message: "Post forked successfully",
+		Data:    post,
+	}
+
+	ctx.JSON(http.StatusCreated, response)
+}
+
+// @Summary		Get raw post content
+// @Schemes		http
+// @Description	Get only the content of a post as plain text, applying the same rules as getting the post
+// @Tags			Post
+// @Produce		plain
+// @Param			id				path		string			true	"Post ID"
+// @Param			X-Post-Password	header		string			false	"Post password"
+// @Param			password		query		string			false	"Post password"
+// @Success		200				{string}	string			"Post content"
+// @Failure		401				{object}	typesystem.Http	"Unauthorized"
+// @Failure		404				{object}	typesystem.Http	"Post not found"
+// @Router			/raw/{id} [get]
+func (ps *PostHandler) GetRawPost(ctx *gin.Context) {
+	userID := ctx.GetString("x-user-id")
+	id := ctx.Param("id")
+
+	post, err := ps.PostService.GetPost(ctx, id, userID, postPassword(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	writeRaw(ctx, fmt.Sprintf("%s.txt", post.ID), post.Content)
+}
+
+// @Summary		Get raw file content
+// @Schemes		http
+// @Description	Get the content of a single file of a multi-file post as plain text
+// @Tags			Post
+// @Produce		plain
+// @Param			id				path		string			true	"Post ID"
+// @Param			filename		path		string			true	"File name"
+// @Param			X-Post-Password	header		string			false	"Post password"
+// @Param			password		query		string			false	"Post password"
+// @Success		200				{string}	string			"File content"
+// @Failure		401				{object}	typesystem.Http	"Unauthorized"
+// @Failure		404				{object}	typesystem.Http	"File not found"
+// @Router			/raw/{id}/{filename} [get]
+func (ps *PostHandler) GetRawPostFile(ctx *gin.Context) {
+	userID := ctx.GetString("x-user-id")
+	id := ctx.Param("id")
+	filename := ctx.Param("filename")
+
+	file, err := ps.PostService.GetPostFile(ctx, id, filename, userID, postPassword(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	writeRaw(ctx, file.Filename, file.Content)
+}
+
+// @Summary		Download post archive
+// @Schemes		http
+// @Description	Download every file of a post bundled as a zip or tar archive
+// @Tags			Post
+// @Produce		application/zip,application/x-tar
+// @Param			id				path		string			true	"Post ID"
+// @Param			format			query		string			false	"Archive format (zip or tar)"
+// @Param			X-Post-Password	header		string			false	"Post password"
+// @Success		200				{file}		file			"Archive"
+// @Failure		400				{object}	typesystem.Http	"Bad Request"
+// @Failure		404				{object}	typesystem.Http	"Post not found"
+// @Router			/post/{id}/archive [get]
+func (ps *PostHandler) GetPostArchive(ctx *gin.Context) {
+	userID := ctx.GetString("x-user-id")
+	id := ctx.Param("id")
+
+	archive, err := ps.PostService.ArchivePost(ctx, id, ctx.Query("format"), userID, postPassword(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Header("Content-Length", strconv.Itoa(len(archive.Data)))
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": archive.Filename}))
+
+	ctx.Data(http.StatusOK, archive.ContentType, archive.Data)
+}
+
+// checkUnverified applies the configured restrictions on users who have not verified
+// their email. It relies on middleware.EmailVerification running first.
+func (ps *PostHandler) checkUnverified(ctx *gin.Context, visibility entity.Visibility) error {
+	if ctx.GetString("x-user-id") == "" || ctx.GetBool("x-email-verified") {
+		return nil
+	}
+
+	if ps.Env.RestrictsUnverified(entity.RestrictCreatePosts) {
+		return services.ErrEmailNotVerified
+	}
+
+	if visibility == entity.Private && ps.Env.RestrictsUnverified(entity.RestrictPrivatePosts) {
+		return services.ErrEmailNotVerified
+	}
+
+	return nil
+}
+
+// writeRaw writes content as an inline plain text attachment named filename
+func writeRaw(ctx *gin.Context, filename string, content string) {
+	ctx.Header("Content-Length", strconv.Itoa(len(content)))
+	ctx.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": filename}))
+	ctx.Header("X-Content-Type-Options", "nosniff")
+
+	ctx.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(content))
+}
+
+// @Summary		Get post as highlighted HTML
+// @Schemes		http
+// @Description	Render the content of a post as a syntax highlighted HTML document
+// @Tags			Post
+// @Produce		html
+// @Param			id				path		string			true	"Post ID"
+// @Param			X-Post-Password	header		string			false	"Post password"
+// @Success		200				{string}	string			"Highlighted HTML"
+// @Failure		401				{object}	typesystem.Http	"Unauthorized"
+// @Failure		404				{object}	typesystem.Http	"Post not found"
+// @Router			/post/{id}/html [get]
+func (ps *PostHandler) GetPostHTML(ctx *gin.Context) {
+	userID := ctx.GetString("x-user-id")
+	id := ctx.Param("id")
+
+	rendered, err := ps.PostService.RenderPost(ctx, id, userID, postPassword(ctx))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(rendered))
+}
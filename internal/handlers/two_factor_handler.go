@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Caixetadev/snippet/config"
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type TwoFactorService interface {
+	Setup(ctx context.Context, user *entity.User) (*entity.TwoFactorSetupResponse, error)
+	Confirm(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	CreateChallenge(ctx context.Context, userID uuid.UUID) (string, error)
+	VerifyChallenge(ctx context.Context, token string, code string) (uuid.UUID, error)
+}
+
+type TwoFactorHandler struct {
+	UserService      entity.UserService
+	TwoFactorService TwoFactorService
+	Env              *config.Config
+}
+
+// @Summary		Set up two-factor authentication
+// @Schemes		http
+// @Description	Generate a TOTP secret and its otpauth URI. Two-factor authentication is enabled once a code is confirmed.
+// @Tags			User
+// @Produce		json
+// @Security		BearerAuth
+// @Success		200	{object}	entity.Response	"Two-factor setup started"
+// @Failure		401	{object}	typesystem.Http	"Unauthorized"
+// @Failure		409	{object}	typesystem.Http	"Two-factor authentication is already enabled"
+// @Router			/user/2fa/setup [post]
+func (th *TwoFactorHandler) Setup(ctx *gin.Context) {
+	userID, err := uuid.Parse(ctx.GetString("x-user-id"))
+	if err != nil {
+		ctx.Error(typesystem.Unauthorized)
+		return
+	}
+
+	user, err := th.UserService.GetUserByID(ctx, userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	setup, err := th.TwoFactorService.Setup(ctx, user)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := entity.Response{
+		Status:  http.StatusOK,
+		Message: "Two-factor setup started",
+		Data:    setup,
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// @Summary		Confirm two-factor authentication
+// @Schemes		http
+// @Description	Enable two-factor authentication with a code from the authenticator app. Returns recovery codes, which are only shown once.
+// @Tags			User
+// @Accept			json
+// @Produce		json
+// @Security		BearerAuth
+// @Param			request	body		entity.TwoFactorConfirmRequest	true	"Code"
+// @Success		200		{object}	entity.Response					"Two-factor authentication enabled"
+// @Failure		400		{object}	typesystem.Http					"Bad Request"
+// @Failure		401		{object}	typesystem.Http					"Invalid code"
+// @Router			/user/2fa/confirm [post]
+func (th *TwoFactorHandler) Confirm(ctx *gin.Context) {
+	var payload entity.TwoFactorConfirmRequest
+
+	err := ctx.ShouldBindJSON(&payload)
+	if err != nil {
+		ctx.Error(typesystem.BadRequest)
+		return
+	}
+
+	userID, err := uuid.Parse(ctx.GetString("x-user-id"))
+	if err != nil {
+		ctx.Error(typesystem.Unauthorized)
+		return
+	}
+
+	codes, err := th.TwoFactorService.Confirm(ctx, userID, payload.Code)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := entity.Response{
+		Status:  http.StatusOK,
+		Message: "Two-factor authentication enabled",
+		Data:    entity.TwoFactorConfirmResponse{RecoveryCodes: codes},
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"net/http"
+
 	"github.com/Caixetadev/snippet/config"
 	"github.com/Caixetadev/snippet/internal/entity"
 	"github.com/Caixetadev/snippet/pkg/typesystem"
@@ -52,3 +54,72 @@ func (uc *UserHandler) GetAuthenticatedUser(ctx *gin.Context) {
 
 	ctx.JSON(200, response)
 }
+
+// @Summary		List sessions
+// @Schemes		http
+// @Description	List the active sessions of the authenticated user
+// @Tags			User
+// @Produce		json
+// @Security		BearerAuth
+// @Success		200	{object}	entity.Response	"Sessions retrieved successfully"
+// @Failure		401	{object}	typesystem.Http	"Unauthorized"
+// @Router			/user/sessions [get]
+func (uc *UserHandler) GetSessions(ctx *gin.Context) {
+	userID, err := uuid.Parse(ctx.GetString("x-user-id"))
+	if err != nil {
+		ctx.Error(typesystem.Unauthorized)
+		return
+	}
+
+	sessions, err := uc.UserService.ListSessions(ctx, userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := entity.Response{
+		Data:    sessions,
+		Status:  http.StatusOK,
+		Message: "Sessions retrieved successfully",
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// @Summary		Revoke a session
+// @Schemes		http
+// @Description	Revoke one of the authenticated user's sessions. Its refresh token can no longer be used.
+// @Tags			User
+// @Produce		json
+// @Security		BearerAuth
+// @Param			id	path		string			true	"Session ID"
+// @Success		200	{object}	entity.Response	"Session revoked successfully"
+// @Failure		401	{object}	typesystem.Http	"Unauthorized"
+// @Failure		404	{object}	typesystem.Http	"Session not found"
+// @Router			/user/sessions/{id} [delete]
+func (uc *UserHandler) RevokeSession(ctx *gin.Context) {
+	userID, err := uuid.Parse(ctx.GetString("x-user-id"))
+	if err != nil {
+		ctx.Error(typesystem.Unauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(typesystem.NotFound)
+		return
+	}
+
+	err = uc.UserService.RevokeSession(ctx, userID, id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := entity.Response{
+		Status:  http.StatusOK,
+		Message: "Session revoked successfully",
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
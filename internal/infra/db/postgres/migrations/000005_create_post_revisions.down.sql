@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS public.post_revisions;
+
+ALTER TABLE public.posts DROP COLUMN IF EXISTS revision;
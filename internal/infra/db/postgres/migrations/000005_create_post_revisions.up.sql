@@ -0,0 +1,14 @@
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS public.post_revisions (
+    post_id varchar(8) NOT NULL REFERENCES public.posts(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title varchar(255) NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, revision)
+);
+
+INSERT INTO public.post_revisions (post_id, revision, title, content, created_at)
+SELECT id, revision, title, content, created_at FROM public.posts
+ON CONFLICT DO NOTHING;
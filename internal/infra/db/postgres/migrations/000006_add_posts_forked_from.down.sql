@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_forked_from;
+
+ALTER TABLE public.posts DROP COLUMN IF EXISTS forked_from;
@@ -0,0 +1,3 @@
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS forked_from varchar(8) REFERENCES public.posts(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_forked_from ON public.posts(forked_from);
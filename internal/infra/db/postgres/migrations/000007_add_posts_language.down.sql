@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_language;
+
+ALTER TABLE public.posts DROP COLUMN IF EXISTS language;
@@ -0,0 +1,3 @@
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS language varchar(64) NOT NULL DEFAULT 'text';
+
+CREATE INDEX IF NOT EXISTS idx_posts_language ON public.posts(language);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_posts_content_trgm;
+DROP INDEX IF EXISTS idx_posts_title_trgm;
+DROP INDEX IF EXISTS idx_posts_search_vector;
+
+ALTER TABLE public.posts DROP COLUMN IF EXISTS search_vector;
+
+CREATE INDEX IF NOT EXISTS idx_posts_title ON public.posts(title);
+CREATE INDEX IF NOT EXISTS idx_posts_content ON public.posts(content);
@@ -0,0 +1,14 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('simple', coalesce(content, '')), 'B')
+    ) STORED;
+
+DROP INDEX IF EXISTS idx_posts_title;
+DROP INDEX IF EXISTS idx_posts_content;
+
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON public.posts USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_posts_title_trgm ON public.posts USING GIN (title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_posts_content_trgm ON public.posts USING GIN (content gin_trgm_ops);
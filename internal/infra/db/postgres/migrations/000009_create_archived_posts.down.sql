@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_expiration_at;
+
+DROP TABLE IF EXISTS public.archived_posts;
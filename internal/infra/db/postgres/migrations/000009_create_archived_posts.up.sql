@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS public.archived_posts (
+    id varchar(8) PRIMARY KEY NOT NULL,
+    user_id UUID,
+    title varchar(255) NOT NULL,
+    content TEXT NOT NULL,
+    language varchar(64) NOT NULL DEFAULT 'text',
+    created_at TIMESTAMP,
+    expiration_at TIMESTAMP,
+    archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_posts_expiration_at ON public.posts(expiration_at);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS public.post_files (
+    post_id varchar(8) NOT NULL REFERENCES public.posts(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    filename varchar(255) NOT NULL,
+    language varchar(64) NOT NULL DEFAULT 'text',
+    content TEXT NOT NULL,
+    PRIMARY KEY (post_id, filename)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_files_post_id_position ON public.post_files(post_id, position);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_sessions_user_id;
+
+ALTER TABLE sessions ALTER COLUMN client_ip TYPE VARCHAR(15);
+ALTER TABLE sessions DROP COLUMN IF EXISTS created_at;
+ALTER TABLE sessions DROP COLUMN IF EXISTS user_id;
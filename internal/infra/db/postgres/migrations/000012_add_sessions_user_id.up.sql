@@ -0,0 +1,9 @@
+-- Sessions created before this migration cannot be attributed to a user, so they are dropped
+-- and their owners have to sign in again.
+DELETE FROM sessions;
+
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE sessions ALTER COLUMN client_ip TYPE VARCHAR(45);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
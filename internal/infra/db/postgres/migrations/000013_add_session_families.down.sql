@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS security_events;
+
+DROP INDEX IF EXISTS idx_sessions_family_id;
+
+ALTER TABLE sessions DROP COLUMN IF EXISTS family_id;
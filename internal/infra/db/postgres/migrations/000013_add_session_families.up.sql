@@ -0,0 +1,20 @@
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS family_id UUID;
+
+UPDATE sessions SET family_id = id WHERE family_id IS NULL;
+
+ALTER TABLE sessions ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_family_id ON sessions(family_id);
+
+CREATE TABLE IF NOT EXISTS security_events (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(64) NOT NULL,
+    session_id UUID,
+    family_id UUID,
+    user_agent VARCHAR(255),
+    client_ip VARCHAR(45),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_security_events_user_id ON security_events(user_id, created_at DESC);
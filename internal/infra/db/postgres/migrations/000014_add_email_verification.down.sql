@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS public.email_verifications;
+
+ALTER TABLE public.users DROP COLUMN IF EXISTS email_verified;
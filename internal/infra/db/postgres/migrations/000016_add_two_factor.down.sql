@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS public.signin_challenges;
+DROP TABLE IF EXISTS public.recovery_codes;
+
+ALTER TABLE public.users DROP COLUMN IF EXISTS totp_last_step;
+ALTER TABLE public.users DROP COLUMN IF EXISTS totp_enabled;
+ALTER TABLE public.users DROP COLUMN IF EXISTS totp_secret;
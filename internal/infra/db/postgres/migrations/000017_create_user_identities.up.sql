@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS public.user_identities (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_id_idx ON public.user_identities (user_id);
+
+CREATE TABLE IF NOT EXISTS public.oidc_states (
+    state_hash CHAR(64) PRIMARY KEY,
+    provider VARCHAR(64) NOT NULL,
+    nonce VARCHAR(255) NOT NULL,
+    code_verifier VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
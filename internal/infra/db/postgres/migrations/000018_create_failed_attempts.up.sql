@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS public.failed_attempts (
+    key VARCHAR(512) PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS failed_attempts_updated_at_idx ON public.failed_attempts (updated_at);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS public.rate_limit_buckets (
+    key VARCHAR(512) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_buckets_updated_at_idx ON public.rate_limit_buckets (updated_at);
@@ -0,0 +1,6 @@
+ALTER TABLE public.archived_posts DROP COLUMN IF EXISTS content_key;
+ALTER TABLE public.post_revisions DROP COLUMN IF EXISTS content_key;
+ALTER TABLE public.post_files DROP COLUMN IF EXISTS content_key;
+ALTER TABLE public.posts DROP COLUMN IF EXISTS content_key;
+
+DROP TABLE IF EXISTS public.content_blobs;
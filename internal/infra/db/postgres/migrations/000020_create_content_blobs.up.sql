@@ -0,0 +1,18 @@
+-- Bodies stored in the content store, keyed by the SHA-256 of their content. Rows that
+-- reference a blob keep only a preview in their content column; rows without a key
+-- still hold their whole body inline.
+CREATE TABLE IF NOT EXISTS public.content_blobs (
+    key CHAR(64) PRIMARY KEY,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS content_key CHAR(64) REFERENCES public.content_blobs(key);
+ALTER TABLE public.post_files ADD COLUMN IF NOT EXISTS content_key CHAR(64) REFERENCES public.content_blobs(key);
+ALTER TABLE public.post_revisions ADD COLUMN IF NOT EXISTS content_key CHAR(64) REFERENCES public.content_blobs(key);
+ALTER TABLE public.archived_posts ADD COLUMN IF NOT EXISTS content_key CHAR(64) REFERENCES public.content_blobs(key);
+
+CREATE INDEX IF NOT EXISTS idx_posts_content_key ON public.posts(content_key);
+CREATE INDEX IF NOT EXISTS idx_post_files_content_key ON public.post_files(content_key);
+CREATE INDEX IF NOT EXISTS idx_post_revisions_content_key ON public.post_revisions(content_key);
+CREATE INDEX IF NOT EXISTS idx_archived_posts_content_key ON public.archived_posts(content_key);
@@ -0,0 +1,2 @@
+ALTER TABLE public.content_blobs DROP COLUMN IF EXISTS stored_size;
+ALTER TABLE public.content_blobs DROP COLUMN IF EXISTS codec;
@@ -0,0 +1,8 @@
+-- codec is how the stored object is encoded, size stays the length of the original
+-- content and stored_size is the length of the object
+ALTER TABLE public.content_blobs ADD COLUMN IF NOT EXISTS codec VARCHAR(16) NOT NULL DEFAULT 'identity';
+ALTER TABLE public.content_blobs ADD COLUMN IF NOT EXISTS stored_size BIGINT;
+
+UPDATE public.content_blobs SET stored_size = size WHERE stored_size IS NULL;
+
+ALTER TABLE public.content_blobs ALTER COLUMN stored_size SET NOT NULL;
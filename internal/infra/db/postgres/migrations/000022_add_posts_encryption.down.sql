@@ -0,0 +1,5 @@
+ALTER TABLE public.archived_posts DROP COLUMN IF EXISTS data_key;
+ALTER TABLE public.archived_posts DROP COLUMN IF EXISTS encryption;
+
+ALTER TABLE public.posts DROP COLUMN IF EXISTS data_key;
+ALTER TABLE public.posts DROP COLUMN IF EXISTS encryption;
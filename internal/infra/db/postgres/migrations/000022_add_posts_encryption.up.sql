@@ -0,0 +1,7 @@
+-- encryption is how the bodies of a post are sealed: '' for plaintext, password or
+-- master_key. data_key is the wrapped key that seals them.
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS encryption VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS data_key BYTEA;
+
+ALTER TABLE public.archived_posts ADD COLUMN IF NOT EXISTS encryption VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE public.archived_posts ADD COLUMN IF NOT EXISTS data_key BYTEA;
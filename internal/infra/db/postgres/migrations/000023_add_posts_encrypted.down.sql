@@ -0,0 +1,2 @@
+ALTER TABLE public.archived_posts DROP COLUMN IF EXISTS encrypted;
+ALTER TABLE public.posts DROP COLUMN IF EXISTS encrypted;
@@ -0,0 +1,4 @@
+-- encrypted posts are encrypted by the client, content holds an envelope the server
+-- cannot read
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS encrypted BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE public.archived_posts ADD COLUMN IF NOT EXISTS encrypted BOOLEAN NOT NULL DEFAULT FALSE;
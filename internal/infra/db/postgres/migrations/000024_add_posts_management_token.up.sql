@@ -0,0 +1,3 @@
+-- management_token_hash is the SHA-256 digest of the token that lets the creator of an
+-- anonymous post edit, delete or claim it
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS management_token_hash CHAR(64);
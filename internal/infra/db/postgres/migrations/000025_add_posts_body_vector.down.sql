@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_posts_search_vector;
+
+ALTER TABLE public.posts DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE public.posts DROP COLUMN IF EXISTS body_vector;
+
+ALTER TABLE public.posts ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('simple', coalesce(content, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON public.posts USING GIN (search_vector);
@@ -0,0 +1,16 @@
+-- body_vector indexes the full body of a post, which the posts table only holds a
+-- preview of. It is filled by the application: posts stored before it existed are
+-- searched by their preview until migrate-content indexes them.
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS body_vector tsvector;
+
+DROP INDEX IF EXISTS idx_posts_search_vector;
+
+ALTER TABLE public.posts DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE public.posts ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+        setweight(coalesce(body_vector, to_tsvector('simple', coalesce(content, ''))), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON public.posts USING GIN (search_vector);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS public.archived_post_files;
@@ -0,0 +1,12 @@
+-- The files of archived posts, archived together with their post
+CREATE TABLE IF NOT EXISTS public.archived_post_files (
+    post_id varchar(8) NOT NULL REFERENCES public.archived_posts(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    filename varchar(255) NOT NULL,
+    language varchar(64) NOT NULL DEFAULT 'text',
+    content TEXT NOT NULL,
+    content_key CHAR(64) REFERENCES public.content_blobs(key),
+    PRIMARY KEY (post_id, filename)
+);
+
+CREATE INDEX IF NOT EXISTS idx_archived_post_files_content_key ON public.archived_post_files(content_key);
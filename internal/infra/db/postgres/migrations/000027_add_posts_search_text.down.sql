@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_posts_search_text_trgm;
+
+ALTER TABLE public.posts DROP COLUMN IF EXISTS search_text;
+
+CREATE INDEX IF NOT EXISTS idx_posts_content_trgm ON public.posts USING GIN (content gin_trgm_ops);
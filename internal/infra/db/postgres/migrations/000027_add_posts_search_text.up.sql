@@ -0,0 +1,9 @@
+-- search_text holds the part of the full body of a post that is indexed for search, so
+-- the trigram substring match of code tokens sees more than the preview. It is filled
+-- by the application with body_vector: posts without it are matched on their preview
+-- until migrate-content indexes them.
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS search_text TEXT;
+
+DROP INDEX IF EXISTS idx_posts_content_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_posts_search_text_trgm ON public.posts USING GIN ((coalesce(search_text, content)) gin_trgm_ops);
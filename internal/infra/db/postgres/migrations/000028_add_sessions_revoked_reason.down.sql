@@ -0,0 +1 @@
+ALTER TABLE public.sessions DROP COLUMN IF EXISTS revoked_reason;
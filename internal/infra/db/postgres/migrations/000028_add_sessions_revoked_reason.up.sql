@@ -0,0 +1,6 @@
+-- revoked_reason says why a session was revoked, so that only a rotated refresh token
+-- presented again counts as reuse. Sessions revoked before it existed may have been
+-- rotated, so they are treated as such.
+ALTER TABLE public.sessions ADD COLUMN IF NOT EXISTS revoked_reason varchar(16) NOT NULL DEFAULT '';
+
+UPDATE public.sessions SET revoked_reason = 'rotated' WHERE is_revoked;
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ services.AccessTokenRepository = (*accessTokenRepository)(nil)
+
+type accessTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAccessTokenRepository(db *pgxpool.Pool) *accessTokenRepository {
+	return &accessTokenRepository{db: db}
+}
+
+func (ar *accessTokenRepository) Insert(ctx context.Context, token *entity.PersonalAccessToken) error {
+	query := "INSERT INTO personal_access_tokens (id, user_id, name, token_hash, prefix, scopes, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+
+	_, err := ar.db.Exec(
+		ctx,
+		query,
+		token.ID,
+		token.UserID,
+		token.Name,
+		token.TokenHash,
+		token.Prefix,
+		token.Scopes,
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+
+	return err
+}
+
+func (ar *accessTokenRepository) FindAllByUser(ctx context.Context, userID uuid.UUID) ([]*entity.PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+		FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`
+
+	line, err := ar.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer line.Close()
+
+	tokens := []*entity.PersonalAccessToken{}
+
+	for line.Next() {
+		token := &entity.PersonalAccessToken{}
+		if err := line.Scan(&token.ID, &token.UserID, &token.Name, &token.Prefix, &token.Scopes, &token.ExpiresAt, &token.LastUsedAt, &token.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, line.Err()
+}
+
+func (ar *accessTokenRepository) Revoke(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	query := "UPDATE personal_access_tokens SET revoked_at = now() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL"
+
+	tag, err := ar.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Use looks up an active token by hash and records now as its last use
+func (ar *accessTokenRepository) Use(ctx context.Context, tokenHash string, now time.Time) (*entity.PersonalAccessToken, error) {
+	query := `
+		UPDATE personal_access_tokens
+		SET last_used_at = $2
+		WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > $2)
+		RETURNING id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+	`
+
+	var token entity.PersonalAccessToken
+
+	err := ar.db.QueryRow(ctx, query, tokenHash, now).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.Prefix,
+		&token.Scopes,
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
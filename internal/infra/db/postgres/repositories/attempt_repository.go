@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ services.AttemptStore = (*attemptRepository)(nil)
+
+type attemptRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAttemptRepository(db *pgxpool.Pool) *attemptRepository {
+	return &attemptRepository{db: db}
+}
+
+func (ar *attemptRepository) FindAttempts(ctx context.Context, key string) (*entity.FailedAttempts, error) {
+	query := "SELECT key, failures, locked_until, updated_at FROM failed_attempts WHERE key = $1"
+
+	attempts, err := scanAttempts(ar.db.QueryRow(ctx, query, key))
+	if err == pgx.ErrNoRows {
+		return &entity.FailedAttempts{Key: key}, nil
+	}
+
+	return attempts, err
+}
+
+// RecordFailure counts a failure in a single upsert, so concurrent failures are all
+// counted. The count starts over when the previous failure is older than window.
+func (ar *attemptRepository) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*entity.FailedAttempts, error) {
+	query := `
+		INSERT INTO failed_attempts (key, failures, updated_at) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN failed_attempts.updated_at < $3 THEN 1 ELSE failed_attempts.failures + 1 END,
+			updated_at = $2
+		RETURNING key, failures, locked_until, updated_at`
+
+	return scanAttempts(ar.db.QueryRow(ctx, query, key, now, now.Add(-window)))
+}
+
+func (ar *attemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	query := "UPDATE failed_attempts SET locked_until = GREATEST(COALESCE(locked_until, $2), $2) WHERE key = $1"
+
+	_, err := ar.db.Exec(ctx, query, key, until)
+
+	return err
+}
+
+func (ar *attemptRepository) ResetAttempts(ctx context.Context, key string) error {
+	_, err := ar.db.Exec(ctx, "DELETE FROM failed_attempts WHERE key = $1", key)
+
+	return err
+}
+
+func (ar *attemptRepository) PruneAttempts(ctx context.Context, before time.Time) (int, error) {
+	query := "DELETE FROM failed_attempts WHERE updated_at < $1 AND (locked_until IS NULL OR locked_until < $1)"
+
+	tag, err := ar.db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+func scanAttempts(row pgx.Row) (*entity.FailedAttempts, error) {
+	var attempts entity.FailedAttempts
+	var lockedUntil *time.Time
+
+	err := row.Scan(&attempts.Key, &attempts.Failures, &lockedUntil, &attempts.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if lockedUntil != nil {
+		attempts.LockedUntil = *lockedUntil
+	}
+
+	return &attempts, nil
+}
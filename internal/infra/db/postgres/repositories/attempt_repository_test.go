@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestRecordFailureConcurrent runs against a migrated database given by PG_URL
+func TestRecordFailureConcurrent(t *testing.T) {
+	url := os.Getenv("PG_URL")
+	if url == "" {
+		t.Skip("PG_URL not set")
+	}
+
+	db, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	repo := NewAttemptRepository(db)
+	key := "test:" + uuid.NewString()
+	now := time.Now()
+
+	defer repo.ResetAttempts(context.Background(), key)
+
+	failures := 20
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < failures; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := repo.RecordFailure(context.Background(), key, now, time.Hour)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	until := now.Add(time.Minute)
+
+	err = repo.Lock(context.Background(), key, until)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repo.Lock(context.Background(), key, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attempts, err := repo.FindAttempts(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts.Failures != failures {
+		t.Fatalf("counted %d failures, want %d", attempts.Failures, failures)
+	}
+
+	if !attempts.LockedUntil.Equal(until.Truncate(time.Microsecond)) {
+		t.Fatalf("locked until %s, want %s", attempts.LockedUntil, until)
+	}
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const insertIdentityQuery = "INSERT INTO user_identities (id, user_id, provider, subject, email, created_at) VALUES ($1, $2, $3, $4, $5, $6)"
+
+var _ services.IdentityRepository = (*identityRepository)(nil)
+
+type identityRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewIdentityRepository(db *pgxpool.Pool) *identityRepository {
+	return &identityRepository{db: db}
+}
+
+func (ir *identityRepository) CreateOIDCState(ctx context.Context, state *entity.OIDCState) error {
+	query := "INSERT INTO oidc_states (state_hash, provider, nonce, code_verifier, expires_at) VALUES ($1, $2, $3, $4, $5)"
+
+	_, err := ir.db.Exec(
+		ctx,
+		query,
+		state.StateHash,
+		state.Provider,
+		state.Nonce,
+		state.CodeVerifier,
+		state.ExpiresAt,
+	)
+
+	return err
+}
+
+// ConsumeOIDCState deletes and returns a state so that a callback can only be completed
+// once. It returns pgx.ErrNoRows when the state is unknown or has expired.
+func (ir *identityRepository) ConsumeOIDCState(ctx context.Context, stateHash string, now time.Time) (*entity.OIDCState, error) {
+	query := `
+		DELETE FROM oidc_states WHERE state_hash = $1
+		RETURNING state_hash, provider, nonce, code_verifier, expires_at`
+
+	var state entity.OIDCState
+
+	err := ir.db.QueryRow(ctx, query, stateHash).Scan(
+		&state.StateHash,
+		&state.Provider,
+		&state.Nonce,
+		&state.CodeVerifier,
+		&state.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if !state.ExpiresAt.After(now) {
+		return nil, pgx.ErrNoRows
+	}
+
+	return &state, nil
+}
+
+func (ir *identityRepository) FindUserByIdentity(ctx context.Context, provider, subject string) (*entity.User, error) {
+	query := `
+		SELECT u.id, u.name, u.email, u.email_verified, u.totp_enabled
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2`
+
+	var user entity.User
+
+	err := ir.db.QueryRow(ctx, query, provider, subject).Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.EmailVerified,
+		&user.TwoFactorEnabled,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (ir *identityRepository) LinkIdentity(ctx context.Context, identity *entity.Identity) error {
+	_, err := ir.db.Exec(ctx, insertIdentityQuery, identityArgs(identity)...)
+
+	return err
+}
+
+// CreateUserWithIdentity creates a user who signs in through an identity provider
+func (ir *identityRepository) CreateUserWithIdentity(ctx context.Context, user *entity.User, identity *entity.Identity) error {
+	tx, err := ir.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(
+		ctx,
+		"INSERT INTO users (id, name, email, password, email_verified) VALUES ($1, $2, $3, $4, $5)",
+		user.ID,
+		user.Name,
+		user.Email,
+		user.Password,
+		user.EmailVerified,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, insertIdentityQuery, identityArgs(identity)...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func identityArgs(identity *entity.Identity) []any {
+	return []any{
+		identity.ID,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+		identity.CreatedAt,
+	}
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ services.JobRepository = (*jobRepository)(nil)
+
+type jobRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewJobRepository(db *pgxpool.Pool) *jobRepository {
+	return &jobRepository{db: db}
+}
+
+// insertJob adds a job inside tx, so that it is only enqueued if the surrounding
+// transaction commits
+func insertJob(ctx context.Context, tx pgx.Tx, job *entity.Job) error {
+	query := "INSERT INTO jobs (id, queue, payload, status, max_attempts, run_at, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $7)"
+
+	_, err := tx.Exec(
+		ctx,
+		query,
+		job.ID,
+		job.Queue,
+		job.Payload,
+		job.Status,
+		job.MaxAttempts,
+		job.RunAt,
+		job.CreatedAt,
+	)
+
+	return err
+}
+
+func (jr *jobRepository) Enqueue(ctx context.Context, job *entity.Job) error {
+	tx, err := jr.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = insertJob(ctx, tx, job)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Claim marks up to limit due jobs of queue as running and returns them. Jobs locked by
+// another worker are skipped; running jobs whose lock is older than staleBefore are
+// assumed to belong to a crashed worker and are claimed again.
+func (jr *jobRepository) Claim(
+	ctx context.Context,
+	queue string,
+	now time.Time,
+	staleBefore time.Time,
+	limit int,
+) ([]*entity.Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, locked_at = $2, updated_at = $2
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE queue = $1
+			AND (
+				(status = 'pending' AND run_at <= $2)
+				OR (status = 'running' AND locked_at < $3)
+			)
+			ORDER BY run_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, queue, payload, status, attempts, max_attempts, run_at, created_at
+	`
+
+	rows, err := jr.db.Query(ctx, query, queue, now, staleBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	jobs := []*entity.Job{}
+
+	for rows.Next() {
+		var job entity.Job
+
+		err := rows.Scan(
+			&job.ID,
+			&job.Queue,
+			&job.Payload,
+			&job.Status,
+			&job.Attempts,
+			&job.MaxAttempts,
+			&job.RunAt,
+			&job.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, &job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// Complete marks a job as done and drops its payload, which may hold secrets
+func (jr *jobRepository) Complete(ctx context.Context, id uuid.UUID, now time.Time) error {
+	query := "UPDATE jobs SET status = 'done', payload = '{}', locked_at = NULL, last_error = NULL, updated_at = $2 WHERE id = $1"
+
+	_, err := jr.db.Exec(ctx, query, id, now)
+
+	return err
+}
+
+func (jr *jobRepository) Retry(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string, now time.Time) error {
+	query := "UPDATE jobs SET status = 'pending', run_at = $2, locked_at = NULL, last_error = $3, updated_at = $4 WHERE id = $1"
+
+	_, err := jr.db.Exec(ctx, query, id, runAt, lastError, now)
+
+	return err
+}
+
+func (jr *jobRepository) Bury(ctx context.Context, id uuid.UUID, lastError string, now time.Time) error {
+	query := "UPDATE jobs SET status = 'dead', locked_at = NULL, last_error = $2, updated_at = $3 WHERE id = $1"
+
+	_, err := jr.db.Exec(ctx, query, id, lastError, now)
+
+	return err
+}
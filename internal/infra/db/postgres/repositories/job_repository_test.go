@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestClaimSkipsLockedJobs runs against a migrated database given by PG_URL
+func TestClaimSkipsLockedJobs(t *testing.T) {
+	url := os.Getenv("PG_URL")
+	if url == "" {
+		t.Skip("PG_URL not set")
+	}
+
+	db, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	repo := NewJobRepository(db)
+
+	// A queue of its own keeps the test independent of other jobs in the database
+	queue := "test-" + uuid.NewString()
+	jobs := 50
+
+	for i := 0; i < jobs; i++ {
+		job, err := entity.NewJob(queue, map[string]int{"n": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = repo.Enqueue(context.Background(), job)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer db.Exec(context.Background(), "DELETE FROM jobs WHERE queue = $1", queue)
+
+	workers := 8
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	claimed := map[uuid.UUID]int{}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				now := time.Now()
+
+				batch, err := repo.Claim(context.Background(), queue, now, now.Add(-time.Hour), 3)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if len(batch) == 0 {
+					return
+				}
+
+				mu.Lock()
+				for _, job := range batch {
+					claimed[job.ID]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if len(claimed) != jobs {
+		t.Fatalf("claimed %d distinct jobs, want %d", len(claimed), jobs)
+	}
+
+	for id, count := range claimed {
+		if count != 1 {
+			t.Fatalf("job %s was claimed %d times", id, count)
+		}
+	}
+}
@@ -3,64 +3,241 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"strings"
+	"errors"
+	"time"
 
 	"github.com/Caixetadev/snippet/internal/entity"
 	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/Caixetadev/snippet/pkg/contentstore"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var _ services.PostRepository = (*postRepository)(nil)
+var (
+	_ services.PostRepository        = (*postRepository)(nil)
+	_ services.ExpiredPostRepository = (*postRepository)(nil)
+)
 
+// postRepository keeps post bodies in the content store and only their key and a
+// preview in the database
 type postRepository struct {
-	db *pgxpool.Pool
+	db          *pgxpool.Pool
+	contents    contentstore.Store
+	compression contentstore.Compression
 }
 
-func NewPostRepository(db *pgxpool.Pool) *postRepository {
-	return &postRepository{db: db}
+func NewPostRepository(db *pgxpool.Pool, contents contentstore.Store, compression contentstore.Compression) *postRepository {
+	return &postRepository{db: db, contents: contents, compression: compression}
 }
 
 func (pr *postRepository) Insert(ctx context.Context, post *entity.PostInput) error {
-	query := "INSERT INTO posts (id, user_id, title, content, password, has_password, visibility, expiration_at, delete_after_view) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)"
+	tx, err := pr.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	sealed := post.Encrypted || post.Encryption != entity.Unencrypted
+
+	contentKey, preview, err := pr.storeContent(ctx, tx, post.Content, sealed)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO posts (id, user_id, title, content, content_key, language, password, has_password, visibility, expiration_at, delete_after_view, forked_from, encrypted, encryption, data_key, management_token_hash, body_vector, search_text) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NULLIF($16, ''), " + bodyVector("$17") + ", $17::text)"
 
-	_, err := pr.db.Exec(
+	_, err = tx.Exec(
 		ctx,
 		query,
 		post.ID,
 		post.UserID,
 		post.Title,
-		post.Content,
+		preview,
+		contentKey,
+		post.Language,
 		post.Password,
 		post.HasPassword,
 		post.Visibility,
 		post.ExpirationAt,
 		post.DeleteAfterView,
+		post.ForkedFrom,
+		post.Encrypted,
+		post.Encryption,
+		post.DataKey,
+		post.ManagementTokenHash,
+		searchableContent(post.Content, sealed),
 	)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(ctx, tx, post.ID, 1, post.Title, contentKey, preview)
+	if err != nil {
+		return err
+	}
+
+	for position, file := range post.Files {
+		fileKey, _, err := pr.storeContent(ctx, tx, file.Content, sealed)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			"INSERT INTO post_files (post_id, position, filename, language, content, content_key) VALUES ($1, $2, $3, $4, '', $5)",
+			post.ID,
+			position,
+			file.Filename,
+			file.Language,
+			fileKey,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// insertRevision snapshots a post. Content is only stored inline for bodies that are
+// not in the content store.
+func insertRevision(ctx context.Context, tx pgx.Tx, postID string, revision int, title string, contentKey *string, content string) error {
+	if contentKey != nil {
+		content = ""
+	}
+
+	query := "INSERT INTO post_revisions (post_id, revision, title, content, content_key) VALUES ($1, $2, $3, $4, $5)"
+
+	_, err := tx.Exec(ctx, query, postID, revision, title, content, contentKey)
 
 	return err
 }
 
+// storeContent saves content in the content store and returns its key and preview. The
+// content_blobs row is locked until tx ends, so DeleteUnreferencedContent cannot remove
+// the blob before the row referencing it is committed. Empty content is not stored.
+// Sealed content is encrypted: it has no preview and is not worth compressing.
+func (pr *postRepository) storeContent(ctx context.Context, tx pgx.Tx, content string, sealed bool) (*string, string, error) {
+	if content == "" {
+		return nil, "", nil
+	}
+
+	data := []byte(content)
+	key := contentstore.Key(data)
+
+	preview := entity.ContentPreview(content)
+	compression := pr.compression
+
+	if sealed {
+		preview = ""
+		compression = contentstore.Compression{Codec: contentstore.CodecIdentity}
+	}
+
+	encoded, codec, err := compression.Encode(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// An existing blob keeps the codec it was stored with
+	query := `
+		INSERT INTO content_blobs (key, size, codec, stored_size) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE SET size = EXCLUDED.size
+		RETURNING codec, (xmax = 0) AS inserted
+	`
+
+	var storedCodec string
+	var inserted bool
+
+	err = tx.QueryRow(ctx, query, key, len(data), codec, len(encoded)).Scan(&storedCodec, &inserted)
+	if err != nil {
+		return nil, "", err
+	}
+
+	name := contentstore.Name(key, storedCodec)
+
+	if !inserted {
+		exists, err := pr.contents.Exists(ctx, name)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if exists {
+			return &key, preview, nil
+		}
+
+		if storedCodec != codec {
+			encoded, err = contentstore.Encode(data, storedCodec)
+			if err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	err = pr.contents.Put(ctx, name, encoded)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &key, preview, nil
+}
+
+// contentCodec selects the codec of the blob referenced by column
+func contentCodec(column string) string {
+	return "(SELECT codec FROM content_blobs WHERE key = " + column + ")"
+}
+
+// loadContent replaces content with the full body when it is in the content store
+func (pr *postRepository) loadContent(ctx context.Context, contentKey *string, codec *string, content *string) error {
+	if contentKey == nil || codec == nil {
+		return nil
+	}
+
+	data, err := pr.contents.Get(ctx, contentstore.Name(*contentKey, *codec))
+	if err != nil {
+		return err
+	}
+
+	data, err = contentstore.Decode(data, *codec)
+	if err != nil {
+		return err
+	}
+
+	*content = string(data)
+
+	return nil
+}
+
 const PAGINATION_LIMIT = 10
 
+// zeroTimestamp is how an unset time.Time is stored in a TIMESTAMP column
+const zeroTimestamp = "'0001-01-01 00:00:00'"
+
+// notExpired is a condition matching posts that never expire or expire after
+// the time bound to param. The current time is passed from Go rather than
+// using now() because timestamps are stored without a time zone.
+func notExpired(param string) string {
+	return "(expiration_at IS NULL OR expiration_at <= " + zeroTimestamp + " OR expiration_at > " + param + ")"
+}
+
 func (pr *postRepository) FindAll(
 	ctx context.Context,
 	id uuid.UUID,
 	page int,
 ) ([]*entity.PostOutput, int, error) {
 	query := `
-		SELECT id, title, created_at, has_password, visibility,
+		SELECT id, title, language, created_at, has_password, visibility,
 			count(*) OVER() AS full_count
 		FROM posts
-		WHERE user_id = $1
+		WHERE user_id = $1 AND ` + notExpired("$4") + `
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3;
 	`
 
 	offset := (page - 1) * PAGINATION_LIMIT
 
-	line, err := pr.db.Query(ctx, query, id, PAGINATION_LIMIT, offset)
+	line, err := pr.db.Query(ctx, query, id, PAGINATION_LIMIT, offset, time.Now())
 	if err != nil {
 		return nil, 0, err
 	}
@@ -72,7 +249,7 @@ func (pr *postRepository) FindAll(
 
 	for line.Next() {
 		post := &entity.PostOutput{}
-		if err := line.Scan(&post.ID, &post.Title, &post.CreatedAt, &post.HasPassword, &post.Visibility, &count); err != nil {
+		if err := line.Scan(&post.ID, &post.Title, &post.Language, &post.CreatedAt, &post.HasPassword, &post.Visibility, &count); err != nil {
 			return nil, 0, err
 		}
 
@@ -86,19 +263,19 @@ func (pr *postRepository) FindAll(
 	return posts, count, nil
 }
 
-func (pr *postRepository) FindAllPublics(ctx context.Context, page int) ([]*entity.PostOutput, int, error) {
+func (pr *postRepository) FindAllPublics(ctx context.Context, page int, language string) ([]*entity.PostOutput, int, error) {
 	query := `
-		SELECT id, user_id, title, created_at, has_password, visibility, expiration_at, delete_after_view,
+		SELECT id, user_id, title, language, created_at, has_password, visibility, expiration_at, delete_after_view,
 			count(*) OVER() AS full_count
 		FROM posts
-		WHERE visibility = $1
+		WHERE visibility = $1 AND ($4 = '' OR language = $4) AND ` + notExpired("$5") + `
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3;
 	`
 
 	offset := (page - 1) * PAGINATION_LIMIT
 
-	line, err := pr.db.Query(ctx, query, entity.Public, PAGINATION_LIMIT, offset)
+	line, err := pr.db.Query(ctx, query, entity.Public, PAGINATION_LIMIT, offset, language, time.Now())
 	if err != nil {
 		return nil, 0, err
 	}
@@ -110,7 +287,7 @@ func (pr *postRepository) FindAllPublics(ctx context.Context, page int) ([]*enti
 
 	for line.Next() {
 		post := &entity.PostOutput{}
-		if err := line.Scan(&post.ID, &post.UserID, &post.Title, &post.CreatedAt, &post.HasPassword, &post.Visibility, &post.ExpirationAt, &post.DeleteAfterView, &count); err != nil {
+		if err := line.Scan(&post.ID, &post.UserID, &post.Title, &post.Language, &post.CreatedAt, &post.HasPassword, &post.Visibility, &post.ExpirationAt, &post.DeleteAfterView, &count); err != nil {
 			return nil, 0, err
 		}
 
@@ -127,9 +304,9 @@ func (pr *postRepository) FindAllPublics(ctx context.Context, page int) ([]*enti
 func (pr *postRepository) CountUserPosts(ctx context.Context, id uuid.UUID) (int, error) {
 	var count int
 
-	query := "SELECT COUNT(*) FROM posts WHERE user_id = $1"
+	query := "SELECT COUNT(*) FROM posts WHERE user_id = $1 AND " + notExpired("$2")
 
-	err := pr.db.QueryRow(ctx, query, id).Scan(&count)
+	err := pr.db.QueryRow(ctx, query, id, time.Now()).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
@@ -140,9 +317,9 @@ func (pr *postRepository) CountUserPosts(ctx context.Context, id uuid.UUID) (int
 func (pr *postRepository) CountAllPostsPublics(ctx context.Context) (int, error) {
 	var count int
 
-	query := "SELECT COUNT(*) FROM posts WHERE visibility = 'public'"
+	query := "SELECT COUNT(*) FROM posts WHERE visibility = 'public' AND " + notExpired("$1")
 
-	err := pr.db.QueryRow(ctx, query).Scan(&count)
+	err := pr.db.QueryRow(ctx, query, time.Now()).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
@@ -150,12 +327,29 @@ func (pr *postRepository) CountAllPostsPublics(ctx context.Context) (int, error)
 	return count, nil
 }
 
+// searchCondition matches posts against the full-text query in $1, falling back to a
+// trigram-indexed substring match on the escaped pattern in $2 for code tokens. Both
+// search the indexed part of the full body, or the preview of posts not indexed yet.
+// Posts encrypted by the client are never searched.
+// searchText is the text of a post matched by substring, as in idx_posts_search_text_trgm
+const searchText = "coalesce(search_text, content)"
+
+const searchCondition = `
+	visibility = 'public'
+	AND NOT encrypted
+	AND (
+		$2 = ''
+		OR ($1 <> '' AND search_vector @@ to_tsquery('simple', $1))
+		OR (length($2) >= 3 AND (title ILIKE '%' || $2 || '%' OR ` + searchText + ` ILIKE '%' || $2 || '%'))
+	)
+`
+
 func (pr *postRepository) CountPostsInSearch(ctx context.Context, query string) (int, error) {
 	var count int
 
-	querySql := "SELECT COUNT(*) FROM posts WHERE (title ILIKE '%' || $1 || '%' OR content ILIKE '%' || $1 || '%') AND visibility = 'public'"
+	querySql := "SELECT COUNT(*) FROM posts WHERE " + searchCondition + " AND " + notExpired("$3")
 
-	err := pr.db.QueryRow(ctx, querySql, query).
+	err := pr.db.QueryRow(ctx, querySql, buildTSQuery(query), escapeLikePattern(query), time.Now()).
 		Scan(&count)
 	if err != nil {
 		return 0, err
@@ -165,7 +359,12 @@ func (pr *postRepository) CountPostsInSearch(ctx context.Context, query string)
 }
 
 func (pr *postRepository) FindOneByID(ctx context.Context, id string) (*entity.PostOutput, error) {
-	query := "SELECT id, user_id, title, content, created_at, expiration_at, password, has_password, visibility, delete_after_view FROM posts WHERE id = $1"
+	query := `
+		SELECT id, user_id, title, content, content_key, ` + contentCodec("posts.content_key") + `, language, created_at, expiration_at, password, has_password, visibility, delete_after_view, revision, forked_from, encrypted, encryption, data_key, COALESCE(management_token_hash, ''),
+			(SELECT COUNT(*) FROM posts forks WHERE forks.forked_from = posts.id) AS fork_count
+		FROM posts
+		WHERE id = $1
+	`
 
 	line, err := pr.db.Query(ctx, query, id)
 	if err != nil {
@@ -175,18 +374,73 @@ func (pr *postRepository) FindOneByID(ctx context.Context, id string) (*entity.P
 	defer line.Close()
 
 	var post entity.PostOutput
+	var contentKey, codec *string
 
 	if line.Next() {
-		if err := line.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.ExpirationAt, &post.Password, &post.HasPassword, &post.Visibility, &post.DeleteAfterView); err != nil {
+		if err := line.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &contentKey, &codec, &post.Language, &post.CreatedAt, &post.ExpirationAt, &post.Password, &post.HasPassword, &post.Visibility, &post.DeleteAfterView, &post.Revision, &post.ForkedFrom, &post.Encrypted, &post.Encryption, &post.DataKey, &post.ManagementTokenHash, &post.ForkCount); err != nil {
 			return nil, err
 		}
 	} else {
 		return nil, sql.ErrNoRows
 	}
 
+	line.Close()
+
+	err = pr.loadContent(ctx, contentKey, codec, &post.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	post.Files, err = pr.findFiles(ctx, post.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &post, nil
 }
 
+func (pr *postRepository) findFiles(ctx context.Context, id string) ([]entity.PostFile, error) {
+	query := "SELECT filename, language, content, content_key, " + contentCodec("post_files.content_key") + " FROM post_files WHERE post_id = $1 ORDER BY position"
+
+	line, err := pr.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer line.Close()
+
+	var files []entity.PostFile
+	var contentKeys, codecs []*string
+
+	for line.Next() {
+		var file entity.PostFile
+		var contentKey, codec *string
+
+		if err := line.Scan(&file.Filename, &file.Language, &file.Content, &contentKey, &codec); err != nil {
+			return nil, err
+		}
+
+		files = append(files, file)
+		contentKeys = append(contentKeys, contentKey)
+		codecs = append(codecs, codec)
+	}
+
+	if err := line.Err(); err != nil {
+		return nil, err
+	}
+
+	line.Close()
+
+	for i := range files {
+		err = pr.loadContent(ctx, contentKeys[i], codecs[i], &files[i].Content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
 func (pr *postRepository) Delete(ctx context.Context, id string) error {
 	query := "DELETE FROM posts WHERE id = $1"
 
@@ -198,47 +452,222 @@ func (pr *postRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
-// TODO: refactor this function
-func (pr *postRepository) Update(ctx context.Context, post *entity.PostUpdateInput) error {
-	query := "UPDATE posts SET"
-	var args []interface{}
+// Claim moves an anonymous post into the account of userID if tokenHash still manages
+// it. The management token stops working once the post has an owner.
+func (pr *postRepository) Claim(ctx context.Context, id string, userID uuid.UUID, tokenHash string) error {
+	query := "UPDATE posts SET user_id = $2, management_token_hash = NULL WHERE id = $1 AND user_id IS NULL AND management_token_hash = $3"
 
-	if post.Title != "" {
-		query += fmt.Sprintf(" title = $%d,", len(args)+1)
-		args = append(args, post.Title)
+	tag, err := pr.db.Exec(ctx, query, id, userID, tokenHash)
+	if err != nil {
+		return err
 	}
 
-	if post.Content != "" {
-		query += fmt.Sprintf(" content = $%d,", len(args)+1)
-		args = append(args, post.Content)
+	if tag.RowsAffected() == 0 {
+		return sql.ErrNoRows
 	}
 
-	query = strings.TrimSuffix(query, ",")
+	return nil
+}
+
+// ReplacePasswordHash swaps a post password hash for an upgraded one, unless the
+// password was changed in the meantime
+func (pr *postRepository) ReplacePasswordHash(ctx context.Context, id string, oldHash string, newHash string) error {
+	query := "UPDATE posts SET password = $3 WHERE id = $1 AND password = $2"
 
-	query += fmt.Sprintf(" WHERE id = $%d", len(args)+1)
-	args = append(args, post.ID)
+	_, err := pr.db.Exec(ctx, query, id, oldHash, newHash)
 
-	_, err := pr.db.Exec(ctx, query, args...)
+	return err
+}
+
+// Burn deletes a burn-after-read post and returns it in the same statement, so
+// only one of several concurrent callers gets the row back. The body is read before
+// the delete commits, while the row still keeps it from being collected.
+func (pr *postRepository) Burn(ctx context.Context, id string) (*entity.PostOutput, error) {
+	tx, err := pr.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	query := `
+		DELETE FROM posts
+		WHERE id = $1 AND delete_after_view
+		RETURNING id, user_id, title, content, content_key, ` + contentCodec("posts.content_key") + `, language, created_at, expiration_at, has_password, visibility, delete_after_view, revision, forked_from, encrypted
+	`
+
+	var post entity.PostOutput
+	var contentKey, codec *string
+
+	err = tx.QueryRow(ctx, query, id).Scan(
+		&post.ID,
+		&post.UserID,
+		&post.Title,
+		&post.Content,
+		&contentKey,
+		&codec,
+		&post.Language,
+		&post.CreatedAt,
+		&post.ExpirationAt,
+		&post.HasPassword,
+		&post.Visibility,
+		&post.DeleteAfterView,
+		&post.Revision,
+		&post.ForkedFrom,
+		&post.Encrypted,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	err = pr.loadContent(ctx, contentKey, codec, &post.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &post, nil
+}
+
+// Update applies the non-empty fields of post and stores the result as a new revision
+func (pr *postRepository) Update(ctx context.Context, post *entity.PostUpdateInput) error {
+	tx, err := pr.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	defer tx.Rollback(ctx)
+
+	sealed := post.Encrypted || post.Encryption != entity.Unencrypted
+
+	contentKey, preview, err := pr.storeContent(ctx, tx, post.Content, sealed)
+	if err != nil {
+		return err
+	}
+
+	// $6 says whether the content changed. Sealed content has an empty preview, so the
+	// preview cannot tell.
+	query := `
+		UPDATE posts
+		SET title = COALESCE(NULLIF($1, ''), title),
+			content = CASE WHEN $6 THEN $2 ELSE content END,
+			content_key = CASE WHEN $6 THEN $5 ELSE content_key END,
+			body_vector = CASE WHEN $6 THEN ` + bodyVector("$7") + ` ELSE body_vector END,
+			search_text = CASE WHEN $6 THEN $7::text ELSE search_text END,
+			language = COALESCE(NULLIF($3, ''), language),
+			revision = revision + 1
+		WHERE id = $4
+		RETURNING title, content, content_key, revision
+	`
+
+	var title, content string
+	var revision int
+
+	err = tx.QueryRow(ctx, query, post.Title, preview, post.Language, post.ID, contentKey, contentKey != nil, searchableContent(post.Content, sealed)).Scan(&title, &content, &contentKey, &revision)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return sql.ErrNoRows
+		}
+		return err
+	}
+
+	err = insertRevision(ctx, tx, post.ID, revision, title, contentKey, content)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (pr *postRepository) Search(ctx context.Context, q string, page int) ([]*entity.PostOutput, int, error) {
+func (pr *postRepository) FindRevisions(ctx context.Context, id string) ([]*entity.PostRevision, error) {
 	query := `
-		SELECT id, user_id, title, content, has_password, created_at,
-			count(*) OVER() AS full_count
+		SELECT post_id, revision, title, created_at
+		FROM post_revisions
+		WHERE post_id = $1
+		ORDER BY revision DESC
+	`
+
+	line, err := pr.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer line.Close()
+
+	var revisions []*entity.PostRevision
+
+	for line.Next() {
+		revision := &entity.PostRevision{}
+		if err := line.Scan(&revision.PostID, &revision.Revision, &revision.Title, &revision.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	if len(revisions) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return revisions, nil
+}
+
+func (pr *postRepository) FindRevision(ctx context.Context, id string, revision int) (*entity.PostRevision, error) {
+	query := "SELECT post_id, revision, title, content, content_key, " + contentCodec("post_revisions.content_key") + ", created_at FROM post_revisions WHERE post_id = $1 AND revision = $2"
+
+	var postRevision entity.PostRevision
+	var contentKey, codec *string
+
+	err := pr.db.QueryRow(ctx, query, id, revision).Scan(
+		&postRevision.PostID,
+		&postRevision.Revision,
+		&postRevision.Title,
+		&postRevision.Content,
+		&contentKey,
+		&codec,
+		&postRevision.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	err = pr.loadContent(ctx, contentKey, codec, &postRevision.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	return &postRevision, nil
+}
+
+func (pr *postRepository) Search(ctx context.Context, q string, page int, language string) ([]*entity.PostOutput, int, error) {
+	query := `
+		SELECT id, user_id, title, content, content_key, ` + contentCodec("posts.content_key") + `, encryption <> '' AS sealed,
+			language, has_password, created_at, count(*) OVER() AS full_count
 		FROM posts
-		WHERE (title ILIKE '%' || $1 || '%' OR content ILIKE '%' || $1 || '%') AND visibility = 'public'
-		ORDER BY created_at DESC, id DESC
-		LIMIT $2 OFFSET $3;
+		WHERE ` + searchCondition + `
+			AND ($5 = '' OR language = $5)
+			AND ` + notExpired("$6") + `
+		ORDER BY
+			CASE WHEN $1 <> '' THEN ts_rank_cd(search_vector, to_tsquery('simple', $1)) ELSE 0 END DESC,
+			CASE WHEN length($2) >= 3 THEN word_similarity($2, title || ' ' || ` + searchText + `) ELSE 0 END DESC,
+			created_at DESC, id DESC
+		LIMIT $3 OFFSET $4;
 	`
 
 	offset := (page - 1) * PAGINATION_LIMIT
+	tsQuery := buildTSQuery(q)
 
-	line, err := pr.db.Query(ctx, query, q, PAGINATION_LIMIT, offset)
+	line, err := pr.db.Query(ctx, query, tsQuery, escapeLikePattern(q), PAGINATION_LIMIT, offset, language, time.Now())
 	if err != nil {
 		return nil, 0, err
 	}
@@ -246,16 +675,22 @@ func (pr *postRepository) Search(ctx context.Context, q string, page int) ([]*en
 	defer line.Close()
 
 	var posts []*entity.PostOutput
+	var bodies []searchBody
 	var count int
 
 	for line.Next() {
 		post := &entity.PostOutput{}
+		var body searchBody
 
 		if err := line.Scan(
 			&post.ID,
 			&post.UserID,
 			&post.Title,
 			&post.Content,
+			&body.contentKey,
+			&body.codec,
+			&body.sealed,
+			&post.Language,
 			&post.HasPassword,
 			&post.CreatedAt,
 			&count,
@@ -264,11 +699,254 @@ func (pr *postRepository) Search(ctx context.Context, q string, page int) ([]*en
 		}
 
 		posts = append(posts, post)
+		bodies = append(bodies, body)
 	}
 
 	if len(posts) == 0 && count == 0 {
 		return nil, 0, sql.ErrNoRows
 	}
 
+	if tsQuery != "" {
+		err = pr.highlight(ctx, tsQuery, posts, bodies)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
 	return posts, count, nil
 }
+
+// searchBody locates the full body of a search result
+type searchBody struct {
+	contentKey *string
+	codec      *string
+	sealed     bool
+}
+
+// highlight sets the highlight of each post to the fragments of its full body that
+// match tsQuery, as HTML with the matches in <mark>. Sealed bodies have no highlight.
+func (pr *postRepository) highlight(ctx context.Context, tsQuery string, posts []*entity.PostOutput, bodies []searchBody) error {
+	texts := make([]string, len(posts))
+
+	for i, post := range posts {
+		if bodies[i].sealed {
+			continue
+		}
+
+		text := post.Content
+
+		err := pr.loadContent(ctx, bodies[i].contentKey, bodies[i].codec, &text)
+		if err != nil {
+			return err
+		}
+
+		texts[i] = highlightable(*searchableContent(text, false))
+	}
+
+	query := `
+		SELECT ts_headline('simple', body, to_tsquery('simple', $2), $3)
+		FROM unnest($1::text[]) WITH ORDINALITY AS bodies(body, n)
+		ORDER BY n
+	`
+
+	line, err := pr.db.Query(ctx, query, texts, tsQuery, headlineOptions)
+	if err != nil {
+		return err
+	}
+
+	defer line.Close()
+
+	i := 0
+
+	for line.Next() {
+		var headline string
+		if err := line.Scan(&headline); err != nil {
+			return err
+		}
+
+		posts[i].Highlight = markHighlight(headline)
+		i++
+	}
+
+	return line.Err()
+}
+
+// expiredPostIDs selects up to $2 posts that expired before $1, skipping rows
+// locked by a concurrent sweep
+const expiredPostIDs = `
+	SELECT id FROM posts
+	WHERE expiration_at > ` + zeroTimestamp + ` AND expiration_at <= $1
+	ORDER BY expiration_at
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED
+`
+
+// DeleteExpired hard-deletes up to limit posts that expired before now
+func (pr *postRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) (int, error) {
+	query := "DELETE FROM posts WHERE id IN (" + expiredPostIDs + ")"
+
+	tag, err := pr.db.Exec(ctx, query, now, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// ArchiveExpired moves up to limit posts that expired before now, with their files,
+// into archived_posts and archived_post_files. The files are copied before the posts
+// are deleted, since the delete cascades to post_files.
+func (pr *postRepository) ArchiveExpired(ctx context.Context, now time.Time, limit int) (int, error) {
+	tx, err := pr.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	line, err := tx.Query(ctx, expiredPostIDs, now, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []string
+
+	for line.Next() {
+		var id string
+		if err := line.Scan(&id); err != nil {
+			line.Close()
+			return 0, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	line.Close()
+
+	if err := line.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	query := `
+		INSERT INTO archived_posts (id, user_id, title, content, content_key, language, created_at, expiration_at, encrypted, encryption, data_key)
+		SELECT id, user_id, title, content, content_key, language, created_at, expiration_at, encrypted, encryption, data_key
+		FROM posts WHERE id = ANY($1::text[])
+		ON CONFLICT (id) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			title = EXCLUDED.title,
+			content = EXCLUDED.content,
+			content_key = EXCLUDED.content_key,
+			language = EXCLUDED.language,
+			created_at = EXCLUDED.created_at,
+			expiration_at = EXCLUDED.expiration_at,
+			encrypted = EXCLUDED.encrypted,
+			encryption = EXCLUDED.encryption,
+			data_key = EXCLUDED.data_key,
+			archived_at = CURRENT_TIMESTAMP
+	`
+
+	_, err = tx.Exec(ctx, query, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	// A post archived again under a reused id replaces the files of the earlier one
+	_, err = tx.Exec(ctx, "DELETE FROM archived_post_files WHERE post_id = ANY($1::text[])", ids)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `
+		INSERT INTO archived_post_files (post_id, position, filename, language, content, content_key)
+		SELECT post_id, position, filename, language, content, content_key
+		FROM post_files WHERE post_id = ANY($1::text[])
+	`
+
+	_, err = tx.Exec(ctx, query, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := tx.Exec(ctx, "DELETE FROM posts WHERE id = ANY($1::text[])", ids)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// unreferencedContent selects up to $1 blobs no post, file, revision, archived post or
+// archived file refers to, skipping blobs locked by a writer that is about to reference them
+const unreferencedContent = `
+	SELECT key, codec FROM content_blobs b
+	WHERE NOT EXISTS (SELECT 1 FROM posts WHERE content_key = b.key)
+		AND NOT EXISTS (SELECT 1 FROM post_files WHERE content_key = b.key)
+		AND NOT EXISTS (SELECT 1 FROM post_revisions WHERE content_key = b.key)
+		AND NOT EXISTS (SELECT 1 FROM archived_posts WHERE content_key = b.key)
+		AND NOT EXISTS (SELECT 1 FROM archived_post_files WHERE content_key = b.key)
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+`
+
+// DeleteUnreferencedContent removes up to limit blobs that are no longer referenced from
+// the content store. The rows are deleted first so that a reference committed in the
+// meantime fails the foreign key check and keeps the blob.
+func (pr *postRepository) DeleteUnreferencedContent(ctx context.Context, limit int) (int, error) {
+	tx, err := pr.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	line, err := tx.Query(ctx, unreferencedContent, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var keys, names []string
+
+	for line.Next() {
+		var key, codec string
+		if err := line.Scan(&key, &codec); err != nil {
+			line.Close()
+			return 0, err
+		}
+
+		keys = append(keys, key)
+		names = append(names, contentstore.Name(key, codec))
+	}
+
+	line.Close()
+
+	if err := line.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM content_blobs WHERE key = ANY($1::text[])", keys)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, name := range names {
+		err = pr.contents.Delete(ctx, name)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(keys), tx.Commit(ctx)
+}
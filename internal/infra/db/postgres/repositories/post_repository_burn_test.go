@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/pkg/contentstore"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestBurnConcurrent runs against a migrated database given by PG_URL
+func TestBurnConcurrent(t *testing.T) {
+	url := os.Getenv("PG_URL")
+	if url == "" {
+		t.Skip("PG_URL not set")
+	}
+
+	db, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	contents, err := contentstore.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewPostRepository(db, contents, contentstore.Compression{Codec: contentstore.CodecGzip, Threshold: 64})
+
+	post := entity.NewPost(nil, "burn", "secret", "text", "", false, entity.Public, time.Time{}, true)
+
+	err = repo.Insert(context.Background(), post)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readers := 20
+
+	var wg sync.WaitGroup
+	var delivered, missed atomic.Int32
+
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			burned, err := repo.Burn(context.Background(), post.ID)
+			switch {
+			case err == nil && burned.Content == "secret":
+				delivered.Add(1)
+			case errors.Is(err, sql.ErrNoRows):
+				missed.Add(1)
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if delivered.Load() != 1 {
+		t.Fatalf("delivered %d times, want exactly once", delivered.Load())
+	}
+
+	if missed.Load() != int32(readers-1) {
+		t.Fatalf("missed %d times, want %d", missed.Load(), readers-1)
+	}
+}
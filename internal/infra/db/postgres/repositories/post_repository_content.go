@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/Caixetadev/snippet/pkg/contentstore"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var _ services.ContentMigrationRepository = (*postRepository)(nil)
+
+// inlineContentTables lists the tables whose rows held their body inline before the
+// content store. Rows of posts and archived_posts keep a preview of it, and the full
+// body of posts stays indexed for search.
+var inlineContentTables = []struct {
+	name    string
+	preview bool
+	indexed bool
+}{
+	{name: "posts", preview: true, indexed: true},
+	{name: "post_files"},
+	{name: "post_revisions"},
+	{name: "archived_posts", preview: true},
+	{name: "archived_post_files"},
+}
+
+// MoveInlineContent moves the bodies of up to limit rows of each table that still hold
+// them inline into the content store
+func (pr *postRepository) MoveInlineContent(ctx context.Context, limit int) (int, error) {
+	moved := 0
+
+	for _, table := range inlineContentTables {
+		n, err := pr.moveInlineContent(ctx, table.name, table.preview, table.indexed, limit)
+		if err != nil {
+			return moved, err
+		}
+
+		moved += n
+	}
+
+	return moved, nil
+}
+
+func (pr *postRepository) moveInlineContent(ctx context.Context, table string, preview bool, indexed bool, limit int) (int, error) {
+	tx, err := pr.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	query := "SELECT ctid, content FROM " + table + " WHERE content_key IS NULL AND content <> '' LIMIT $1 FOR UPDATE SKIP LOCKED"
+
+	line, err := tx.Query(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	type inlineRow struct {
+		ctid    pgtype.TID
+		content string
+	}
+
+	var rows []inlineRow
+
+	for line.Next() {
+		var row inlineRow
+		if err := line.Scan(&row.ctid, &row.content); err != nil {
+			line.Close()
+			return 0, err
+		}
+
+		rows = append(rows, row)
+	}
+
+	line.Close()
+
+	if err := line.Err(); err != nil {
+		return 0, err
+	}
+
+	update := "UPDATE " + table + " SET content = $2, content_key = $3 WHERE ctid = $1"
+	if indexed {
+		update = "UPDATE " + table + " SET content = $2, content_key = $3, body_vector = " + bodyVector("$4") + ", search_text = $4::text WHERE ctid = $1"
+	}
+
+	// The rows are locked, so their ctid stays valid until the transaction ends
+	for _, row := range rows {
+		contentKey, content, err := pr.storeContent(ctx, tx, row.content, false)
+		if err != nil {
+			return 0, err
+		}
+
+		if !preview {
+			content = ""
+		}
+
+		args := []any{row.ctid, content, contentKey}
+		if indexed {
+			args = append(args, searchableContent(row.content, false))
+		}
+
+		_, err = tx.Exec(ctx, update, args...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(rows), tx.Commit(ctx)
+}
+
+// IndexContent indexes for search the full bodies of up to limit posts that were stored
+// before full bodies were indexed and are only searched by their preview
+func (pr *postRepository) IndexContent(ctx context.Context, limit int) (int, error) {
+	tx, err := pr.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	query := `
+		SELECT id, content, content_key, ` + contentCodec("posts.content_key") + `
+		FROM posts
+		WHERE (body_vector IS NULL OR search_text IS NULL) AND content_key IS NOT NULL AND NOT encrypted AND encryption = ''
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+
+	line, err := tx.Query(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	type storedRow struct {
+		id         string
+		content    string
+		contentKey *string
+		codec      *string
+	}
+
+	var rows []storedRow
+
+	for line.Next() {
+		var row storedRow
+		if err := line.Scan(&row.id, &row.content, &row.contentKey, &row.codec); err != nil {
+			line.Close()
+			return 0, err
+		}
+
+		rows = append(rows, row)
+	}
+
+	line.Close()
+
+	if err := line.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		err = pr.loadContent(ctx, row.contentKey, row.codec, &row.content)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE posts SET body_vector = "+bodyVector("$2")+", search_text = $2::text WHERE id = $1", row.id, searchableContent(row.content, false))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(rows), tx.Commit(ctx)
+}
+
+// CompressContent compresses up to limit stored bodies that were stored as is but are
+// large enough to be compressed now, starting after the key after. It returns the last
+// key it looked at, or an empty string once there is nothing left to look at.
+func (pr *postRepository) CompressContent(ctx context.Context, after string, limit int) (string, int, error) {
+	if pr.compression.Codec == "" || pr.compression.Codec == contentstore.CodecIdentity {
+		return "", 0, nil
+	}
+
+	tx, err := pr.db.Begin(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	query := `
+		SELECT key FROM content_blobs
+		WHERE codec = $1 AND size >= $2 AND key > $3
+		ORDER BY key
+		LIMIT $4
+		FOR UPDATE SKIP LOCKED
+	`
+
+	line, err := tx.Query(ctx, query, contentstore.CodecIdentity, pr.compression.Threshold, after, limit)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var keys []string
+
+	for line.Next() {
+		var key string
+		if err := line.Scan(&key); err != nil {
+			line.Close()
+			return "", 0, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	line.Close()
+
+	if err := line.Err(); err != nil {
+		return "", 0, err
+	}
+
+	if len(keys) == 0 {
+		return "", 0, nil
+	}
+
+	var stale []string
+
+	for _, key := range keys {
+		name := contentstore.Name(key, contentstore.CodecIdentity)
+
+		data, err := pr.contents.Get(ctx, name)
+		if err != nil {
+			return "", 0, err
+		}
+
+		encoded, codec, err := pr.compression.Encode(data)
+		if err != nil {
+			return "", 0, err
+		}
+
+		if codec == contentstore.CodecIdentity {
+			continue
+		}
+
+		// The compressed object gets its own name, so the row never points at an
+		// object in another encoding whatever happens before the commit
+		err = pr.contents.Put(ctx, contentstore.Name(key, codec), encoded)
+		if err != nil {
+			return "", 0, err
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE content_blobs SET codec = $2, stored_size = $3 WHERE key = $1", key, codec, len(encoded))
+		if err != nil {
+			return "", 0, err
+		}
+
+		stale = append(stale, name)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+
+	for _, name := range stale {
+		err = pr.contents.Delete(ctx, name)
+		if err != nil {
+			return "", 0, err
+		}
+	}
+
+	return keys[len(keys)-1], len(stale), nil
+}
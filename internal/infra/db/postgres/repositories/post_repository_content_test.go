@@ -0,0 +1,375 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/pkg/contentstore"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestContentStoredOnce runs against a migrated database given by PG_URL
+func TestContentStoredOnce(t *testing.T) {
+	url := os.Getenv("PG_URL")
+	if url == "" {
+		t.Skip("PG_URL not set")
+	}
+
+	ctx := context.Background()
+
+	db, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	contents, err := contentstore.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewPostRepository(db, contents, contentstore.Compression{Codec: contentstore.CodecGzip, Threshold: 64})
+
+	body := strings.Repeat("fmt.Println(\"hello\")\n", 100) + time.Now().String()
+	key := contentstore.Key([]byte(body))
+
+	var ids []string
+
+	for i := 0; i < 2; i++ {
+		post := entity.NewPost(nil, "content", body, "go", "", false, entity.Public, time.Time{}, false)
+
+		err = repo.Insert(ctx, post)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, post.ID)
+	}
+
+	var blobs, size, storedSize int
+	var codec, preview string
+
+	err = db.QueryRow(ctx, "SELECT COUNT(*), MAX(codec), MAX(size), MAX(stored_size) FROM content_blobs WHERE key = $1", key).Scan(&blobs, &codec, &size, &storedSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blobs != 1 {
+		t.Fatalf("stored %d blobs, want 1", blobs)
+	}
+
+	if codec != contentstore.CodecGzip || size != len(body) || storedSize >= size {
+		t.Fatalf("stored %d of %d bytes with codec %s, want it gzip compressed", storedSize, size, codec)
+	}
+
+	err = db.QueryRow(ctx, "SELECT content FROM posts WHERE id = $1", ids[0]).Scan(&preview)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if preview != entity.ContentPreview(body) {
+		t.Fatalf("posts.content holds %d bytes, want the %d byte preview", len(preview), entity.ContentPreviewSize)
+	}
+
+	post, err := repo.FindOneByID(ctx, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if post.Content != body {
+		t.Fatal("FindOneByID did not return the full body")
+	}
+
+	for _, id := range ids {
+		err = repo.Delete(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for {
+		removed, err := repo.DeleteUnreferencedContent(ctx, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if removed < 100 {
+			break
+		}
+	}
+
+	_, err = contents.Get(ctx, contentstore.Name(key, codec))
+	if err != contentstore.ErrNotFound {
+		t.Fatalf("body still stored after its posts were deleted: %v", err)
+	}
+}
+
+// TestUpdateSealedContent runs against a migrated database given by PG_URL
+func TestUpdateSealedContent(t *testing.T) {
+	url := os.Getenv("PG_URL")
+	if url == "" {
+		t.Skip("PG_URL not set")
+	}
+
+	ctx := context.Background()
+
+	db, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	contents, err := contentstore.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewPostRepository(db, contents, contentstore.Compression{Codec: contentstore.CodecGzip, Threshold: 64})
+
+	// The repository stores sealed bodies as they are, any bytes stand in for ciphertext
+	post := entity.NewPost(nil, "sealed", "ciphertext v1 "+time.Now().String(), "text", "hash", true, entity.Public, time.Time{}, false)
+	post.Encryption = entity.EncryptedWithPassword
+	post.DataKey = []byte("wrapped key")
+
+	err = repo.Insert(ctx, post)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer repo.Delete(ctx, post.ID)
+
+	updated := "ciphertext v2 " + time.Now().String()
+
+	err = repo.Update(ctx, &entity.PostUpdateInput{ID: post.ID, Content: updated, Encryption: entity.EncryptedWithPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := repo.FindOneByID(ctx, post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found.Content != updated || found.Revision != 2 {
+		t.Fatalf("read back revision %d with %q, want revision 2 with the updated body", found.Revision, found.Content)
+	}
+
+	var preview string
+
+	err = db.QueryRow(ctx, "SELECT content FROM posts WHERE id = $1", post.ID).Scan(&preview)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if preview != "" {
+		t.Fatalf("sealed post has preview %q", preview)
+	}
+
+	for revision, want := range map[int]string{1: post.Content, 2: updated} {
+		found, err := repo.FindRevision(ctx, post.ID, revision)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if found.Content != want {
+			t.Fatalf("revision %d holds %q, want %q", revision, found.Content, want)
+		}
+	}
+
+	// A title-only update keeps the sealed body
+	err = repo.Update(ctx, &entity.PostUpdateInput{ID: post.ID, Title: "renamed", Encryption: entity.EncryptedWithPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err = repo.FindOneByID(ctx, post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found.Content != updated || found.Title != "renamed" {
+		t.Fatalf("title-only update changed the body to %q", found.Content)
+	}
+}
+
+// TestSearchFullBody runs against a migrated database given by PG_URL
+func TestSearchFullBody(t *testing.T) {
+	url := os.Getenv("PG_URL")
+	if url == "" {
+		t.Skip("PG_URL not set")
+	}
+
+	ctx := context.Background()
+
+	db, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	contents, err := contentstore.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewPostRepository(db, contents, contentstore.Compression{Codec: contentstore.CodecGzip, Threshold: 64})
+
+	// The word is past the preview kept in posts.content
+	word := "needle" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	body := strings.Repeat("filler ", entity.ContentPreviewSize) + "<script>alert(1)</script> " + word
+
+	post := entity.NewPost(nil, "haystack", body, "text", "", false, entity.Public, time.Time{}, false)
+
+	err = repo.Insert(ctx, post)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer repo.Delete(ctx, post.ID)
+
+	posts, _, err := repo.Search(ctx, word, 1, "")
+	if err != nil {
+		t.Fatalf("search for a word past the preview: %s", err)
+	}
+
+	if len(posts) != 1 || posts[0].ID != post.ID {
+		t.Fatalf("search found %d posts, want the inserted one", len(posts))
+	}
+
+	if !strings.Contains(posts[0].Highlight, "<mark>"+word+"</mark>") {
+		t.Fatalf("highlight %q does not mark %s", posts[0].Highlight, word)
+	}
+
+	if strings.Contains(posts[0].Highlight, "<script>") {
+		t.Fatalf("highlight %q is not escaped", posts[0].Highlight)
+	}
+
+	// Part of a word is no lexeme, so only the substring match over the full body finds it
+	_, count, err := repo.Search(ctx, word[1:], 1, "")
+	if err != nil || count != 1 {
+		t.Fatalf("substring search past the preview found %d posts: %v", count, err)
+	}
+
+	// A title-only update keeps the body indexed
+	err = repo.Update(ctx, &entity.PostUpdateInput{ID: post.ID, Title: "renamed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, count, err = repo.Search(ctx, word, 1, "")
+	if err != nil || count != 1 {
+		t.Fatalf("search after a title-only update found %d posts: %v", count, err)
+	}
+
+	err = repo.Update(ctx, &entity.PostUpdateInput{ID: post.ID, Content: "replaced"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = repo.Search(ctx, word, 1, "")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("search after replacing the body returned %v, want no rows", err)
+	}
+}
+
+// TestArchiveExpiredKeepsFiles runs against a migrated database given by PG_URL
+func TestArchiveExpiredKeepsFiles(t *testing.T) {
+	url := os.Getenv("PG_URL")
+	if url == "" {
+		t.Skip("PG_URL not set")
+	}
+
+	ctx := context.Background()
+
+	db, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	contents, err := contentstore.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewPostRepository(db, contents, contentstore.Compression{})
+
+	suffix := time.Now().String()
+	files := []entity.PostFile{
+		{Filename: "main.go", Language: "go", Content: "package main\n" + suffix},
+		{Filename: "README.md", Language: "markdown", Content: "# readme\n" + suffix},
+	}
+
+	post := entity.NewPost(nil, "archived", "", "text", "", false, entity.Public, time.Now().Add(time.Hour), false)
+	post.Files = files
+
+	err = repo.Insert(ctx, post)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Exec(ctx, "DELETE FROM archived_posts WHERE id = $1", post.ID)
+
+	// Sweep as if the post had expired, with a limit large enough to reach it
+	_, err = repo.ArchiveExpired(ctx, time.Now().Add(2*time.Hour), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		removed, err := repo.DeleteUnreferencedContent(ctx, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if removed < 100 {
+			break
+		}
+	}
+
+	line, err := db.Query(ctx, "SELECT f.filename, f.content_key, b.codec FROM archived_post_files f JOIN content_blobs b ON b.key = f.content_key WHERE f.post_id = $1 ORDER BY f.position", post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer line.Close()
+
+	var archived int
+
+	for line.Next() {
+		var filename, key, codec string
+		if err := line.Scan(&filename, &key, &codec); err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := contents.Get(ctx, contentstore.Name(key, codec))
+		if err != nil {
+			t.Fatalf("body of archived file %s was collected: %v", filename, err)
+		}
+
+		if string(body) != files[archived].Content {
+			t.Fatalf("archived file %s holds %q, want %q", filename, body, files[archived].Content)
+		}
+
+		archived++
+	}
+
+	if err := line.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if archived != len(files) {
+		t.Fatalf("archived %d files, want %d", archived, len(files))
+	}
+}
@@ -26,7 +26,7 @@ func BenchmarkSearch(b *testing.B) {
 	query := "CAIXETA"
 
 	for i := 0; i < b.N; i++ {
-		_, _, err := repo.Search(context.Background(), query, 1)
+		_, _, err := repo.Search(context.Background(), query, 1, "")
 		if err != nil {
 			b.Fatal(err)
 		}
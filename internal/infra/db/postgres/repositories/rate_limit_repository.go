@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/Caixetadev/snippet/pkg/ratelimit"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ services.RateLimitStore = (*rateLimitRepository)(nil)
+
+type rateLimitRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRateLimitRepository(db *pgxpool.Pool) *rateLimitRepository {
+	return &rateLimitRepository{db: db}
+}
+
+// TakeToken locks the bucket row while the token is taken, so that instances sharing
+// the database never hand out the same token twice
+func (rr *rateLimitRepository) TakeToken(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	tx, err := rr.db.Begin(ctx)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	// A new bucket starts full. Inserting it first gives the next statement a row to lock.
+	_, err = tx.Exec(
+		ctx,
+		"INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO NOTHING",
+		key, float64(limit.Burst), now,
+	)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	var bucket ratelimit.Bucket
+
+	err = tx.QueryRow(
+		ctx,
+		"SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE",
+		key,
+	).Scan(&bucket.Tokens, &bucket.UpdatedAt)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	bucket, result := limit.Take(bucket, now)
+
+	_, err = tx.Exec(
+		ctx,
+		"UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3 WHERE key = $1",
+		key, bucket.Tokens, bucket.UpdatedAt,
+	)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	return result, tx.Commit(ctx)
+}
+
+func (rr *rateLimitRepository) PruneBuckets(ctx context.Context, before time.Time) (int, error) {
+	tag, err := rr.db.Exec(ctx, "DELETE FROM rate_limit_buckets WHERE updated_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
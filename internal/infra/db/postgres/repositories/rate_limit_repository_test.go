@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/pkg/ratelimit"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestTakeTokenConcurrent runs against a migrated database given by PG_URL
+func TestTakeTokenConcurrent(t *testing.T) {
+	url := os.Getenv("PG_URL")
+	if url == "" {
+		t.Skip("PG_URL not set")
+	}
+
+	db, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	repo := NewRateLimitRepository(db)
+	key := "test:" + uuid.NewString()
+	limit := ratelimit.Limit{Burst: 5, Period: time.Hour}
+	now := time.Now()
+
+	defer db.Exec(context.Background(), "DELETE FROM rate_limit_buckets WHERE key = $1", key)
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			result, err := repo.TakeToken(context.Background(), key, limit, now)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if result.Allowed {
+				allowed.Add(1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if got := allowed.Load(); got != int32(limit.Burst) {
+		t.Fatalf("allowed %d requests, want %d", got, limit.Burst)
+	}
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+)
+
+// searchIndexSize caps how many bytes of a body are indexed for search. A tsvector
+// cannot exceed 1 MB, which a large body of distinct words would.
+const searchIndexSize = 256 << 10
+
+// bodyVector is the body_vector of the text bound to param
+func bodyVector(param string) string {
+	return "to_tsvector('simple', " + param + "::text)"
+}
+
+// searchableContent returns the part of content indexed for search, or nil for sealed
+// content, which is never indexed
+func searchableContent(content string, sealed bool) *string {
+	if sealed {
+		return nil
+	}
+
+	searchable := entity.TruncateContent(content, searchIndexSize)
+
+	return &searchable
+}
+
+// buildTSQuery converts a user search query into a to_tsquery expression.
+//
+// Terms are AND-ed together, "quoted phrases" must appear in order, a trailing
+// * makes a prefix query, a leading - negates a term and a bare OR combines the
+// terms around it. Identifiers such as snake_case are split into adjacent lexemes
+// the same way to_tsvector splits them. Dotted names such as foo.bar or main.go are
+// kept whole: the parser indexes them as a single host or file lexeme, and to_tsquery
+// runs the same parser over them, so both sides split them alike.
+func buildTSQuery(q string) string {
+	var query strings.Builder
+
+	operator := ""
+
+	for _, token := range tokenizeSearchQuery(q) {
+		if token == "OR" {
+			if query.Len() > 0 {
+				operator = " | "
+			}
+			continue
+		}
+
+		negate := false
+		if strings.HasPrefix(token, "-") && len(token) > 1 {
+			negate = true
+			token = token[1:]
+		}
+
+		prefix := strings.HasSuffix(token, "*")
+
+		lexemes := searchLexemes(token)
+		if len(lexemes) == 0 {
+			continue
+		}
+
+		if prefix {
+			lexemes[len(lexemes)-1] += ":*"
+		}
+
+		term := strings.Join(lexemes, " <-> ")
+		if len(lexemes) > 1 {
+			term = "(" + term + ")"
+		}
+
+		if negate {
+			term = "!" + term
+		}
+
+		if query.Len() > 0 {
+			if operator == "" {
+				operator = " & "
+			}
+			query.WriteString(operator)
+		}
+
+		query.WriteString(term)
+		operator = ""
+	}
+
+	return query.String()
+}
+
+// tokenizeSearchQuery splits q on whitespace, keeping "quoted phrases" together
+func tokenizeSearchQuery(q string) []string {
+	var tokens []string
+	var current strings.Builder
+
+	inPhrase := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range q {
+		switch {
+		case r == '"':
+			flush()
+			inPhrase = !inPhrase
+		case unicode.IsSpace(r) && !inPhrase:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	flush()
+
+	return tokens
+}
+
+// searchLexemes splits a token into its alphanumeric parts, keeping the dots that join
+// two of them
+func searchLexemes(token string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(token), func(r rune) bool {
+		return r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var lexemes []string
+
+	for _, field := range fields {
+		for _, part := range strings.Split(field, "..") {
+			part = strings.Trim(part, ".")
+			if part != "" {
+				lexemes = append(lexemes, part)
+			}
+		}
+	}
+
+	return lexemes
+}
+
+// highlightStart and highlightStop delimit the matches in a headline. They are control
+// characters removed from bodies before highlighting, so they only ever mark matches.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// headlineOptions are the ts_headline options of search highlights
+const headlineOptions = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", MaxFragments=3, MaxWords=20, MinWords=5"
+
+// highlightable returns body without the characters that delimit matches
+func highlightable(body string) string {
+	return strings.NewReplacer(highlightStart, "", highlightStop, "").Replace(body)
+}
+
+// markHighlight escapes a headline for HTML and wraps its matches in <mark>
+func markHighlight(headline string) string {
+	return strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>").Replace(html.EscapeString(headline))
+}
+
+// escapeLikePattern escapes the LIKE wildcards in s so it is matched literally
+func escapeLikePattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,57 @@
+package repository
+
+import "testing"
+
+func TestBuildTSQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", ""},
+		{"hello world", "hello & world"},
+		{`"connection refused" postgres`, "(connection <-> refused) & postgres"},
+		{"sel*", "sel:*"},
+		{"nginx -apache", "nginx & !apache"},
+		{"yaml OR json", "yaml | json"},
+		{"OR yaml OR", "yaml"},
+		{"get_user_by_id", "(get <-> user <-> by <-> id)"},
+		{"http.Handle*", "http.handle:*"},
+		{"main.go", "main.go"},
+		{"os.Getenv(\"PG_URL\")", "os.getenv & (pg <-> url)"},
+		{"end. ..start", "end & start"},
+		{"'); DROP TABLE posts; --", "drop & table & posts"},
+	}
+
+	for _, tt := range tests {
+		got := buildTSQuery(tt.query)
+		if got != tt.want {
+			t.Errorf("buildTSQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeLikePattern(t *testing.T) {
+	got := escapeLikePattern(`100%_done\`)
+	want := `100\%\_done\\`
+
+	if got != want {
+		t.Errorf("escapeLikePattern() = %q, want %q", got, want)
+	}
+}
+
+func TestMarkHighlight(t *testing.T) {
+	body := highlightable("<script>alert(1)</script> \x02bold\x03 needle")
+	if body != "<script>alert(1)</script> bold needle" {
+		t.Fatalf("highlightable() = %q, the delimiters were not removed", body)
+	}
+
+	// What ts_headline returns for the body when searching for needle
+	headline := "<script>alert(1)</script> bold " + highlightStart + "needle" + highlightStop
+
+	got := markHighlight(headline)
+	want := "&lt;script&gt;alert(1)&lt;/script&gt; bold <mark>needle</mark>"
+
+	if got != want {
+		t.Errorf("markHighlight() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ services.TwoFactorRepository = (*twoFactorRepository)(nil)
+
+type twoFactorRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewTwoFactorRepository(db *pgxpool.Pool) *twoFactorRepository {
+	return &twoFactorRepository{db: db}
+}
+
+func (tr *twoFactorRepository) FindTwoFactor(ctx context.Context, userID uuid.UUID) (*entity.TwoFactor, error) {
+	query := "SELECT id, COALESCE(totp_secret, ''), totp_enabled, totp_last_step FROM users WHERE id = $1"
+
+	var twoFactor entity.TwoFactor
+
+	err := tr.db.QueryRow(ctx, query, userID).Scan(
+		&twoFactor.UserID,
+		&twoFactor.Secret,
+		&twoFactor.Enabled,
+		&twoFactor.LastStep,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &twoFactor, nil
+}
+
+// SetTOTPSecret stores a pending secret. It returns pgx.ErrNoRows when 2FA is already enabled.
+func (tr *twoFactorRepository) SetTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	query := "UPDATE users SET totp_secret = $2, totp_last_step = NULL WHERE id = $1 AND totp_enabled = false"
+
+	tag, err := tr.db.Exec(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// EnableTOTP turns 2FA on and replaces the user's recovery codes
+func (tr *twoFactorRepository) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codes []*entity.RecoveryCode) error {
+	tx, err := tr.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "UPDATE users SET totp_enabled = true, totp_last_step = $2 WHERE id = $1", userID, step)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		_, err = tx.Exec(
+			ctx,
+			"INSERT INTO recovery_codes (id, user_id, code_hash, created_at) VALUES ($1, $2, $3, $4)",
+			code.ID,
+			code.UserID,
+			code.CodeHash,
+			code.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UseTOTPStep records step as the last accepted one. It returns pgx.ErrNoRows when
+// a code from that step or a later one was already used.
+func (tr *twoFactorRepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	query := "UPDATE users SET totp_last_step = $2 WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)"
+
+	tag, err := tr.db.Exec(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// UseRecoveryCode burns an unused recovery code. It returns pgx.ErrNoRows when there is none.
+func (tr *twoFactorRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string, now time.Time) error {
+	query := "UPDATE recovery_codes SET used_at = $3 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL"
+
+	tag, err := tr.db.Exec(ctx, query, userID, codeHash, now)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (tr *twoFactorRepository) CreateSigninChallenge(ctx context.Context, challenge *entity.SigninChallenge) error {
+	query := "INSERT INTO signin_challenges (id, user_id, token_hash, expires_at, created_at) VALUES ($1, $2, $3, $4, $5)"
+
+	_, err := tr.db.Exec(
+		ctx,
+		query,
+		challenge.ID,
+		challenge.UserID,
+		challenge.TokenHash,
+		challenge.ExpiresAt,
+		challenge.CreatedAt,
+	)
+
+	return err
+}
+
+func (tr *twoFactorRepository) FindSigninChallenge(ctx context.Context, tokenHash string, now time.Time) (*entity.SigninChallenge, error) {
+	query := "SELECT id, user_id, token_hash, attempts, expires_at, created_at FROM signin_challenges WHERE token_hash = $1 AND expires_at > $2"
+
+	var challenge entity.SigninChallenge
+
+	err := tr.db.QueryRow(ctx, query, tokenHash, now).Scan(
+		&challenge.ID,
+		&challenge.UserID,
+		&challenge.TokenHash,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+		&challenge.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+// FailSigninChallenge counts a wrong code and discards the challenge after maxAttempts
+func (tr *twoFactorRepository) FailSigninChallenge(ctx context.Context, id uuid.UUID, maxAttempts int) error {
+	tx, err := tr.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "UPDATE signin_challenges SET attempts = attempts + 1 WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM signin_challenges WHERE id = $1 AND attempts >= $2", id, maxAttempts)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeleteSigninChallenge redeems a challenge. It returns pgx.ErrNoRows when it is already gone.
+func (tr *twoFactorRepository) DeleteSigninChallenge(ctx context.Context, id uuid.UUID) error {
+	tag, err := tr.db.Exec(ctx, "DELETE FROM signin_challenges WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/Caixetadev/snippet/internal/entity"
 	"github.com/Caixetadev/snippet/internal/services"
@@ -21,7 +22,7 @@ func NewUserRepository(db *pgxpool.Pool) *userRepository {
 }
 
 func (ur *userRepository) Insert(ctx context.Context, user *entity.User) error {
-	query := "INSERT INTO users (id, name, email, password) VALUES ($1, $2, $3, $4)"
+	query := "INSERT INTO users (id, name, email, password, email_verified) VALUES ($1, $2, $3, $4, $5)"
 
 	_, err := ur.db.Exec(
 		ctx,
@@ -30,6 +31,7 @@ func (ur *userRepository) Insert(ctx context.Context, user *entity.User) error {
 		user.Name,
 		user.Email,
 		user.Password,
+		user.EmailVerified,
 	)
 
 	return err
@@ -37,7 +39,7 @@ func (ur *userRepository) Insert(ctx context.Context, user *entity.User) error {
 
 // GetUserByEmail make a query in database and return an user or error
 func (ur *userRepository) FindOneByEmail(ctx context.Context, email string) (*entity.User, error) {
-	query := "SELECT id, name, email, password FROM users WHERE email = $1"
+	query := "SELECT id, name, email, password, email_verified, totp_enabled FROM users WHERE email = $1"
 
 	line, err := ur.db.Query(ctx, query, email)
 	if err != nil {
@@ -49,7 +51,7 @@ func (ur *userRepository) FindOneByEmail(ctx context.Context, email string) (*en
 	var user entity.User
 
 	if line.Next() {
-		if err = line.Scan(&user.ID, &user.Name, &user.Email, &user.Password); err != nil {
+		if err = line.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.EmailVerified, &user.TwoFactorEnabled); err != nil {
 			return nil, err
 		}
 	} else {
@@ -60,7 +62,7 @@ func (ur *userRepository) FindOneByEmail(ctx context.Context, email string) (*en
 }
 
 func (ur *userRepository) FindOneByID(ctx context.Context, id uuid.UUID) (*entity.User, error) {
-	query := "SELECT id, name, email FROM users WHERE id = $1"
+	query := "SELECT id, name, email, email_verified, totp_enabled FROM users WHERE id = $1"
 
 	line, err := ur.db.Query(ctx, query, id)
 	if err != nil {
@@ -72,7 +74,7 @@ func (ur *userRepository) FindOneByID(ctx context.Context, id uuid.UUID) (*entit
 	var user entity.User
 
 	if line.Next() {
-		if err = line.Scan(&user.ID, &user.Name, &user.Email); err != nil {
+		if err = line.Scan(&user.ID, &user.Name, &user.Email, &user.EmailVerified, &user.TwoFactorEnabled); err != nil {
 			return nil, err
 		}
 	} else {
@@ -95,10 +97,18 @@ func (ur *userRepository) UserExistsByEmail(ctx context.Context, email string) (
 	return exists, nil
 }
 
-func (ur *userRepository) StoreVerificationData(ctx context.Context, verificationData *entity.VerificationData) error {
+// StoreVerificationData stores a password reset code together with the job that mails it
+func (ur *userRepository) StoreVerificationData(ctx context.Context, verificationData *entity.VerificationData, job *entity.Job) error {
+	tx, err := ur.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
 	query := "INSERT INTO password_reset (id, user_id, reset_token, expiration_datetime) VALUES ($1, $2, $3, $4)"
 
-	_, err := ur.db.Exec(
+	_, err = tx.Exec(
 		ctx,
 		query,
 		verificationData.ID,
@@ -106,6 +116,24 @@ func (ur *userRepository) StoreVerificationData(ctx context.Context, verificatio
 		verificationData.Code,
 		verificationData.ExpiresAt,
 	)
+	if err != nil {
+		return err
+	}
+
+	err = insertJob(ctx, tx, job)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ReplacePasswordHash swaps a password hash for an upgraded one, unless the
+// password was changed in the meantime
+func (ur *userRepository) ReplacePasswordHash(ctx context.Context, id uuid.UUID, oldHash string, newHash string) error {
+	query := "UPDATE users SET password = $3 WHERE id = $1 AND password = $2"
+
+	_, err := ur.db.Exec(ctx, query, id, oldHash, newHash)
 
 	return err
 }
@@ -144,19 +172,79 @@ func (ur *userRepository) UpdatePassword(ctx context.Context, password string, i
 	return err
 }
 
-func (ur *userRepository) CreateSession(ctx context.Context, session *entity.Session) error {
-	query := "INSERT INTO sessions (id, name, refresh_token, user_agent, client_ip, is_blocked, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+const insertSessionQuery = "INSERT INTO sessions (id, user_id, family_id, name, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
 
-	_, err := ur.db.Exec(
-		ctx,
-		query,
+func sessionArgs(session *entity.Session) []any {
+	return []any{
 		session.ID,
+		session.UserID,
+		session.FamilyID,
 		session.Name,
 		session.RefreshToken,
 		session.UserAgent,
 		session.ClientIp,
 		session.IsBlocked,
 		session.ExpiresAt,
+		session.CreatedAt,
+	}
+}
+
+func (ur *userRepository) CreateSession(ctx context.Context, session *entity.Session) error {
+	_, err := ur.db.Exec(ctx, insertSessionQuery, sessionArgs(session)...)
+
+	return err
+}
+
+// RotateSession revokes the session oldID and stores its replacement in one transaction.
+// It returns pgx.ErrNoRows when oldID was already revoked, which means the refresh
+// token was used twice.
+func (ur *userRepository) RotateSession(ctx context.Context, oldID uuid.UUID, session *entity.Session) error {
+	tx, err := ur.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, "UPDATE sessions SET is_revoked = true, revoked_reason = $2 WHERE id = $1 AND is_revoked = false", oldID, entity.SessionRotated)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	_, err = tx.Exec(ctx, insertSessionQuery, sessionArgs(session)...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (ur *userRepository) RevokeSessionFamily(ctx context.Context, familyID uuid.UUID) error {
+	query := "UPDATE sessions SET is_revoked = true, revoked_reason = $2 WHERE family_id = $1 AND is_revoked = false"
+
+	_, err := ur.db.Exec(ctx, query, familyID, entity.SessionReused)
+
+	return err
+}
+
+func (ur *userRepository) InsertSecurityEvent(ctx context.Context, event *entity.SecurityEvent) error {
+	query := "INSERT INTO security_events (id, user_id, type, session_id, family_id, user_agent, client_ip, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+
+	_, err := ur.db.Exec(
+		ctx,
+		query,
+		event.ID,
+		event.UserID,
+		event.Type,
+		event.SessionID,
+		event.FamilyID,
+		event.UserAgent,
+		event.ClientIp,
+		event.CreatedAt,
 	)
 
 	return err
@@ -189,7 +277,7 @@ func (ur *userRepository) GetRefreshTokenByToken(ctx context.Context, token stri
 }
 
 func (ur *userRepository) GetSession(ctx context.Context, id uuid.UUID) (*entity.Session, error) {
-	query := "SELECT id, name, refresh_token, is_blocked, is_revoked, expires_at FROM sessions WHERE id = $1 LIMIT 1"
+	query := "SELECT id, user_id, family_id, name, refresh_token, is_blocked, is_revoked, revoked_reason, expires_at FROM sessions WHERE id = $1 LIMIT 1"
 
 	line, err := ur.db.Query(
 		ctx,
@@ -205,7 +293,7 @@ func (ur *userRepository) GetSession(ctx context.Context, id uuid.UUID) (*entity
 	var session entity.Session
 
 	if line.Next() {
-		if err = line.Scan(&session.ID, &session.Name, &session.RefreshToken, &session.IsBlocked, &session.IsRevoked, &session.ExpiresAt); err != nil {
+		if err = line.Scan(&session.ID, &session.UserID, &session.FamilyID, &session.Name, &session.RefreshToken, &session.IsBlocked, &session.IsRevoked, &session.RevokedFor, &session.ExpiresAt); err != nil {
 			return nil, err
 		}
 	} else {
@@ -216,12 +304,134 @@ func (ur *userRepository) GetSession(ctx context.Context, id uuid.UUID) (*entity
 }
 
 func (ur *userRepository) RevokeRefreshToken(ctx context.Context, token string) error {
-	query := "UPDATE sessions SET is_revoked = $1 WHERE refresh_token = $2"
+	query := "UPDATE sessions SET is_revoked = true, revoked_reason = $1 WHERE refresh_token = $2 AND is_revoked = false"
 
-	_, err := ur.db.Exec(ctx, query, true, token)
+	_, err := ur.db.Exec(ctx, query, entity.SessionSignedOut, token)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// FindSessionsByUser returns the sessions of a user that are neither revoked nor expired
+func (ur *userRepository) FindSessionsByUser(ctx context.Context, userID uuid.UUID, now time.Time) ([]*entity.Session, error) {
+	query := `
+		SELECT id, user_id, name, user_agent, client_ip, expires_at, created_at
+		FROM sessions
+		WHERE user_id = $1 AND is_revoked = false AND expires_at > $2
+		ORDER BY created_at DESC
+	`
+
+	rows, err := ur.db.Query(ctx, query, userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := []*entity.Session{}
+
+	for rows.Next() {
+		var session entity.Session
+
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.Name,
+			&session.UserAgent,
+			&session.ClientIp,
+			&session.ExpiresAt,
+			&session.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeSession revokes a single session owned by userID. It returns pgx.ErrNoRows
+// when the session does not exist, belongs to someone else or is already revoked.
+func (ur *userRepository) RevokeSession(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	query := "UPDATE sessions SET is_revoked = true, revoked_reason = $3 WHERE id = $1 AND user_id = $2 AND is_revoked = false"
+
+	tag, err := ur.db.Exec(ctx, query, id, userID, entity.SessionSignedOut)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (ur *userRepository) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	query := "UPDATE sessions SET is_revoked = true, revoked_reason = $2 WHERE user_id = $1 AND is_revoked = false"
+
+	_, err := ur.db.Exec(ctx, query, userID, entity.SessionSignedOut)
+
+	return err
+}
+
+// StoreEmailVerification replaces any pending verification of the user with a new one
+// and enqueues the job that mails it
+func (ur *userRepository) StoreEmailVerification(ctx context.Context, verification *entity.EmailVerification, job *entity.Job) error {
+	tx, err := ur.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "DELETE FROM email_verifications WHERE user_id = $1", verification.UserID)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO email_verifications (id, user_id, token_hash, expires_at, created_at) VALUES ($1, $2, $3, $4, $5)"
+
+	_, err = tx.Exec(
+		ctx,
+		query,
+		verification.ID,
+		verification.UserID,
+		verification.TokenHash,
+		verification.ExpiresAt,
+		verification.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = insertJob(ctx, tx, job)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// VerifyEmail consumes an unexpired verification token and marks its user as verified.
+// It returns pgx.ErrNoRows when the token is unknown or expired.
+func (ur *userRepository) VerifyEmail(ctx context.Context, tokenHash string, now time.Time) error {
+	query := `
+		WITH consumed AS (
+			DELETE FROM email_verifications
+			WHERE token_hash = $1 AND expires_at > $2
+			RETURNING user_id
+		)
+		UPDATE users SET email_verified = true
+		WHERE id IN (SELECT user_id FROM consumed)
+		RETURNING id
+	`
+
+	var id uuid.UUID
+
+	return ur.db.QueryRow(ctx, query, tokenHash, now).Scan(&id)
+}
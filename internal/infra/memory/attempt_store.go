@@ -0,0 +1,98 @@
+// Package memory holds in-process implementations of storage interfaces, for single
+// instance deployments and development
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
+)
+
+var _ services.AttemptStore = (*AttemptStore)(nil)
+
+// AttemptStore keeps failed attempts in memory. The counts are lost on restart and
+// not shared between instances.
+type AttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]*entity.FailedAttempts
+}
+
+func NewAttemptStore() *AttemptStore {
+	return &AttemptStore{attempts: make(map[string]*entity.FailedAttempts)}
+}
+
+func (s *AttemptStore) FindAttempts(ctx context.Context, key string) (*entity.FailedAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts, ok := s.attempts[key]
+	if !ok {
+		return &entity.FailedAttempts{Key: key}, nil
+	}
+
+	copied := *attempts
+
+	return &copied, nil
+}
+
+func (s *AttemptStore) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*entity.FailedAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts, ok := s.attempts[key]
+	if !ok {
+		attempts = &entity.FailedAttempts{Key: key}
+		s.attempts[key] = attempts
+	}
+
+	if attempts.UpdatedAt.Before(now.Add(-window)) {
+		attempts.Failures = 0
+	}
+
+	attempts.Failures++
+	attempts.UpdatedAt = now
+
+	copied := *attempts
+
+	return &copied, nil
+}
+
+func (s *AttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts, ok := s.attempts[key]
+	if ok && until.After(attempts.LockedUntil) {
+		attempts.LockedUntil = until
+	}
+
+	return nil
+}
+
+func (s *AttemptStore) ResetAttempts(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+
+	return nil
+}
+
+func (s *AttemptStore) PruneAttempts(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+
+	for key, attempts := range s.attempts {
+		if attempts.UpdatedAt.Before(before) && attempts.LockedUntil.Before(before) {
+			delete(s.attempts, key)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/Caixetadev/snippet/pkg/ratelimit"
+)
+
+var _ services.RateLimitStore = (*RateLimitStore)(nil)
+
+// RateLimitStore keeps token buckets in memory. Every instance limits on its own.
+type RateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]ratelimit.Bucket
+}
+
+func NewRateLimitStore() *RateLimitStore {
+	return &RateLimitStore{buckets: make(map[string]ratelimit.Bucket)}
+}
+
+func (s *RateLimitStore) TakeToken(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, result := limit.Take(s.buckets[key], now)
+	s.buckets[key] = bucket
+
+	return result, nil
+}
+
+func (s *RateLimitStore) PruneBuckets(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+
+	for key, bucket := range s.buckets {
+		if bucket.UpdatedAt.Before(before) {
+			delete(s.buckets, key)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
@@ -1,10 +1,13 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
+	"github.com/Caixetadev/snippet/internal/entity"
 	"github.com/Caixetadev/snippet/internal/token"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
 	"github.com/gin-gonic/gin"
 )
 
@@ -12,7 +15,14 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
-func AuthPostMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+// AccessTokenVerifier resolves personal access tokens
+type AccessTokenVerifier interface {
+	Verify(ctx context.Context, token string) (*entity.PersonalAccessToken, error)
+}
+
+// AuthPostMiddleware authenticates the request with either a PASETO access token
+// or a personal access token. Requests without a token continue anonymously.
+func AuthPostMiddleware(tokenMaker token.Maker, accessTokens AccessTokenVerifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authorizationHeader := c.GetHeader("Authorization")
 
@@ -25,6 +35,20 @@ func AuthPostMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 
 		tokenString := parts[1]
 
+		if entity.IsAccessToken(tokenString) {
+			accessToken, err := accessTokens.Verify(c, tokenString)
+			if err != nil {
+				c.AbortWithError(http.StatusUnauthorized, err)
+				return
+			}
+
+			c.Set("x-user-id", accessToken.UserID.String())
+			c.Set("x-token-scopes", accessToken.Scopes)
+			c.Set("x-token-id", accessToken.ID.String())
+			c.Next()
+			return
+		}
+
 		payload, err := tokenMaker.VerifyToken(tokenString)
 		if err != nil {
 			c.AbortWithError(http.StatusUnauthorized, err)
@@ -35,3 +59,29 @@ func AuthPostMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequireScope rejects requests authenticated with a personal access token that
+// lacks scope. Session tokens and anonymous requests are not restricted.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, ok := c.Get("x-token-scopes")
+		if ok && !entity.HasScope(scopes.([]string), scope) {
+			c.AbortWithError(http.StatusForbidden, typesystem.Forbidden)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSession rejects requests authenticated with a personal access token
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("x-token-scopes"); ok {
+			c.AbortWithError(http.StatusForbidden, typesystem.Forbidden)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type EmailVerificationChecker interface {
+	IsEmailVerified(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
+// EmailVerification stores in "x-email-verified" whether the authenticated user has
+// verified their email. Anonymous requests are left untouched.
+func EmailVerification(users EmailVerificationChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.GetString("x-user-id"))
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		verified, err := users.IsEmailVerified(c, userID)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.Set("x-email-verified", verified)
+		c.Next()
+	}
+}
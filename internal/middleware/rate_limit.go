@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/pkg/ratelimit"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+	"github.com/gin-gonic/gin"
+)
+
+type RateLimiter interface {
+	Take(ctx context.Context, policy string, identity entity.RateLimitIdentity) (*ratelimit.Result, error)
+}
+
+// RateLimit counts requests against policy and rejects them with 429 once the caller's
+// bucket is empty. Callers are identified by their personal access token, their user or
+// their client address, in that order, so it must run after AuthPostMiddleware.
+func RateLimit(limiter RateLimiter, policy string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := limiter.Take(c, policy, RateLimitIdentity(c))
+		if err != nil {
+			// An unavailable store should not take the whole API down with it
+			log.Printf("rate limit: %s", err)
+			c.Next()
+			return
+		}
+
+		if result == nil {
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+
+		if !result.Allowed {
+			// AbortWithError would write the status before ErrorHandler adds Retry-After
+			c.Error(typesystem.NewTooManyRequestsError("Rate limit exceeded, try again later", result.RetryAfter))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RateLimitIdentity returns who the request is counted against
+func RateLimitIdentity(c *gin.Context) entity.RateLimitIdentity {
+	if tokenID := c.GetString("x-token-id"); tokenID != "" {
+		return entity.RateLimitIdentity{Kind: entity.RateLimitToken, Value: tokenID}
+	}
+
+	if userID := c.GetString("x-user-id"); userID != "" {
+		return entity.RateLimitIdentity{Kind: entity.RateLimitUser, Value: userID}
+	}
+
+	return entity.RateLimitIdentity{Kind: entity.RateLimitAnonymous, Value: c.ClientIP()}
+}
+
+func seconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
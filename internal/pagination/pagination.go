@@ -2,6 +2,7 @@ package pagination
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/Caixetadev/snippet/internal/entity"
 	"github.com/Caixetadev/snippet/internal/utils"
@@ -12,13 +13,18 @@ func GeneratePaginationInfo(count int, page int, path string) (*entity.Paginatio
 
 	totalPages := (count + limit - 1) / limit
 
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
 	nextPage, prevPage := "", ""
 	if totalPages > page {
-		nextPage = fmt.Sprintf("%s?page=%d", path, page+1)
+		nextPage = fmt.Sprintf("%s%spage=%d", path, separator, page+1)
 	}
 
 	if page > 1 {
-		prevPage = fmt.Sprintf("%s?page=%d", path, page-1)
+		prevPage = fmt.Sprintf("%s%spage=%d", path, separator, page-1)
 	}
 
 	pagination := &entity.PaginationInfo{
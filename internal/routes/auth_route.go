@@ -1,35 +1,62 @@
 package routes
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/Caixetadev/snippet/config"
 	"github.com/Caixetadev/snippet/internal/handlers"
 	repository "github.com/Caixetadev/snippet/internal/infra/db/postgres/repositories"
 	"github.com/Caixetadev/snippet/internal/services"
 	"github.com/Caixetadev/snippet/internal/token"
+	"github.com/Caixetadev/snippet/pkg/oidc"
 	"github.com/Caixetadev/snippet/pkg/passwordhash"
 	"github.com/Caixetadev/snippet/pkg/validation"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func NewAuthRouter(cfg *config.Config, db *pgxpool.Pool, group *gin.RouterGroup, validation validation.Validator, tokenMaker token.Maker) {
+func NewAuthRouter(cfg *config.Config, db *pgxpool.Pool, group *gin.RouterGroup, validation validation.Validator, tokenMaker token.Maker, passwordHasher passwordhash.PasswordHasher, lockout *services.LockoutService) {
 	ur := repository.NewUserRepository(db)
 
-	userService := services.NewUserService(ur, validation, &passwordhash.BcryptPasswordHasher{}, tokenMaker)
-	emailService, err := services.NewSimpleEmailService(cfg)
-	if err != nil {
-		panic(err)
-	}
+	userService := services.NewUserService(ur, validation, passwordHasher, tokenMaker)
 
 	ac := &handlers.AuthHandler{
-		UserService:  userService,
-		EmailService: emailService,
-		Env:          cfg,
+		UserService:      userService,
+		TwoFactorService: services.NewTwoFactorService(repository.NewTwoFactorRepository(db), lockout, cfg.TOTPIssuer),
+		OIDCService:      services.NewOIDCService(oidcProviders(cfg), repository.NewIdentityRepository(db), ur),
+		Lockout:          lockout,
+		Env:              cfg,
 	}
 
 	group.POST("/auth/signup", ac.Signup)
 	group.POST("/auth/signin", ac.Signin)
+	group.POST("/auth/signin/2fa", ac.SigninTwoFactor)
+	group.GET("/auth/oidc/:provider/start", ac.OIDCStart)
+	group.GET("/auth/oidc/:provider/callback", ac.OIDCCallback)
 	group.POST("/auth/forgot-password", ac.ForgotPassword)
 	group.POST("/auth/refresh-token", ac.RefreshToken)
+	group.POST("/auth/logout", ac.Logout)
+	group.POST("/auth/logout-all", ac.LogoutAll)
 	group.PUT("/auth/reset-password/:resetToken", ac.ResetPassword)
+	group.POST("/auth/verify-email/:token", ac.VerifyEmail)
+	group.POST("/auth/resend-verification", ac.ResendVerification)
+}
+
+func oidcProviders(cfg *config.Config) map[string]services.OIDCProvider {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	providers := make(map[string]services.OIDCProvider, len(cfg.OIDC))
+
+	for name, provider := range cfg.OIDC {
+		providers[name] = oidc.NewProvider(oidc.Config{
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  provider.RedirectURL,
+			Scopes:       provider.Scopes,
+		}, client)
+	}
+
+	return providers
 }
@@ -2,30 +2,51 @@ package routes
 
 import (
 	"github.com/Caixetadev/snippet/config"
+	"github.com/Caixetadev/snippet/internal/entity"
 	"github.com/Caixetadev/snippet/internal/handlers"
 	repository "github.com/Caixetadev/snippet/internal/infra/db/postgres/repositories"
+	"github.com/Caixetadev/snippet/internal/middleware"
 	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/Caixetadev/snippet/internal/token"
+	"github.com/Caixetadev/snippet/pkg/contentcrypto"
+	"github.com/Caixetadev/snippet/pkg/contentstore"
 	"github.com/Caixetadev/snippet/pkg/passwordhash"
 	"github.com/Caixetadev/snippet/pkg/validation"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func NewPostRouter(cfg *config.Config, db *pgxpool.Pool, group *gin.RouterGroup, validation validation.Validator) {
-	pr := repository.NewPostRepository(db)
+func NewPostRouter(cfg *config.Config, db *pgxpool.Pool, group *gin.RouterGroup, validation validation.Validator, tokenMaker token.Maker, passwordHasher passwordhash.PasswordHasher, lockout *services.LockoutService, limiter *services.RateLimitService, contents contentstore.Store, compression contentstore.Compression, contentKeys contentcrypto.Keys) {
+	pr := repository.NewPostRepository(db, contents, compression)
+	ur := repository.NewUserRepository(db)
 
-	postService := services.NewPostService(pr, validation, &passwordhash.BcryptPasswordHasher{})
+	postService := services.NewPostService(pr, validation, passwordHasher, lockout, entity.ContentLimits{
+		MaxSize:     cfg.ContentMaxSize,
+		MaxPostSize: cfg.ContentMaxPostSize,
+	}, contentKeys)
+
+	userService := services.NewUserService(ur, validation, passwordHasher, tokenMaker)
+	emailVerification := middleware.EmailVerification(userService)
 
 	pc := &handlers.PostHandler{
 		PostService: postService,
 		Env:         cfg,
 	}
 
-	group.POST("/post/create", pc.Post)
-	group.GET("/post/user/all", pc.GetPosts)
-	group.DELETE("/post/:id", pc.DeletePost)
-	group.PATCH("/post/:id", pc.UpdatePost)
-	group.GET("/post/search", pc.SearchPost)
-	group.GET("/post/:id", pc.GetPost)
-	group.GET("/post/all", pc.GetAllPublics)
+	group.POST("/post/create", middleware.RequireScope(entity.ScopePostWrite), middleware.RateLimit(limiter, entity.RateLimitPostCreate), emailVerification, pc.Post)
+	group.GET("/post/user/all", middleware.RequireScope(entity.ScopePostRead), pc.GetPosts)
+	group.DELETE("/post/:id", middleware.RequireScope(entity.ScopePostWrite), pc.DeletePost)
+	group.PATCH("/post/:id", middleware.RequireScope(entity.ScopePostWrite), pc.UpdatePost)
+	group.GET("/post/search", middleware.RequireScope(entity.ScopePostRead), middleware.RateLimit(limiter, entity.RateLimitSearch), pc.SearchPost)
+	group.GET("/post/:id", middleware.RequireScope(entity.ScopePostRead), pc.GetPost)
+	group.GET("/post/:id/revisions", middleware.RequireScope(entity.ScopePostRead), pc.GetRevisions)
+	group.GET("/post/:id/revisions/:n", middleware.RequireScope(entity.ScopePostRead), pc.GetRevision)
+	group.GET("/post/:id/diff", middleware.RequireScope(entity.ScopePostRead), pc.DiffRevisions)
+	group.POST("/post/:id/claim", middleware.RequireScope(entity.ScopePostWrite), pc.ClaimPost)
+	group.POST("/post/:id/fork", middleware.RequireScope(entity.ScopePostWrite), emailVerification, pc.Fork)
+	group.GET("/post/:id/html", middleware.RequireScope(entity.ScopePostRead), pc.GetPostHTML)
+	group.GET("/post/:id/archive", middleware.RequireScope(entity.ScopePostRead), pc.GetPostArchive)
+	group.GET("/raw/:id", middleware.RequireScope(entity.ScopePostRead), pc.GetRawPost)
+	group.GET("/raw/:id/:filename", middleware.RequireScope(entity.ScopePostRead), pc.GetRawPostFile)
+	group.GET("/post/all", middleware.RequireScope(entity.ScopePostRead), pc.GetAllPublics)
 }
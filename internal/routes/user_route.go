@@ -2,8 +2,10 @@ package routes
 
 import (
 	"github.com/Caixetadev/snippet/config"
+	"github.com/Caixetadev/snippet/internal/entity"
 	"github.com/Caixetadev/snippet/internal/handlers"
 	repository "github.com/Caixetadev/snippet/internal/infra/db/postgres/repositories"
+	"github.com/Caixetadev/snippet/internal/middleware"
 	"github.com/Caixetadev/snippet/internal/services"
 	"github.com/Caixetadev/snippet/internal/token"
 	"github.com/Caixetadev/snippet/pkg/passwordhash"
@@ -12,15 +14,33 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func NewUserRouter(cfg *config.Config, db *pgxpool.Pool, group *gin.RouterGroup, validation validation.Validator, tokenMaker token.Maker) {
+func NewUserRouter(cfg *config.Config, db *pgxpool.Pool, group *gin.RouterGroup, validation validation.Validator, tokenMaker token.Maker, passwordHasher passwordhash.PasswordHasher, lockout *services.LockoutService) {
 	ur := repository.NewUserRepository(db)
 
-	userService := services.NewUserService(ur, validation, &passwordhash.BcryptPasswordHasher{}, tokenMaker)
+	userService := services.NewUserService(ur, validation, passwordHasher, tokenMaker)
 
 	uc := &handlers.UserHandler{
 		UserService: userService,
 		Env:         cfg,
 	}
 
-	group.GET("/user", uc.GetAuthenticatedUser)
+	ac := &handlers.AccessTokenHandler{
+		AccessTokenService: services.NewAccessTokenService(repository.NewAccessTokenRepository(db), validation),
+		Env:                cfg,
+	}
+
+	tc := &handlers.TwoFactorHandler{
+		UserService:      userService,
+		TwoFactorService: services.NewTwoFactorService(repository.NewTwoFactorRepository(db), lockout, cfg.TOTPIssuer),
+		Env:              cfg,
+	}
+
+	group.GET("/user", middleware.RequireScope(entity.ScopeUserRead), uc.GetAuthenticatedUser)
+	group.GET("/user/sessions", middleware.RequireSession(), uc.GetSessions)
+	group.DELETE("/user/sessions/:id", middleware.RequireSession(), uc.RevokeSession)
+	group.POST("/user/2fa/setup", middleware.RequireSession(), tc.Setup)
+	group.POST("/user/2fa/confirm", middleware.RequireSession(), tc.Confirm)
+	group.POST("/user/tokens", middleware.RequireSession(), ac.CreateToken)
+	group.GET("/user/tokens", middleware.RequireSession(), ac.GetTokens)
+	group.DELETE("/user/tokens/:id", middleware.RequireSession(), ac.RevokeToken)
 }
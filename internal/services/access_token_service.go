@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+	"github.com/Caixetadev/snippet/pkg/validation"
+	"github.com/google/uuid"
+)
+
+var ErrAccessTokenExpiry = typesystem.NewHttpError(
+	"Token expiration must be in the future.",
+	"[Error: access_token_expiry]",
+	http.StatusBadRequest,
+)
+
+type AccessTokenRepository interface {
+	Insert(ctx context.Context, token *entity.PersonalAccessToken) error
+	FindAllByUser(ctx context.Context, userID uuid.UUID) ([]*entity.PersonalAccessToken, error)
+	Revoke(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	Use(ctx context.Context, tokenHash string, now time.Time) (*entity.PersonalAccessToken, error)
+}
+
+type AccessTokenService struct {
+	accessTokenRepo AccessTokenRepository
+	validation      validation.Validator
+}
+
+func NewAccessTokenService(accessTokenRepo AccessTokenRepository, validation validation.Validator) *AccessTokenService {
+	return &AccessTokenService{accessTokenRepo: accessTokenRepo, validation: validation}
+}
+
+// Create issues a new personal access token. The plaintext token is only returned here.
+func (as *AccessTokenService) Create(
+	ctx context.Context,
+	userID uuid.UUID,
+	input *entity.CreateAccessTokenRequest,
+) (*entity.CreateAccessTokenResponse, error) {
+	err := as.validation.Validate(input)
+	if err != nil {
+		return nil, typesystem.BadRequest
+	}
+
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, ErrAccessTokenExpiry
+	}
+
+	token, plaintext, err := entity.NewPersonalAccessToken(userID, input.Name, input.Scopes, input.ExpiresAt)
+	if err != nil {
+		return nil, typesystem.ServerError
+	}
+
+	err = as.accessTokenRepo.Insert(ctx, token)
+	if err != nil {
+		return nil, typesystem.ServerError
+	}
+
+	return &entity.CreateAccessTokenResponse{Token: plaintext, Info: token}, nil
+}
+
+func (as *AccessTokenService) List(ctx context.Context, userID uuid.UUID) ([]*entity.PersonalAccessToken, error) {
+	tokens, err := as.accessTokenRepo.FindAllByUser(ctx, userID)
+	if err != nil {
+		return nil, typesystem.ServerError
+	}
+
+	return tokens, nil
+}
+
+func (as *AccessTokenService) Revoke(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	err := as.accessTokenRepo.Revoke(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return typesystem.NotFound
+		}
+		return typesystem.ServerError
+	}
+
+	return nil
+}
+
+// Verify resolves a plaintext token to an active personal access token and records its use
+func (as *AccessTokenService) Verify(ctx context.Context, token string) (*entity.PersonalAccessToken, error) {
+	if !entity.IsAccessToken(token) {
+		return nil, typesystem.TokenInvalidError
+	}
+
+	accessToken, err := as.accessTokenRepo.Use(ctx, entity.HashAccessToken(token), time.Now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, typesystem.TokenInvalidError
+		}
+		return nil, typesystem.ServerError
+	}
+
+	return accessToken, nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"log"
+)
+
+type ContentMigrationRepository interface {
+	MoveInlineContent(ctx context.Context, limit int) (int, error)
+	IndexContent(ctx context.Context, limit int) (int, error)
+	CompressContent(ctx context.Context, after string, limit int) (string, int, error)
+}
+
+// ContentMigration counts the bodies a ContentMigrator brought up to date
+type ContentMigration struct {
+	Moved      int
+	Indexed    int
+	Compressed int
+}
+
+// ContentMigrator brings stored bodies up to date in batches: bodies still held inline
+// in the database are moved to the content store, bodies stored before full bodies were
+// indexed for search are indexed, and bodies stored before compression was enabled are
+// compressed
+type ContentMigrator struct {
+	repo      ContentMigrationRepository
+	batchSize int
+}
+
+func NewContentMigrator(repo ContentMigrationRepository, batchSize int) *ContentMigrator {
+	return &ContentMigrator{repo: repo, batchSize: batchSize}
+}
+
+// Run migrates every body and returns how many were moved, indexed and compressed
+func (cm *ContentMigrator) Run(ctx context.Context) (ContentMigration, error) {
+	var migration ContentMigration
+
+	for {
+		n, err := cm.repo.MoveInlineContent(ctx, cm.batchSize)
+		if err != nil {
+			return migration, err
+		}
+
+		if n == 0 {
+			break
+		}
+
+		migration.Moved += n
+		log.Printf("content migration: moved %d bodies to the content store", migration.Moved)
+
+		if ctx.Err() != nil {
+			return migration, ctx.Err()
+		}
+	}
+
+	for {
+		n, err := cm.repo.IndexContent(ctx, cm.batchSize)
+		if err != nil {
+			return migration, err
+		}
+
+		if n == 0 {
+			break
+		}
+
+		migration.Indexed += n
+		log.Printf("content migration: indexed %d bodies for search", migration.Indexed)
+
+		if ctx.Err() != nil {
+			return migration, ctx.Err()
+		}
+	}
+
+	after := ""
+
+	for {
+		last, n, err := cm.repo.CompressContent(ctx, after, cm.batchSize)
+		if err != nil {
+			return migration, err
+		}
+
+		if last == "" {
+			return migration, nil
+		}
+
+		after = last
+		migration.Compressed += n
+
+		if n > 0 {
+			log.Printf("content migration: compressed %d bodies", migration.Compressed)
+		}
+
+		if ctx.Err() != nil {
+			return migration, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+
+	"github.com/Caixetadev/snippet/config"
+	"github.com/Caixetadev/snippet/pkg/contentcrypto"
+	"github.com/Caixetadev/snippet/pkg/contentstore"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// NewContentCompression returns how bodies are compressed at rest
+func NewContentCompression(cfg *config.Config) (contentstore.Compression, error) {
+	if !contentstore.ValidCodec(cfg.ContentCompression) {
+		return contentstore.Compression{}, fmt.Errorf("unknown content compression %q", cfg.ContentCompression)
+	}
+
+	return contentstore.Compression{
+		Codec:     cfg.ContentCompression,
+		Threshold: cfg.ContentCompressionThreshold,
+	}, nil
+}
+
+// NewContentKeys returns the keys that encrypt post bodies at rest
+func NewContentKeys(cfg *config.Config) (contentcrypto.Keys, error) {
+	keys := contentcrypto.Keys{
+		Password: contentcrypto.PasswordParams{
+			Memory:      cfg.Argon2Memory,
+			Iterations:  cfg.Argon2Iterations,
+			Parallelism: cfg.Argon2Parallelism,
+		},
+	}
+
+	if cfg.ContentMasterKey == "" {
+		log.Print("CONTENT_MASTER_KEY is not set, private posts are stored unencrypted")
+		return keys, nil
+	}
+
+	masterKey, err := base64.StdEncoding.DecodeString(cfg.ContentMasterKey)
+	if err != nil || len(masterKey) != contentcrypto.KeySize {
+		return keys, fmt.Errorf("CONTENT_MASTER_KEY must be %d base64 encoded bytes", contentcrypto.KeySize)
+	}
+
+	keys.MasterKey = masterKey
+
+	return keys, nil
+}
+
+// NewContentStore builds the store selected by cfg.ContentStoreDriver
+func NewContentStore(cfg *config.Config) (contentstore.Store, error) {
+	switch cfg.ContentStoreDriver {
+	case contentstore.DriverFile, "":
+		return contentstore.NewFileStore(cfg.ContentStoreDir)
+	case contentstore.DriverS3:
+		if cfg.ContentS3Bucket == "" {
+			return nil, fmt.Errorf("content store: CONTENT_S3_BUCKET is required")
+		}
+
+		awsConfig := &aws.Config{
+			Region:           aws.String(cfg.AWSRegion),
+			Credentials:      credentials.NewStaticCredentials(cfg.AWSAccessKey, cfg.AWSSecretKey, ""),
+			S3ForcePathStyle: aws.Bool(cfg.ContentS3ForcePathStyle),
+		}
+
+		if cfg.ContentS3Endpoint != "" {
+			awsConfig.Endpoint = aws.String(cfg.ContentS3Endpoint)
+		}
+
+		sess, err := session.NewSession(awsConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		return contentstore.NewS3Store(s3.New(sess), cfg.ContentS3Bucket, cfg.ContentS3Prefix), nil
+	default:
+		return nil, fmt.Errorf("unknown content store driver %q", cfg.ContentStoreDriver)
+	}
+}
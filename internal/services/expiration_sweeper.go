@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type ExpiredPostRepository interface {
+	DeleteExpired(ctx context.Context, now time.Time, limit int) (int, error)
+	ArchiveExpired(ctx context.Context, now time.Time, limit int) (int, error)
+	DeleteUnreferencedContent(ctx context.Context, limit int) (int, error)
+}
+
+// ExpirationSweeper periodically removes expired posts in batches, then the stored
+// bodies no post refers to anymore
+type ExpirationSweeper struct {
+	postRepo  ExpiredPostRepository
+	interval  time.Duration
+	batchSize int
+	archive   bool
+}
+
+func NewExpirationSweeper(
+	postRepo ExpiredPostRepository,
+	interval time.Duration,
+	batchSize int,
+	archive bool,
+) *ExpirationSweeper {
+	return &ExpirationSweeper{
+		postRepo:  postRepo,
+		interval:  interval,
+		batchSize: batchSize,
+		archive:   archive,
+	}
+}
+
+// Run sweeps on every interval until ctx is cancelled. A non-positive interval disables the sweeper.
+func (es *ExpirationSweeper) Run(ctx context.Context) {
+	if es.interval <= 0 || es.batchSize <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(es.interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := es.Sweep(ctx, time.Now())
+		if err != nil {
+			log.Printf("expiration sweeper: %s", err)
+		} else if removed > 0 {
+			log.Printf("expiration sweeper: removed %d expired posts", removed)
+		}
+
+		if ctx.Err() == nil {
+			removed, err = es.CollectContent(ctx)
+			if err != nil {
+				log.Printf("expiration sweeper: %s", err)
+			} else if removed > 0 {
+				log.Printf("expiration sweeper: removed %d unreferenced bodies", removed)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep removes every post that expired before now, one batch at a time
+func (es *ExpirationSweeper) Sweep(ctx context.Context, now time.Time) (int, error) {
+	total := 0
+
+	for {
+		var removed int
+		var err error
+
+		if es.archive {
+			removed, err = es.postRepo.ArchiveExpired(ctx, now, es.batchSize)
+		} else {
+			removed, err = es.postRepo.DeleteExpired(ctx, now, es.batchSize)
+		}
+
+		if err != nil {
+			return total, err
+		}
+
+		total += removed
+
+		if removed < es.batchSize || ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+	}
+}
+
+// CollectContent removes every stored body that is no longer referenced, one batch at a
+// time. Bodies are shared between identical posts, so they cannot go with the post.
+func (es *ExpirationSweeper) CollectContent(ctx context.Context) (int, error) {
+	total := 0
+
+	for {
+		removed, err := es.postRepo.DeleteUnreferencedContent(ctx, es.batchSize)
+		if err != nil {
+			return total, err
+		}
+
+		total += removed
+
+		if removed < es.batchSize || ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/google/uuid"
+)
+
+type JobRepository interface {
+	Enqueue(ctx context.Context, job *entity.Job) error
+	Claim(ctx context.Context, queue string, now time.Time, staleBefore time.Time, limit int) ([]*entity.Job, error)
+	Complete(ctx context.Context, id uuid.UUID, now time.Time) error
+	Retry(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string, now time.Time) error
+	Bury(ctx context.Context, id uuid.UUID, lastError string, now time.Time) error
+}
+
+// JobHandler runs a single job. Returning an error schedules a retry.
+type JobHandler func(ctx context.Context, job *entity.Job) error
+
+const (
+	// JobBackoffBase is the delay before the first retry. It doubles on every attempt.
+	JobBackoffBase = 30 * time.Second
+	// JobBackoffMax caps the delay between retries
+	JobBackoffMax = time.Hour
+	// JobLockTimeout is how long a job may stay running before another worker
+	// assumes its worker died and claims it again
+	JobLockTimeout = 10 * time.Minute
+)
+
+// JobBackoff returns the delay before retrying a job that has failed attempts times
+func JobBackoff(attempts int) time.Duration {
+	delay := JobBackoffBase
+
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= JobBackoffMax {
+			return JobBackoffMax
+		}
+	}
+
+	return delay
+}
+
+// JobWorker polls one queue and runs its jobs. Several workers, in one or many
+// processes, can share a queue.
+type JobWorker struct {
+	jobRepo   JobRepository
+	queue     string
+	handler   JobHandler
+	interval  time.Duration
+	batchSize int
+}
+
+func NewJobWorker(
+	jobRepo JobRepository,
+	queue string,
+	handler JobHandler,
+	interval time.Duration,
+	batchSize int,
+) *JobWorker {
+	return &JobWorker{
+		jobRepo:   jobRepo,
+		queue:     queue,
+		handler:   handler,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run processes due jobs on every interval until ctx is cancelled. A non-positive interval disables the worker.
+func (jw *JobWorker) Run(ctx context.Context) {
+	if jw.interval <= 0 || jw.batchSize <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(jw.interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			processed, err := jw.Process(ctx, time.Now())
+			if err != nil {
+				log.Printf("job worker %s: %s", jw.queue, err)
+				break
+			}
+
+			// Keep draining while full batches come back
+			if processed < jw.batchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Process claims one batch of due jobs and runs them
+func (jw *JobWorker) Process(ctx context.Context, now time.Time) (int, error) {
+	jobs, err := jw.jobRepo.Claim(ctx, jw.queue, now, now.Add(-JobLockTimeout), jw.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, job := range jobs {
+		err = jw.run(ctx, job)
+		if err != nil {
+			return len(jobs), err
+		}
+	}
+
+	return len(jobs), nil
+}
+
+func (jw *JobWorker) run(ctx context.Context, job *entity.Job) error {
+	// A job claimed more often than allowed kept crashing or timing out its worker
+	if job.Attempts > job.MaxAttempts {
+		return jw.jobRepo.Bury(ctx, job.ID, "exceeded max attempts", time.Now())
+	}
+
+	err := jw.handle(ctx, job)
+	if err == nil {
+		return jw.jobRepo.Complete(ctx, job.ID, time.Now())
+	}
+
+	if job.Attempts >= job.MaxAttempts {
+		log.Printf("job worker %s: job %s is dead after %d attempts: %s", jw.queue, job.ID, job.Attempts, err)
+		return jw.jobRepo.Bury(ctx, job.ID, err.Error(), time.Now())
+	}
+
+	now := time.Now()
+
+	return jw.jobRepo.Retry(ctx, job.ID, now.Add(JobBackoff(job.Attempts)), err.Error(), now)
+}
+
+func (jw *JobWorker) handle(ctx context.Context, job *entity.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return jw.handler(ctx, job)
+}
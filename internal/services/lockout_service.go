@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Caixetadev/snippet/config"
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+)
+
+// AttemptStore keeps failed attempt counts. It is implemented in memory and in Postgres.
+type AttemptStore interface {
+	FindAttempts(ctx context.Context, key string) (*entity.FailedAttempts, error)
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*entity.FailedAttempts, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	ResetAttempts(ctx context.Context, key string) error
+	PruneAttempts(ctx context.Context, before time.Time) (int, error)
+}
+
+// LockoutService slows down password guessing by locking accounts, client addresses,
+// pastes and second factors after repeated failures
+type LockoutService struct {
+	store    AttemptStore
+	policies map[string]entity.LockoutPolicy
+}
+
+// LockoutPolicies returns the configured policy of each attempt scope
+func LockoutPolicies(cfg *config.Config) map[string]entity.LockoutPolicy {
+	policy := entity.LockoutPolicy{
+		Threshold: cfg.LockoutThreshold,
+		BaseDelay: cfg.LockoutBaseDelay,
+		MaxDelay:  cfg.LockoutMaxDelay,
+		Window:    cfg.LockoutWindow,
+	}
+
+	ipPolicy := policy
+	ipPolicy.Threshold = cfg.LockoutIPThreshold
+
+	return map[string]entity.LockoutPolicy{
+		entity.AttemptScopeAccount:   policy,
+		entity.AttemptScopePost:      policy,
+		entity.AttemptScopeTwoFactor: policy,
+		entity.AttemptScopeIP:        ipPolicy,
+	}
+}
+
+// NewLockoutService takes a policy per attempt scope. Keys of scopes without a policy are not tracked.
+func NewLockoutService(store AttemptStore, policies map[string]entity.LockoutPolicy) *LockoutService {
+	return &LockoutService{store: store, policies: policies}
+}
+
+// Check returns a typesystem.TooManyRequests error while any of keys is locked
+func (ls *LockoutService) Check(ctx context.Context, keys ...entity.AttemptKey) error {
+	now := time.Now()
+
+	var retryAfter time.Duration
+
+	for _, key := range ls.tracked(keys) {
+		attempts, err := ls.store.FindAttempts(ctx, key.String())
+		if err != nil {
+			return typesystem.ServerError
+		}
+
+		if wait := attempts.LockedUntil.Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if retryAfter > 0 {
+		return typesystem.NewTooManyRequestsError("Too many failed attempts, try again later", retryAfter)
+	}
+
+	return nil
+}
+
+// Fail counts a failed attempt against every key and locks the keys that reached their threshold
+func (ls *LockoutService) Fail(ctx context.Context, keys ...entity.AttemptKey) error {
+	now := time.Now()
+
+	for _, key := range ls.tracked(keys) {
+		policy := ls.policies[key.Scope]
+
+		attempts, err := ls.store.RecordFailure(ctx, key.String(), now, policy.Window)
+		if err != nil {
+			return typesystem.ServerError
+		}
+
+		lockFor := policy.LockFor(attempts.Failures)
+		if lockFor <= 0 {
+			continue
+		}
+
+		err = ls.store.Lock(ctx, key.String(), now.Add(lockFor))
+		if err != nil {
+			return typesystem.ServerError
+		}
+	}
+
+	return nil
+}
+
+// Reset forgets the failures of keys after a successful attempt
+func (ls *LockoutService) Reset(ctx context.Context, keys ...entity.AttemptKey) error {
+	for _, key := range ls.tracked(keys) {
+		err := ls.store.ResetAttempts(ctx, key.String())
+		if err != nil {
+			return typesystem.ServerError
+		}
+	}
+
+	return nil
+}
+
+// Run removes stale attempt counts on every interval until ctx is cancelled
+func (ls *LockoutService) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		_, err := ls.store.PruneAttempts(ctx, time.Now().Add(-ls.longestWindow()))
+		if err != nil {
+			log.Printf("lockout: %s", err)
+		}
+	}
+}
+
+// tracked drops keys without a policy or without a value, such as the address of a
+// request that did not come through gin
+func (ls *LockoutService) tracked(keys []entity.AttemptKey) []entity.AttemptKey {
+	tracked := make([]entity.AttemptKey, 0, len(keys))
+
+	for _, key := range keys {
+		if _, ok := ls.policies[key.Scope]; ok && key.Value != "" {
+			tracked = append(tracked, key)
+		}
+	}
+
+	return tracked
+}
+
+func (ls *LockoutService) longestWindow() time.Duration {
+	var window time.Duration
+
+	for _, policy := range ls.policies {
+		window = max(window, policy.Window, policy.MaxDelay)
+	}
+
+	return window
+}
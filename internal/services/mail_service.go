@@ -1,46 +1,76 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+
 	"github.com/Caixetadev/snippet/config"
 	"github.com/Caixetadev/snippet/internal/entity"
-	"github.com/Caixetadev/snippet/pkg/typesystem"
+	"github.com/Caixetadev/snippet/pkg/mail"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ses"
 )
 
-type SimpleEmailService struct {
-	sesClient *ses.SES
-	Env       *config.Config
-}
+// NewMailTransport builds the transport selected by cfg.MailDriver
+func NewMailTransport(cfg *config.Config) (mail.Transport, error) {
+	switch cfg.MailDriver {
+	case mail.DriverSES, "":
+		sess, err := session.NewSession(&aws.Config{
+			Region:      aws.String(cfg.AWSRegion),
+			Credentials: credentials.NewStaticCredentials(cfg.AWSSecretKey, cfg.AWSAccessKey, ""),
+		})
+		if err != nil {
+			return nil, err
+		}
 
-func NewSimpleEmailService(cfg *config.Config) (*SimpleEmailService, error) {
-	sess, err := session.NewSession(&aws.Config{
-		Region:      aws.String(cfg.AWSRegion),
-		Credentials: credentials.NewStaticCredentials(cfg.AWSSecretKey, cfg.AWSAccessKey, ""),
-	})
-	if err != nil {
-		return nil, err
+		return mail.NewSESTransport(ses.New(sess)), nil
+	case mail.DriverSMTP:
+		return &mail.SMTPTransport{
+			Host:       cfg.SMTPHost,
+			Port:       cfg.SMTPPort,
+			Username:   cfg.SMTPUsername,
+			Password:   cfg.SMTPPassword,
+			RequireTLS: cfg.SMTPStartTLS,
+		}, nil
+	case mail.DriverFile:
+		return mail.NewFileTransport(cfg.MailOutboxDir)
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.MailDriver)
 	}
-
-	return &SimpleEmailService{
-		sesClient: ses.New(sess),
-		Env:       cfg,
-	}, nil
 }
 
-func (e *SimpleEmailService) SendResetPasswordEmail(user *entity.User) (string, error) {
-	mailData := entity.NewMailData(user.Name)
+type MailService struct {
+	transport mail.Transport
+	Env       *config.Config
+}
 
-	htmlBody := entity.GetHTMLTemplate(mailData)
+func NewMailService(transport mail.Transport, cfg *config.Config) *MailService {
+	return &MailService{
+		transport: transport,
+		Env:       cfg,
+	}
+}
 
-	input := entity.NewEmail(user.Email, htmlBody, "Reset Password", e.Env.AWSSenderEmail)
+// Deliver renders and sends the email carried by a job on entity.EmailQueue.
+// It is the JobHandler of the email queue.
+func (e *MailService) Deliver(ctx context.Context, job *entity.Job) error {
+	var email entity.Email
 
-	_, err := e.sesClient.SendEmail(input)
+	err := json.Unmarshal(job.Payload, &email)
 	if err != nil {
-		return "", typesystem.ServerError
+		return err
 	}
 
-	return mailData.Code, nil
+	msg := mail.NewMessage(
+		e.Env.Sender(),
+		email.To,
+		email.Subject,
+		entity.GetTextTemplate(email.Template, email.Data),
+		entity.GetHTMLTemplate(email.Template, email.Data),
+	)
+
+	return e.transport.Send(ctx, msg)
 }
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/pkg/oidc"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrUnknownOIDCProvider = typesystem.NewHttpError(
+		"Unknown identity provider.",
+		"[Error: unknown_identity_provider]",
+		http.StatusNotFound,
+	)
+	ErrOIDCLoginFailed = typesystem.NewHttpError(
+		"Signing in with the identity provider failed.",
+		"[Error: oidc_login_failed]",
+		http.StatusUnauthorized,
+	)
+	ErrOIDCEmailNotVerified = typesystem.NewHttpError(
+		"An account with this email already exists. The identity provider must verify the email before it can be linked.",
+		"[Error: oidc_email_not_verified]",
+		http.StatusConflict,
+	)
+)
+
+type OIDCProvider interface {
+	AuthCodeURL(ctx context.Context, state string, nonce string, verifier string) (string, error)
+	Exchange(ctx context.Context, code string, verifier string) (*oidc.Token, error)
+	VerifyIDToken(ctx context.Context, raw string, nonce string) (*oidc.Claims, error)
+}
+
+type IdentityRepository interface {
+	CreateOIDCState(ctx context.Context, state *entity.OIDCState) error
+	ConsumeOIDCState(ctx context.Context, stateHash string, now time.Time) (*entity.OIDCState, error)
+	FindUserByIdentity(ctx context.Context, provider, subject string) (*entity.User, error)
+	LinkIdentity(ctx context.Context, identity *entity.Identity) error
+	CreateUserWithIdentity(ctx context.Context, user *entity.User, identity *entity.Identity) error
+}
+
+type OIDCService struct {
+	providers      map[string]OIDCProvider
+	identityRepo   IdentityRepository
+	userRepository UserRepository
+}
+
+func NewOIDCService(providers map[string]OIDCProvider, identityRepo IdentityRepository, userRepository UserRepository) *OIDCService {
+	return &OIDCService{
+		providers:      providers,
+		identityRepo:   identityRepo,
+		userRepository: userRepository,
+	}
+}
+
+// Start begins a login at the identity provider. It returns the URL to send the user
+// to and the state that the callback must present.
+func (oc *OIDCService) Start(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := oc.providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownOIDCProvider
+	}
+
+	state, err := oidc.GenerateNonce()
+	if err != nil {
+		return "", "", typesystem.ServerError
+	}
+
+	nonce, err := oidc.GenerateNonce()
+	if err != nil {
+		return "", "", typesystem.ServerError
+	}
+
+	verifier, err := oidc.GenerateVerifier()
+	if err != nil {
+		return "", "", typesystem.ServerError
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		log.Printf("oidc %s: %s", providerName, err)
+		return "", "", typesystem.ServerError
+	}
+
+	err = oc.identityRepo.CreateOIDCState(ctx, entity.NewOIDCState(providerName, state, nonce, verifier))
+	if err != nil {
+		return "", "", typesystem.ServerError
+	}
+
+	return authURL, state, nil
+}
+
+// Callback completes a login: it redeems the authorization code, verifies the ID token
+// and returns the user it belongs to, creating or linking the account when needed
+func (oc *OIDCService) Callback(ctx context.Context, providerName string, state string, code string) (*entity.User, error) {
+	provider, ok := oc.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	oidcState, err := oc.identityRepo.ConsumeOIDCState(ctx, entity.HashToken(state), time.Now())
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, typesystem.TokenInvalidError
+		}
+		return nil, typesystem.ServerError
+	}
+
+	if oidcState.Provider != providerName {
+		return nil, typesystem.TokenInvalidError
+	}
+
+	token, err := provider.Exchange(ctx, code, oidcState.CodeVerifier)
+	if err != nil {
+		log.Printf("oidc %s: %s", providerName, err)
+		return nil, ErrOIDCLoginFailed
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, token.IDToken, oidcState.Nonce)
+	if err != nil {
+		log.Printf("oidc %s: %s", providerName, err)
+		return nil, ErrOIDCLoginFailed
+	}
+
+	return oc.resolveUser(ctx, providerName, claims)
+}
+
+// resolveUser finds the user of an identity. Unknown identities are linked to the account
+// with the same email, but only when the provider verified that email, so that nobody can
+// take over an account by registering its address at a provider. Otherwise a new user is created.
+func (oc *OIDCService) resolveUser(ctx context.Context, providerName string, claims *oidc.Claims) (*entity.User, error) {
+	user, err := oc.identityRepo.FindUserByIdentity(ctx, providerName, claims.Subject)
+	if err == nil {
+		return user, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, typesystem.ServerError
+	}
+
+	if claims.Email == "" {
+		return nil, ErrOIDCLoginFailed
+	}
+
+	user, err = oc.userRepository.FindOneByEmail(ctx, claims.Email)
+	if err == nil {
+		if !claims.EmailVerified {
+			return nil, ErrOIDCEmailNotVerified
+		}
+
+		err = oc.identityRepo.LinkIdentity(ctx, entity.NewIdentity(user.ID, providerName, claims.Subject, claims.Email))
+		if err != nil {
+			return nil, typesystem.ServerError
+		}
+
+		return user, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, typesystem.ServerError
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.Email
+	}
+
+	// Users created here have no password and sign in through the provider, or set
+	// one with the password reset flow
+	user = entity.NewUser(name, claims.Email, "")
+	user.EmailVerified = claims.EmailVerified
+
+	err = oc.identityRepo.CreateUserWithIdentity(ctx, user, entity.NewIdentity(user.ID, providerName, claims.Subject, claims.Email))
+	if err != nil {
+		return nil, typesystem.ServerError
+	}
+
+	return user, nil
+}
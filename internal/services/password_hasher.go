@@ -0,0 +1,16 @@
+package services
+
+import (
+	"github.com/Caixetadev/snippet/config"
+	"github.com/Caixetadev/snippet/pkg/passwordhash"
+)
+
+// NewPasswordHasher returns the password hasher selected in the configuration
+func NewPasswordHasher(cfg *config.Config) (passwordhash.PasswordHasher, error) {
+	params := passwordhash.DefaultArgon2Params
+	params.Memory = cfg.Argon2Memory
+	params.Iterations = cfg.Argon2Iterations
+	params.Parallelism = cfg.Argon2Parallelism
+
+	return passwordhash.New(cfg.PasswordHashAlgorithm, cfg.BcryptCost, params)
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+)
+
+const (
+	ArchiveZip = "zip"
+	ArchiveTar = "tar"
+)
+
+var ErrUnsupportedArchiveFormat = typesystem.NewHttpError(
+	"Archive format must be zip or tar.",
+	"[Error: unsupported_archive_format]",
+	http.StatusBadRequest,
+)
+
+// GetPostFile returns a single file of a multi-file post
+func (ps *PostService) GetPostFile(
+	ctx context.Context,
+	id string,
+	filename string,
+	userID string,
+	password string,
+) (*entity.PostFile, error) {
+	post, err := ps.findAccessiblePost(ctx, id, userID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	// A wrong file name must not burn the post
+	if findFile(post.Files, filename) == nil {
+		return nil, typesystem.NotFound
+	}
+
+	post, err = ps.readPost(ctx, post, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return findFile(post.Files, filename), nil
+}
+
+func findFile(files []entity.PostFile, filename string) *entity.PostFile {
+	for i := range files {
+		if files[i].Filename == filename {
+			return &files[i]
+		}
+	}
+
+	return nil
+}
+
+// ArchivePost bundles every file of a post into a zip or tar archive. A post
+// without files is bundled as a single text file named after its ID.
+func (ps *PostService) ArchivePost(
+	ctx context.Context,
+	id string,
+	format string,
+	userID string,
+	password string,
+) (*entity.PostArchive, error) {
+	if format == "" {
+		format = ArchiveZip
+	}
+
+	if format != ArchiveZip && format != ArchiveTar {
+		return nil, ErrUnsupportedArchiveFormat
+	}
+
+	post, err := ps.GetPost(ctx, id, userID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	files := post.Files
+	if len(files) == 0 {
+		files = []entity.PostFile{{Filename: fmt.Sprintf("%s.txt", post.ID), Content: post.Content}}
+	}
+
+	var data []byte
+	var contentType string
+
+	switch format {
+	case ArchiveTar:
+		data, err = buildTar(files, post.CreatedAt)
+		contentType = "application/x-tar"
+	default:
+		data, err = buildZip(files, post.CreatedAt)
+		contentType = "application/zip"
+	}
+
+	if err != nil {
+		return nil, typesystem.ServerError
+	}
+
+	return &entity.PostArchive{
+		Filename:    fmt.Sprintf("%s.%s", post.ID, format),
+		ContentType: contentType,
+		Data:        data,
+	}, nil
+}
+
+func buildZip(files []entity.PostFile, modified time.Time) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	writer := zip.NewWriter(&buffer)
+
+	for _, file := range files {
+		entry, err := writer.CreateHeader(&zip.FileHeader{
+			Name:     file.Filename,
+			Method:   zip.Deflate,
+			Modified: modified,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = entry.Write([]byte(file.Content))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func buildTar(files []entity.PostFile, modified time.Time) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	writer := tar.NewWriter(&buffer)
+
+	for _, file := range files {
+		err := writer.WriteHeader(&tar.Header{
+			Name:    file.Filename,
+			Mode:    0o644,
+			Size:    int64(len(file.Content)),
+			ModTime: modified,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = writer.Write([]byte(file.Content))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
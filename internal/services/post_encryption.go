@@ -0,0 +1,134 @@
+package services
+
+import (
+	"log"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/pkg/contentcrypto"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+)
+
+// sealPost encrypts the bodies of a new post. Password-protected posts are sealed with a
+// key derived from password, private posts with the master key when one is configured.
+// Other posts are stored as they are.
+func (ps *PostService) sealPost(post *entity.PostInput, password string) error {
+	encryption := entity.Unencrypted
+
+	switch {
+	case post.HasPassword:
+		encryption = entity.EncryptedWithPassword
+	case post.Visibility == entity.Private && len(ps.keys.MasterKey) > 0:
+		encryption = entity.EncryptedWithMasterKey
+	default:
+		return nil
+	}
+
+	dataKey, err := contentcrypto.NewKey()
+	if err != nil {
+		return typesystem.ServerError
+	}
+
+	var wrapped []byte
+
+	if encryption == entity.EncryptedWithPassword {
+		wrapped, err = contentcrypto.WrapWithPassword(dataKey, []byte(password), ps.keys.Password)
+	} else {
+		wrapped, err = contentcrypto.WrapWithKey(dataKey, ps.keys.MasterKey)
+	}
+
+	if err != nil {
+		return typesystem.ServerError
+	}
+
+	post.Content, err = sealContent(dataKey, post.Content)
+	if err != nil {
+		return err
+	}
+
+	for i := range post.Files {
+		post.Files[i].Content, err = sealContent(dataKey, post.Files[i].Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	post.Encryption = encryption
+	post.DataKey = wrapped
+
+	return nil
+}
+
+// dataKey unwraps the data key of a post. The password must already be checked.
+// Unencrypted posts have no data key.
+func (ps *PostService) dataKey(post *entity.PostOutput, password string) ([]byte, error) {
+	var dataKey []byte
+	var err error
+
+	switch post.Encryption {
+	case entity.Unencrypted:
+		return nil, nil
+	case entity.EncryptedWithPassword:
+		dataKey, err = contentcrypto.UnwrapWithPassword(post.DataKey, []byte(password))
+	case entity.EncryptedWithMasterKey:
+		dataKey, err = contentcrypto.UnwrapWithKey(post.DataKey, ps.keys.MasterKey)
+	default:
+		err = contentcrypto.ErrDecrypt
+	}
+
+	if err != nil {
+		log.Printf("unwrap data key of post %s: %s", post.ID, err)
+		return nil, typesystem.ServerError
+	}
+
+	return dataKey, nil
+}
+
+// openPost decrypts the bodies of a post in place
+func openPost(dataKey []byte, post *entity.PostOutput) error {
+	if dataKey == nil {
+		return nil
+	}
+
+	var err error
+
+	post.Content, err = openContent(dataKey, post.Content)
+	if err != nil {
+		return err
+	}
+
+	for i := range post.Files {
+		post.Files[i].Content, err = openContent(dataKey, post.Files[i].Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sealContent(dataKey []byte, content string) (string, error) {
+	if content == "" {
+		return "", nil
+	}
+
+	sealed, err := contentcrypto.Seal(dataKey, []byte(content))
+	if err != nil {
+		return "", typesystem.ServerError
+	}
+
+	return string(sealed), nil
+}
+
+// openContent decrypts a body. Empty content was never sealed.
+func openContent(dataKey []byte, content string) (string, error) {
+	if dataKey == nil || content == "" {
+		return content, nil
+	}
+
+	data, err := contentcrypto.Open(dataKey, []byte(content))
+	if err != nil {
+		return "", typesystem.ServerError
+	}
+
+	return string(data), nil
+}
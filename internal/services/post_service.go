@@ -1,19 +1,27 @@
 package services
 
 import (
+	"crypto/subtle"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Caixetadev/snippet/internal/entity"
 	"github.com/Caixetadev/snippet/internal/pagination"
+	"github.com/Caixetadev/snippet/pkg/contentcrypto"
+	"github.com/Caixetadev/snippet/pkg/highlight"
 	"github.com/Caixetadev/snippet/pkg/passwordhash"
+	"github.com/Caixetadev/snippet/pkg/pastecrypt"
 	"github.com/Caixetadev/snippet/pkg/typesystem"
 	"github.com/Caixetadev/snippet/pkg/validation"
 	"github.com/google/uuid"
+	"github.com/pmezard/go-difflib/difflib"
 	"golang.org/x/net/context"
 )
 
@@ -29,37 +37,101 @@ var (
 		http.StatusBadRequest,
 	)
 	ErrPasswordLength = typesystem.NewHttpError(
-		"Password should have a minimum of 3 characters.",
+		fmt.Sprintf("Password should have a minimum of %d characters.", entity.MinPostPasswordLength),
 		"[Error: password_length]",
 		http.StatusBadRequest,
 	)
+	ErrUnsupportedLanguage = typesystem.NewHttpError(
+		"The given language is not supported.",
+		"[Error: unsupported_language]",
+		http.StatusBadRequest,
+	)
+	ErrTooManyFiles = typesystem.NewHttpError(
+		fmt.Sprintf("A post can have at most %d files.", MaxPostFiles),
+		"[Error: too_many_files]",
+		http.StatusBadRequest,
+	)
+	ErrInvalidFilename = typesystem.NewHttpError(
+		"File names must not be empty or contain path separators.",
+		"[Error: invalid_filename]",
+		http.StatusBadRequest,
+	)
+	ErrDuplicateFilename = typesystem.NewHttpError(
+		"File names must be unique within a post.",
+		"[Error: duplicate_filename]",
+		http.StatusBadRequest,
+	)
+	ErrContentTooLarge = typesystem.NewHttpError(
+		"The post content exceeds the maximum size.",
+		"[Error: content_too_large]",
+		http.StatusRequestEntityTooLarge,
+	)
+	ErrInvalidEnvelope = typesystem.NewHttpError(
+		"The content of an encrypted post must be a valid encryption envelope.",
+		"[Error: invalid_envelope]",
+		http.StatusBadRequest,
+	)
+	ErrRenderEncrypted = typesystem.NewHttpError(
+		"Encrypted posts can only be decrypted by the client.",
+		"[Error: render_encrypted]",
+		http.StatusBadRequest,
+	)
+	ErrAlreadyClaimed = typesystem.NewHttpError(
+		"The post already belongs to an account.",
+		"[Error: already_claimed]",
+		http.StatusConflict,
+	)
+	ErrForkDeleteAfterView = typesystem.NewHttpError(
+		"Cannot fork a post that is deleted after being viewed.",
+		"[Error: fork_delete_after_view]",
+		http.StatusForbidden,
+	)
+	ErrInvalidRevision = typesystem.NewHttpError(
+		"Revision must be a positive integer.",
+		"[Error: invalid_revision]",
+		http.StatusBadRequest,
+	)
 )
 
+// MaxPostFiles is the maximum number of files in a multi-file post
+const MaxPostFiles = 20
+
 type PostRepository interface {
 	Insert(ctx context.Context, post *entity.PostInput) error
 	FindAll(ctx context.Context, id uuid.UUID, page int) ([]*entity.PostOutput, int, error)
-	FindAllPublics(ctx context.Context, page int) ([]*entity.PostOutput, int, error)
+	FindAllPublics(ctx context.Context, page int, language string) ([]*entity.PostOutput, int, error)
 	Delete(ctx context.Context, id string) error
 	CountUserPosts(ctx context.Context, id uuid.UUID) (int, error)
 	CountAllPostsPublics(ctx context.Context) (int, error)
 	CountPostsInSearch(ctx context.Context, query string) (int, error)
 	FindOneByID(ctx context.Context, id string) (*entity.PostOutput, error)
 	Update(ctx context.Context, post *entity.PostUpdateInput) error
-	Search(ctx context.Context, query string, page int) ([]*entity.PostOutput, int, error)
+	Search(ctx context.Context, query string, page int, language string) ([]*entity.PostOutput, int, error)
+	Burn(ctx context.Context, id string) (*entity.PostOutput, error)
+	FindRevisions(ctx context.Context, id string) ([]*entity.PostRevision, error)
+	FindRevision(ctx context.Context, id string, revision int) (*entity.PostRevision, error)
+	ReplacePasswordHash(ctx context.Context, id string, oldHash string, newHash string) error
+	Claim(ctx context.Context, id string, userID uuid.UUID, tokenHash string) error
 }
 
 type PostService struct {
 	postRepo       PostRepository
 	validation     validation.Validator
 	passwordHasher passwordhash.PasswordHasher
+	lockout        *LockoutService
+	limits         entity.ContentLimits
+	keys           contentcrypto.Keys
 }
 
 func NewPostService(
 	postRepo PostRepository,
 	validation validation.Validator,
 	passwordHasher passwordhash.PasswordHasher,
+	lockout *LockoutService,
+	limits entity.ContentLimits,
+	keys contentcrypto.Keys,
 ) *PostService {
-	return &PostService{postRepo: postRepo, validation: validation, passwordHasher: passwordHasher}
+	return &PostService{postRepo: postRepo, validation: validation, passwordHasher: passwordHasher, lockout: lockout, limits: limits, keys: keys}
 }
 
 func (ps *PostService) Create(ctx context.Context, input *entity.PostInput) error {
@@ -76,12 +148,26 @@ func (ps *PostService) Create(ctx context.Context, input *entity.PostInput) erro
 		return ErrDeleteAndViewConflict
 	}
 
+	err = ps.checkContentSize(input.Content, input.Files)
+	if err != nil {
+		return err
+	}
+
+	if input.Encrypted {
+		err = validateEnvelopes(input.Content, input.Files)
+		if err != nil {
+			return err
+		}
+	}
+
+	password := input.Password
+
 	if input.HasPassword {
-		if len(input.Password) < 3 {
+		if len(input.Password) < entity.MinPostPasswordLength {
 			return ErrPasswordLength
 		}
 
-		encryptedPassword, err := ps.passwordHasher.GenerateFromPassword([]byte(input.Password), 10)
+		encryptedPassword, err := ps.passwordHasher.GenerateFromPassword([]byte(input.Password))
 		if err != nil {
 			return typesystem.ServerError
 		}
@@ -91,10 +177,29 @@ func (ps *PostService) Create(ctx context.Context, input *entity.PostInput) erro
 		input.Password = ""
 	}
 
+	if input.Language == "" && input.Encrypted {
+		input.Language = highlight.PlainText
+	} else if input.Language == "" {
+		input.Language = highlight.Detect(input.Title, input.Content)
+	} else {
+		language, ok := highlight.Normalize(input.Language)
+		if !ok {
+			return ErrUnsupportedLanguage
+		}
+
+		input.Language = language
+	}
+
+	err = normalizeFiles(input.Files, input.Encrypted)
+	if err != nil {
+		return err
+	}
+
 	post := entity.NewPost(
 		input.UserID,
 		input.Title,
 		input.Content,
+		input.Language,
 		input.Password,
 		input.HasPassword,
 		input.Visibility,
@@ -102,8 +207,23 @@ func (ps *PostService) Create(ctx context.Context, input *entity.PostInput) erro
 		input.DeleteAfterView,
 	)
 
+	post.Files = input.Files
+	post.Encrypted = input.Encrypted
+
+	var managementToken string
+
 	if len(*post.UserID) == 0 {
 		post.UserID = nil
+
+		managementToken, post.ManagementTokenHash, err = entity.NewManagementToken()
+		if err != nil {
+			return typesystem.ServerError
+		}
+	}
+
+	err = ps.sealPost(post, password)
+	if err != nil {
+		return err
 	}
 
 	err = ps.postRepo.Insert(ctx, post)
@@ -112,6 +232,9 @@ func (ps *PostService) Create(ctx context.Context, input *entity.PostInput) erro
 		return typesystem.ServerError
 	}
 
+	input.ID = post.ID
+	input.ManagementToken = managementToken
+
 	return nil
 }
 
@@ -144,13 +267,19 @@ func (ps *PostService) GetPosts(
 func (ps *PostService) GetAllPublics(
 	ctx context.Context,
 	pageStr string,
+	language string,
 ) ([]*entity.PostOutput, *entity.PaginationInfo, error) {
 	page, err := strconv.Atoi(pageStr)
 	if err != nil || page < 1 {
 		return nil, nil, typesystem.ServerError
 	}
 
-	posts, count, err := ps.postRepo.FindAllPublics(ctx, page)
+	language, err = normalizeLanguageFilter(language)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	posts, count, err := ps.postRepo.FindAllPublics(ctx, page, language)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil, typesystem.NotFound
@@ -158,7 +287,12 @@ func (ps *PostService) GetAllPublics(
 		return nil, nil, typesystem.ServerError
 	}
 
-	paginationInfo, err := pagination.GeneratePaginationInfo(count, page, "/post/all")
+	path := "/post/all"
+	if language != "" {
+		path = fmt.Sprintf("/post/all?language=%s", url.QueryEscape(language))
+	}
+
+	paginationInfo, err := pagination.GeneratePaginationInfo(count, page, path)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -166,7 +300,9 @@ func (ps *PostService) GetAllPublics(
 	return posts, paginationInfo, nil
 }
 
-func (ps *PostService) DeletePost(ctx context.Context, id string, userID uuid.UUID) error {
+// DeletePost deletes a post of userID, or an anonymous post given its management token.
+// Anonymous callers pass uuid.Nil.
+func (ps *PostService) DeletePost(ctx context.Context, id string, userID uuid.UUID, managementToken string) error {
 	post, err := ps.postRepo.FindOneByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -175,7 +311,7 @@ func (ps *PostService) DeletePost(ctx context.Context, id string, userID uuid.UU
 		return typesystem.ServerError
 	}
 
-	if *post.UserID != userID.String() {
+	if !canManage(post, userID, managementToken) {
 		return typesystem.Forbidden
 	}
 
@@ -187,11 +323,14 @@ func (ps *PostService) DeletePost(ctx context.Context, id string, userID uuid.UU
 	return nil
 }
 
+// UpdatePost updates a post of userID, or an anonymous post given its management token.
+// Anonymous callers pass uuid.Nil.
 func (ps *PostService) UpdatePost(
 	ctx context.Context,
 	post *entity.PostUpdateInput,
 	userID uuid.UUID,
 	id string,
+	managementToken string,
 ) error {
 	postInDatabase, err := ps.postRepo.FindOneByID(ctx, id)
 	if err != nil {
@@ -201,12 +340,42 @@ func (ps *PostService) UpdatePost(
 		return typesystem.ServerError
 	}
 
-	if *postInDatabase.UserID != userID.String() {
+	if !canManage(postInDatabase, userID, managementToken) {
 		return typesystem.Forbidden
 	}
 
 	post.ID = postInDatabase.ID
 
+	if post.Content != "" {
+		err = ps.checkContentSize(post.Content, postInDatabase.Files)
+		if err != nil {
+			return err
+		}
+
+		if postInDatabase.Encrypted {
+			err = validateEnvelopes(post.Content, nil)
+			if err != nil {
+				return err
+			}
+
+			post.Encrypted = true
+		}
+
+		err = ps.sealUpdate(ctx, post, postInDatabase)
+		if err != nil {
+			return err
+		}
+	}
+
+	if post.Language != "" {
+		language, ok := highlight.Normalize(post.Language)
+		if !ok {
+			return ErrUnsupportedLanguage
+		}
+
+		post.Language = language
+	}
+
 	err = ps.postRepo.Update(ctx, post)
 	if err != nil {
 		return typesystem.ServerError
@@ -215,17 +384,67 @@ func (ps *PostService) UpdatePost(
 	return nil
 }
 
+// ClaimPost moves an anonymous post into the account of userID. The caller proves they
+// created it with its management token, which stops working afterwards.
+func (ps *PostService) ClaimPost(ctx context.Context, id string, userID uuid.UUID, managementToken string) error {
+	post, err := ps.postRepo.FindOneByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return typesystem.NotFound
+		}
+		return typesystem.ServerError
+	}
+
+	if post.UserID != nil {
+		return ErrAlreadyClaimed
+	}
+
+	if !canManage(post, uuid.Nil, managementToken) {
+		return typesystem.Forbidden
+	}
+
+	err = ps.postRepo.Claim(ctx, id, userID, post.ManagementTokenHash)
+	if err != nil {
+		// Claimed by another request in the meantime
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAlreadyClaimed
+		}
+		return typesystem.ServerError
+	}
+
+	return nil
+}
+
+// canManage reports whether userID owns the post or managementToken manages it
+func canManage(post *entity.PostOutput, userID uuid.UUID, managementToken string) bool {
+	if post.UserID != nil {
+		return userID != uuid.Nil && *post.UserID == userID.String()
+	}
+
+	if managementToken == "" || post.ManagementTokenHash == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(entity.HashToken(managementToken)), []byte(post.ManagementTokenHash)) == 1
+}
+
 func (ps *PostService) SearchPost(
 	ctx context.Context,
 	query string,
 	pageStr string,
+	language string,
 ) ([]*entity.PostOutput, *entity.PaginationInfo, error) {
 	page, err := strconv.Atoi(pageStr)
 	if err != nil || page < 1 {
 		return nil, nil, typesystem.ServerError
 	}
 
-	posts, count, err := ps.postRepo.Search(ctx, query, page)
+	language, err = normalizeLanguageFilter(language)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	posts, count, err := ps.postRepo.Search(ctx, query, page, language)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil, typesystem.NotFound
@@ -233,7 +452,12 @@ func (ps *PostService) SearchPost(
 		return nil, nil, typesystem.ServerError
 	}
 
-	paginationInfo, err := pagination.GeneratePaginationInfo(count, page, fmt.Sprintf("/post/search?q=%s&", query))
+	path := fmt.Sprintf("/post/search?q=%s", url.QueryEscape(query))
+	if language != "" {
+		path = fmt.Sprintf("%s&language=%s", path, url.QueryEscape(language))
+	}
+
+	paginationInfo, err := pagination.GeneratePaginationInfo(count, page, path)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -246,6 +470,91 @@ func (ps *PostService) GetPost(
 	id string,
 	userID string,
 	password string,
+) (*entity.PostOutput, error) {
+	post, err := ps.findAccessiblePost(ctx, id, userID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return ps.readPost(ctx, post, password)
+}
+
+// readPost decrypts a post returned by findAccessiblePost, burning it first when it is
+// deleted after being read. Anything that can still reject the request must be checked
+// before, or the only view of the post is spent on an error.
+func (ps *PostService) readPost(ctx context.Context, post *entity.PostOutput, password string) (*entity.PostOutput, error) {
+	dataKey, err := ps.dataKey(post, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if post.DeleteAfterView {
+		burned, err := ps.burn(ctx, post.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		burned.Files = post.Files
+		post = burned
+	}
+
+	err = openPost(dataKey, post)
+	if err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+// sealUpdate encrypts new content for a sealed post with its existing data key. Changing
+// the content of a password-protected post takes its password.
+func (ps *PostService) sealUpdate(ctx context.Context, post *entity.PostUpdateInput, postInDatabase *entity.PostOutput) error {
+	if postInDatabase.Encryption == entity.Unencrypted {
+		return nil
+	}
+
+	if postInDatabase.Encryption == entity.EncryptedWithPassword {
+		err := ps.checkPassword(ctx, postInDatabase, post.Password)
+		if err != nil {
+			return err
+		}
+	}
+
+	dataKey, err := ps.dataKey(postInDatabase, post.Password)
+	if err != nil {
+		return err
+	}
+
+	post.Content, err = sealContent(dataKey, post.Content)
+	if err != nil {
+		return err
+	}
+
+	post.Encryption = postInDatabase.Encryption
+
+	return nil
+}
+
+// burn atomically deletes a burn-after-read post and returns it. When several
+// readers race only the one whose delete succeeds receives the content.
+func (ps *PostService) burn(ctx context.Context, id string) (*entity.PostOutput, error) {
+	post, err := ps.postRepo.Burn(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, typesystem.NotFound
+		}
+		return nil, typesystem.ServerError
+	}
+
+	return post, nil
+}
+
+// findAccessiblePost loads a post and applies the expiration, password and visibility rules
+func (ps *PostService) findAccessiblePost(
+	ctx context.Context,
+	id string,
+	userID string,
+	password string,
 ) (*entity.PostOutput, error) {
 	post, err := ps.postRepo.FindOneByID(ctx, id)
 	if err != nil {
@@ -255,28 +564,455 @@ func (ps *PostService) GetPost(
 		return nil, typesystem.ServerError
 	}
 
+	// expired posts are removed by the ExpirationSweeper
 	if !post.ExpirationAt.IsZero() && time.Now().After(post.ExpirationAt) {
-		go ps.postRepo.Delete(ctx, post.ID)
-
 		return nil, typesystem.NotFound
 	}
 
 	if post.HasPassword {
-		err := ps.passwordHasher.CompareHashAndPassword([]byte(post.Password), []byte(password))
+		err := ps.checkPassword(ctx, post, password)
 		if err != nil {
-			return nil, typesystem.Unauthorized
+			return nil, err
 		}
 	}
 
-	if post.DeleteAfterView {
-		defer ps.postRepo.Delete(ctx, post.ID)
-	}
-
 	if post.Visibility == entity.Private {
-		if *post.UserID != userID {
+		if post.UserID == nil || *post.UserID != userID {
 			return nil, typesystem.NotFound
 		}
 	}
 
 	return post, nil
 }
+
+// checkPassword verifies the password of a protected post. Failures are counted against
+// the post and the client address, both of which get locked after too many of them.
+func (ps *PostService) checkPassword(ctx context.Context, post *entity.PostOutput, password string) error {
+	postKey := entity.PostAttemptKey(post.ID)
+	ipKey := entity.IPAttemptKey(entity.ClientIP(ctx))
+
+	err := ps.lockout.Check(ctx, postKey, ipKey)
+	if err != nil {
+		return err
+	}
+
+	err = ps.passwordHasher.CompareHashAndPassword([]byte(post.Password), []byte(password))
+	if err != nil {
+		err = ps.lockout.Fail(ctx, postKey, ipKey)
+		if err != nil {
+			return err
+		}
+
+		return typesystem.Unauthorized
+	}
+
+	err = ps.lockout.Reset(ctx, postKey)
+	if err != nil {
+		return err
+	}
+
+	ps.rehashPassword(ctx, post, password)
+
+	return nil
+}
+
+// rehashPassword upgrades the hash of a post password that was just verified when it
+// uses an old algorithm or weaker parameters. Failures are only logged.
+func (ps *PostService) rehashPassword(ctx context.Context, post *entity.PostOutput, password string) {
+	if !ps.passwordHasher.NeedsRehash([]byte(post.Password)) {
+		return
+	}
+
+	hash, err := ps.passwordHasher.GenerateFromPassword([]byte(password))
+	if err != nil {
+		log.Printf("rehash password of post %s: %s", post.ID, err)
+		return
+	}
+
+	err = ps.postRepo.ReplacePasswordHash(ctx, post.ID, post.Password, string(hash))
+	if err != nil {
+		log.Printf("rehash password of post %s: %s", post.ID, err)
+		return
+	}
+
+	post.Password = string(hash)
+}
+
+// findPostHistory returns a post whose revision history the caller may read.
+// History of burn-after-read posts is restricted to the owner, otherwise it
+// could be used to read the content without burning it.
+func (ps *PostService) findPostHistory(
+	ctx context.Context,
+	id string,
+	userID string,
+	password string,
+) (*entity.PostOutput, error) {
+	post, err := ps.findAccessiblePost(ctx, id, userID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if post.DeleteAfterView && (post.UserID == nil || *post.UserID != userID) {
+		return nil, typesystem.Forbidden
+	}
+
+	return post, nil
+}
+
+func (ps *PostService) GetRevisions(
+	ctx context.Context,
+	id string,
+	userID string,
+	password string,
+) ([]*entity.PostRevision, error) {
+	_, err := ps.findPostHistory(ctx, id, userID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := ps.postRepo.FindRevisions(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, typesystem.NotFound
+		}
+		return nil, typesystem.ServerError
+	}
+
+	return revisions, nil
+}
+
+func (ps *PostService) GetRevision(
+	ctx context.Context,
+	id string,
+	revisionStr string,
+	userID string,
+	password string,
+) (*entity.PostRevision, error) {
+	revision, err := parseRevision(revisionStr)
+	if err != nil {
+		return nil, err
+	}
+
+	post, err := ps.findPostHistory(ctx, id, userID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := ps.dataKey(post, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return ps.findRevision(ctx, id, revision, dataKey)
+}
+
+// DiffRevisions returns a unified diff between two revisions of a post
+func (ps *PostService) DiffRevisions(
+	ctx context.Context,
+	id string,
+	fromStr string,
+	toStr string,
+	userID string,
+	password string,
+) (*entity.PostDiffOutput, error) {
+	post, err := ps.findPostHistory(ctx, id, userID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if toStr == "" {
+		toStr = strconv.Itoa(post.Revision)
+	}
+
+	to, err := parseRevision(toStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if fromStr == "" {
+		fromStr = strconv.Itoa(max(to-1, 1))
+	}
+
+	from, err := parseRevision(fromStr)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := ps.dataKey(post, password)
+	if err != nil {
+		return nil, err
+	}
+
+	fromRevision, err := ps.findRevision(ctx, id, from, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	toRevision, err := ps.findRevision(ctx, id, to, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(fromRevision.Content),
+		B:        difflib.SplitLines(toRevision.Content),
+		FromFile: fmt.Sprintf("%s@%d", id, from),
+		ToFile:   fmt.Sprintf("%s@%d", id, to),
+		Context:  3,
+	})
+	if err != nil {
+		return nil, typesystem.ServerError
+	}
+
+	return &entity.PostDiffOutput{From: from, To: to, Diff: diff}, nil
+}
+
+// Fork copies a post the caller can read into a new post owned by the caller.
+// The fork keeps the source password hash so protected content stays protected.
+func (ps *PostService) Fork(
+	ctx context.Context,
+	id string,
+	userID string,
+	password string,
+	input *entity.ForkPostInput,
+) (*entity.PostOutput, error) {
+	if userID == "" {
+		return nil, typesystem.Unauthorized
+	}
+
+	err := ps.validation.Validate(input)
+	if err != nil {
+		return nil, typesystem.BadRequest
+	}
+
+	source, err := ps.findAccessiblePost(ctx, id, userID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if source.DeleteAfterView && (source.UserID == nil || *source.UserID != userID) {
+		return nil, ErrForkDeleteAfterView
+	}
+
+	dataKey, err := ps.dataKey(source, password)
+	if err != nil {
+		return nil, err
+	}
+
+	err = openPost(dataKey, source)
+	if err != nil {
+		return nil, err
+	}
+
+	title := input.Title
+	if title == "" {
+		title = source.Title
+	}
+
+	visibility := input.Visibility
+	if visibility == "" {
+		visibility = source.Visibility
+	}
+
+	post := entity.NewPost(
+		&userID,
+		title,
+		source.Content,
+		source.Language,
+		source.Password,
+		source.HasPassword,
+		visibility,
+		time.Time{},
+		false,
+	)
+	post.ForkedFrom = &source.ID
+	post.Files = source.Files
+	post.Encrypted = source.Encrypted
+
+	// A protected source was opened with password, which is the fork's password too
+	err = ps.sealPost(post, password)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ps.postRepo.Insert(ctx, post)
+	if err != nil {
+		return nil, typesystem.ServerError
+	}
+
+	return &entity.PostOutput{
+		ID:          post.ID,
+		UserID:      post.UserID,
+		Title:       post.Title,
+		HasPassword: post.HasPassword,
+		Visibility:  post.Visibility,
+		Revision:    1,
+		ForkedFrom:  post.ForkedFrom,
+		Encrypted:   post.Encrypted,
+	}, nil
+}
+
+// RenderPost returns the post content as a syntax highlighted HTML document. Encrypted
+// posts are refused before a burn-after-read post is read, so they are not lost.
+func (ps *PostService) RenderPost(
+	ctx context.Context,
+	id string,
+	userID string,
+	password string,
+) (string, error) {
+	post, err := ps.findAccessiblePost(ctx, id, userID, password)
+	if err != nil {
+		return "", err
+	}
+
+	if post.Encrypted {
+		return "", ErrRenderEncrypted
+	}
+
+	post, err = ps.readPost(ctx, post, password)
+	if err != nil {
+		return "", err
+	}
+
+	rendered, err := highlight.RenderHTML(post.Content, post.Language)
+	if err != nil {
+		return "", typesystem.ServerError
+	}
+
+	return rendered, nil
+}
+
+// checkContentSize enforces the configured limits on a post body and its files
+func (ps *PostService) checkContentSize(content string, files []entity.PostFile) error {
+	total := len(content)
+
+	if ps.limits.MaxSize > 0 && len(content) > ps.limits.MaxSize {
+		return ErrContentTooLarge
+	}
+
+	for _, file := range files {
+		if ps.limits.MaxSize > 0 && len(file.Content) > ps.limits.MaxSize {
+			return ErrContentTooLarge
+		}
+
+		total += len(file.Content)
+	}
+
+	if ps.limits.MaxPostSize > 0 && total > ps.limits.MaxPostSize {
+		return ErrContentTooLarge
+	}
+
+	return nil
+}
+
+// normalizeFiles validates the files of a multi-file post and resolves their languages.
+// The languages of encrypted files are only detected from their names.
+func normalizeFiles(files []entity.PostFile, encrypted bool) error {
+	if len(files) > MaxPostFiles {
+		return ErrTooManyFiles
+	}
+
+	seen := make(map[string]bool, len(files))
+
+	for i := range files {
+		file := &files[i]
+
+		if !validFilename(file.Filename) {
+			return ErrInvalidFilename
+		}
+
+		if seen[file.Filename] {
+			return ErrDuplicateFilename
+		}
+
+		seen[file.Filename] = true
+
+		if file.Language == "" && encrypted {
+			file.Language = highlight.Detect(file.Filename, "")
+			continue
+		}
+
+		if file.Language == "" {
+			file.Language = highlight.Detect(file.Filename, file.Content)
+			continue
+		}
+
+		language, ok := highlight.Normalize(file.Language)
+		if !ok {
+			return ErrUnsupportedLanguage
+		}
+
+		file.Language = language
+	}
+
+	return nil
+}
+
+// validateEnvelopes checks that the bodies of an encrypted post are envelopes the client
+// can decrypt. They are stored and returned as they were sent.
+func validateEnvelopes(content string, files []entity.PostFile) error {
+	if content != "" {
+		_, err := pastecrypt.Parse([]byte(content))
+		if err != nil {
+			return ErrInvalidEnvelope
+		}
+	}
+
+	for _, file := range files {
+		_, err := pastecrypt.Parse([]byte(file.Content))
+		if err != nil {
+			return ErrInvalidEnvelope
+		}
+	}
+
+	return nil
+}
+
+func validFilename(filename string) bool {
+	if filename == "" || filename == "." || filename == ".." {
+		return false
+	}
+
+	return !strings.ContainsAny(filename, "/\\\x00")
+}
+
+// normalizeLanguageFilter resolves an optional language filter to its canonical name
+func normalizeLanguageFilter(language string) (string, error) {
+	if language == "" {
+		return "", nil
+	}
+
+	normalized, ok := highlight.Normalize(language)
+	if !ok {
+		return "", ErrUnsupportedLanguage
+	}
+
+	return normalized, nil
+}
+
+// findRevision loads a revision and decrypts it with the data key of its post
+func (ps *PostService) findRevision(ctx context.Context, id string, revision int, dataKey []byte) (*entity.PostRevision, error) {
+	postRevision, err := ps.postRepo.FindRevision(ctx, id, revision)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, typesystem.NotFound
+		}
+		return nil, typesystem.ServerError
+	}
+
+	postRevision.Content, err = openContent(dataKey, postRevision.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	return postRevision, nil
+}
+
+func parseRevision(revisionStr string) (int, error) {
+	revision, err := strconv.Atoi(revisionStr)
+	if err != nil || revision < 1 {
+		return 0, ErrInvalidRevision
+	}
+
+	return revision, nil
+}
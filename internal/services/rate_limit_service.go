@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Caixetadev/snippet/config"
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/pkg/ratelimit"
+)
+
+// RateLimitStore keeps token buckets. It is implemented in memory and in Postgres.
+type RateLimitStore interface {
+	TakeToken(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error)
+	PruneBuckets(ctx context.Context, before time.Time) (int, error)
+}
+
+// RateLimitPolicies parses the configured limits, written as <policy>.<kind>=<burst>/<period>
+func RateLimitPolicies(cfg *config.Config) (map[string]entity.RateLimitPolicy, error) {
+	policies := make(map[string]entity.RateLimitPolicy)
+
+	for _, entry := range cfg.RateLimits {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("rate limit %q: want <policy>.<kind>=<burst>/<period>", entry)
+		}
+
+		policy, kind, ok := strings.Cut(name, ".")
+		if !ok {
+			return nil, fmt.Errorf("rate limit %q: want <policy>.<kind>=<burst>/<period>", entry)
+		}
+
+		switch kind {
+		case entity.RateLimitAnonymous, entity.RateLimitUser, entity.RateLimitToken:
+		default:
+			return nil, fmt.Errorf("rate limit %q: unknown kind %q", entry, kind)
+		}
+
+		limit, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if policies[policy] == nil {
+			policies[policy] = make(entity.RateLimitPolicy)
+		}
+
+		policies[policy][kind] = limit
+	}
+
+	return policies, nil
+}
+
+type RateLimitService struct {
+	store    RateLimitStore
+	policies map[string]entity.RateLimitPolicy
+}
+
+func NewRateLimitService(store RateLimitStore, policies map[string]entity.RateLimitPolicy) *RateLimitService {
+	return &RateLimitService{store: store, policies: policies}
+}
+
+// Take counts a request against the bucket of identity under policy. It returns nil
+// when the policy sets no limit for that kind of caller.
+func (rs *RateLimitService) Take(ctx context.Context, policy string, identity entity.RateLimitIdentity) (*ratelimit.Result, error) {
+	limit, ok := rs.policies[policy][identity.Kind]
+	if !ok {
+		return nil, nil
+	}
+
+	result, err := rs.store.TakeToken(ctx, policy+":"+identity.String(), limit, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Run removes buckets that have been full for a while on every interval until ctx is cancelled
+func (rs *RateLimitService) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// A bucket untouched for a whole period is full, which is what a missing bucket means too
+		_, err := rs.store.PruneBuckets(ctx, time.Now().Add(-rs.longestPeriod()))
+		if err != nil {
+			log.Printf("rate limit: %s", err)
+		}
+	}
+}
+
+func (rs *RateLimitService) longestPeriod() time.Duration {
+	var period time.Duration
+
+	for _, policy := range rs.policies {
+		for _, limit := range policy {
+			period = max(period, limit.Period)
+		}
+	}
+
+	return period
+}
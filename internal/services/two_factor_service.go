@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/utils"
+	"github.com/Caixetadev/snippet/pkg/totp"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrTwoFactorEnabled = typesystem.NewHttpError(
+		"Two-factor authentication is already enabled.",
+		"[Error: two_factor_enabled]",
+		http.StatusConflict,
+	)
+	ErrTwoFactorNotSetUp = typesystem.NewHttpError(
+		"Two-factor authentication has not been set up.",
+		"[Error: two_factor_not_set_up]",
+		http.StatusBadRequest,
+	)
+	ErrInvalidTwoFactorCode = typesystem.NewHttpError(
+		"The verification code is not valid.",
+		"[Error: invalid_two_factor_code]",
+		http.StatusUnauthorized,
+	)
+)
+
+// totpSkew accepts codes from one period before and after the current one
+const totpSkew = 1
+
+type TwoFactorRepository interface {
+	FindTwoFactor(ctx context.Context, userID uuid.UUID) (*entity.TwoFactor, error)
+	SetTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error
+	EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codes []*entity.RecoveryCode) error
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string, now time.Time) error
+	CreateSigninChallenge(ctx context.Context, challenge *entity.SigninChallenge) error
+	FindSigninChallenge(ctx context.Context, tokenHash string, now time.Time) (*entity.SigninChallenge, error)
+	FailSigninChallenge(ctx context.Context, id uuid.UUID, maxAttempts int) error
+	DeleteSigninChallenge(ctx context.Context, id uuid.UUID) error
+}
+
+type TwoFactorService struct {
+	twoFactorRepo TwoFactorRepository
+	lockout       *LockoutService
+	issuer        string
+}
+
+func NewTwoFactorService(twoFactorRepo TwoFactorRepository, lockout *LockoutService, issuer string) *TwoFactorService {
+	return &TwoFactorService{twoFactorRepo: twoFactorRepo, lockout: lockout, issuer: issuer}
+}
+
+// Setup generates a new TOTP secret for the user. 2FA stays disabled until Confirm.
+func (ts *TwoFactorService) Setup(ctx context.Context, user *entity.User) (*entity.TwoFactorSetupResponse, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, typesystem.ServerError
+	}
+
+	err = ts.twoFactorRepo.SetTOTPSecret(ctx, user.ID, secret)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrTwoFactorEnabled
+		}
+		return nil, typesystem.ServerError
+	}
+
+	return &entity.TwoFactorSetupResponse{
+		Secret: secret,
+		URI:    totp.URI(ts.issuer, user.Email, secret),
+	}, nil
+}
+
+// Confirm enables 2FA once the user proves their authenticator produces valid codes.
+// It returns the recovery codes, which are only stored hashed.
+func (ts *TwoFactorService) Confirm(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	twoFactor, err := ts.twoFactorRepo.FindTwoFactor(ctx, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, typesystem.NotFound
+		}
+		return nil, typesystem.ServerError
+	}
+
+	if twoFactor.Enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	if twoFactor.Secret == "" {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	step, ok := totp.Validate(twoFactor.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes := make([]string, entity.RecoveryCodeCount)
+	recoveryCodes := make([]*entity.RecoveryCode, entity.RecoveryCodeCount)
+
+	for i := range codes {
+		random, err := utils.GenerateSecureRandomString(10)
+		if err != nil {
+			return nil, typesystem.ServerError
+		}
+
+		codes[i] = strings.ToLower(random[:5] + "-" + random[5:])
+		recoveryCodes[i] = entity.NewRecoveryCode(userID, codes[i])
+	}
+
+	err = ts.twoFactorRepo.EnableTOTP(ctx, userID, step, recoveryCodes)
+	if err != nil {
+		return nil, typesystem.ServerError
+	}
+
+	return codes, nil
+}
+
+// CreateChallenge starts the second step of a sign-in and returns the challenge token
+func (ts *TwoFactorService) CreateChallenge(ctx context.Context, userID uuid.UUID) (string, error) {
+	token, err := utils.GenerateSecureRandomString(32)
+	if err != nil {
+		return "", typesystem.ServerError
+	}
+
+	err = ts.twoFactorRepo.CreateSigninChallenge(ctx, entity.NewSigninChallenge(userID, token))
+	if err != nil {
+		return "", typesystem.ServerError
+	}
+
+	return token, nil
+}
+
+// VerifyChallenge checks the second factor for a challenge and returns the user it belongs to.
+// The code is either a TOTP code or an unused recovery code. A challenge can be redeemed once.
+func (ts *TwoFactorService) VerifyChallenge(ctx context.Context, token string, code string) (uuid.UUID, error) {
+	challenge, err := ts.twoFactorRepo.FindSigninChallenge(ctx, entity.HashToken(token), time.Now())
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return uuid.Nil, typesystem.TokenInvalidError
+		}
+		return uuid.Nil, typesystem.ServerError
+	}
+
+	// A challenge only allows a few attempts, but signing in again issues a new one, so
+	// failures are also counted against the user and the client address
+	userKey := entity.TwoFactorAttemptKey(challenge.UserID.String())
+	ipKey := entity.IPAttemptKey(entity.ClientIP(ctx))
+
+	err = ts.lockout.Check(ctx, userKey, ipKey)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	ok, err := ts.verifyCode(ctx, challenge.UserID, code)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if !ok {
+		err = ts.twoFactorRepo.FailSigninChallenge(ctx, challenge.ID, entity.SigninChallengeMaxAttempts)
+		if err != nil {
+			return uuid.Nil, typesystem.ServerError
+		}
+
+		err = ts.lockout.Fail(ctx, userKey, ipKey)
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		return uuid.Nil, ErrInvalidTwoFactorCode
+	}
+
+	err = ts.lockout.Reset(ctx, userKey)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	err = ts.twoFactorRepo.DeleteSigninChallenge(ctx, challenge.ID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			// Redeemed concurrently by another request
+			return uuid.Nil, typesystem.TokenInvalidError
+		}
+		return uuid.Nil, typesystem.ServerError
+	}
+
+	return challenge.UserID, nil
+}
+
+func (ts *TwoFactorService) verifyCode(ctx context.Context, userID uuid.UUID, code string) (bool, error) {
+	twoFactor, err := ts.twoFactorRepo.FindTwoFactor(ctx, userID)
+	if err != nil {
+		return false, typesystem.ServerError
+	}
+
+	if !twoFactor.Enabled {
+		return false, ErrTwoFactorNotSetUp
+	}
+
+	step, ok := totp.Validate(twoFactor.Secret, code, time.Now(), totpSkew)
+	if ok {
+		// Each code is accepted once, so a code read over the user's shoulder is useless
+		err = ts.twoFactorRepo.UseTOTPStep(ctx, userID, step)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return false, nil
+			}
+			return false, typesystem.ServerError
+		}
+
+		return true, nil
+	}
+
+	err = ts.twoFactorRepo.UseRecoveryCode(ctx, userID, entity.HashToken(strings.ToLower(strings.TrimSpace(code))), time.Now())
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, typesystem.ServerError
+	}
+
+	return true, nil
+}
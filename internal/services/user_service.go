@@ -2,16 +2,18 @@ package services
 
 import (
 	"context"
+	"log"
+	"net/http"
 	"time"
 
 	"github.com/Caixetadev/snippet/internal/entity"
 	"github.com/Caixetadev/snippet/internal/token"
+	"github.com/Caixetadev/snippet/internal/utils"
 	"github.com/Caixetadev/snippet/pkg/passwordhash"
 	"github.com/Caixetadev/snippet/pkg/typesystem"
 	"github.com/Caixetadev/snippet/pkg/validation"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type UserRepository interface {
@@ -19,15 +21,30 @@ type UserRepository interface {
 	FindOneByEmail(ctx context.Context, email string) (*entity.User, error)
 	FindOneByID(ctx context.Context, id uuid.UUID) (*entity.User, error)
 	UserExistsByEmail(ctx context.Context, email string) (bool, error)
-	StoreVerificationData(ctx context.Context, verificationData *entity.VerificationData) error
+	StoreVerificationData(ctx context.Context, verificationData *entity.VerificationData, job *entity.Job) error
 	UpdatePassword(ctx context.Context, password string, id uuid.UUID) error
+	ReplacePasswordHash(ctx context.Context, id uuid.UUID, oldHash string, newHash string) error
 	VerifyCodeToResetPassword(ctx context.Context, code string) (entity.VerificationData, error)
 	GetSession(ctx context.Context, id uuid.UUID) (*entity.Session, error)
 	CreateSession(ctx context.Context, session *entity.Session) error
 	GetRefreshTokenByToken(ctx context.Context, token string) (*entity.RefreshToken, error)
 	RevokeRefreshToken(ctx context.Context, token string) error
+	FindSessionsByUser(ctx context.Context, userID uuid.UUID, now time.Time) ([]*entity.Session, error)
+	RevokeSession(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	RevokeAllSessions(ctx context.Context, userID uuid.UUID) error
+	RotateSession(ctx context.Context, oldID uuid.UUID, session *entity.Session) error
+	RevokeSessionFamily(ctx context.Context, familyID uuid.UUID) error
+	InsertSecurityEvent(ctx context.Context, event *entity.SecurityEvent) error
+	StoreEmailVerification(ctx context.Context, verification *entity.EmailVerification, job *entity.Job) error
+	VerifyEmail(ctx context.Context, tokenHash string, now time.Time) error
 }
 
+var ErrEmailNotVerified = typesystem.NewHttpError(
+	"Please verify your email address first.",
+	"[Error: email_not_verified]",
+	http.StatusForbidden,
+)
+
 type UserService struct {
 	userRepository UserRepository
 	validation     validation.Validator
@@ -54,7 +71,7 @@ func (us *UserService) Create(ctx context.Context, input *entity.User) (*entity.
 		return nil, typesystem.BadRequest
 	}
 
-	encryptedPassword, err := us.passwordHasher.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	encryptedPassword, err := us.passwordHasher.GenerateFromPassword([]byte(input.Password))
 	if err != nil {
 		return nil, typesystem.ServerError
 	}
@@ -140,10 +157,46 @@ func (us *UserService) CompareHashAndPassword(passwordInDatabase, passwordReques
 	return nil
 }
 
-func (us *UserService) StoreVerificationData(ctx context.Context, userID uuid.UUID, email string, code string) error {
-	verificationData := entity.NewVerificationData(userID, email, code)
+// RehashPassword upgrades the hash of a password that was just verified when it uses an
+// old algorithm or weaker parameters. Failures are only logged, the user is signed in anyway.
+func (us *UserService) RehashPassword(ctx context.Context, user *entity.User, password string) {
+	if !us.passwordHasher.NeedsRehash([]byte(user.Password)) {
+		return
+	}
+
+	hash, err := us.passwordHasher.GenerateFromPassword([]byte(password))
+	if err != nil {
+		log.Printf("rehash password of user %s: %s", user.ID, err)
+		return
+	}
+
+	err = us.userRepository.ReplacePasswordHash(ctx, user.ID, user.Password, string(hash))
+	if err != nil {
+		log.Printf("rehash password of user %s: %s", user.ID, err)
+		return
+	}
+
+	user.Password = string(hash)
+}
+
+// RequestPasswordReset stores a reset code and queues the email carrying it. Both are
+// written in one transaction, so a code is never mailed without being stored.
+func (us *UserService) RequestPasswordReset(ctx context.Context, user *entity.User) error {
+	mailData := entity.NewMailData(user.Name)
+
+	job, err := entity.NewEmailJob(user.Email, "Reset Password", entity.PasswordRecoveryTemplate, mailData)
+	if err != nil {
+		return typesystem.ServerError
+	}
+
+	verificationData := entity.NewVerificationData(user.ID, user.Email, mailData.Code)
 
-	return us.userRepository.StoreVerificationData(ctx, verificationData)
+	err = us.userRepository.StoreVerificationData(ctx, verificationData, job)
+	if err != nil {
+		return typesystem.ServerError
+	}
+
+	return nil
 }
 
 func (us *UserService) VerifyCodeToResetPassword(ctx context.Context, code string) (uuid.UUID, error) {
@@ -169,7 +222,7 @@ func (us *UserService) UpdatePassword(
 		return typesystem.BadRequest
 	}
 
-	encryptedPassword, err := us.passwordHasher.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	encryptedPassword, err := us.passwordHasher.GenerateFromPassword([]byte(password))
 	if err != nil {
 		return typesystem.ServerError
 	}
@@ -195,12 +248,12 @@ func (us *UserService) GetSession(ctx context.Context, refreshPayload *entity.Pa
 		return nil, typesystem.Unauthorized
 	}
 
-	if session.Name != refreshPayload.Username {
+	if session.UserID != refreshPayload.UserID {
 		return nil, typesystem.Unauthorized
 	}
 
 	if session.IsRevoked {
-		return nil, typesystem.TokenRevokedError
+		return nil, us.revokedSession(ctx, session)
 	}
 
 	if session.IsBlocked {
@@ -210,6 +263,67 @@ func (us *UserService) GetSession(ctx context.Context, refreshPayload *entity.Pa
 	return session, nil
 }
 
+// RotateSession replaces current with a session for the newly issued refresh token
+// in the same token family
+func (us *UserService) RotateSession(
+	ctx context.Context,
+	current *entity.Session,
+	payload *entity.Payload,
+	token string,
+) error {
+	session := entity.NewSession(ctx, payload, token)
+	session.FamilyID = current.FamilyID
+
+	err := us.userRepository.RotateSession(ctx, current.ID, session)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			// Lost a race against another request using the same refresh token, or
+			// against a sign out
+			revoked, err := us.userRepository.GetSession(ctx, current.ID)
+			if err != nil || !revoked.IsRevoked {
+				return typesystem.ServerError
+			}
+
+			return us.revokedSession(ctx, revoked)
+		}
+		return typesystem.ServerError
+	}
+
+	return nil
+}
+
+// revokedSession returns the error for a revoked session presented again. A rotated
+// refresh token is only presented again if it was copied. Whoever holds the tokens
+// issued after it may be the attacker, so the whole family is ended.
+func (us *UserService) revokedSession(ctx context.Context, session *entity.Session) error {
+	switch session.RevokedFor {
+	case entity.SessionRotated:
+		return us.revokeFamily(ctx, session)
+	case entity.SessionReused:
+		return typesystem.TokenRevokedError
+	default:
+		return typesystem.Unauthorized
+	}
+}
+
+// revokeFamily handles refresh token reuse by revoking every session of the family and
+// recording a security event
+func (us *UserService) revokeFamily(ctx context.Context, session *entity.Session) error {
+	err := us.userRepository.RevokeSessionFamily(ctx, session.FamilyID)
+	if err != nil {
+		return typesystem.ServerError
+	}
+
+	event := entity.NewSecurityEvent(ctx, entity.SecurityEventRefreshTokenReuse, session)
+
+	err = us.userRepository.InsertSecurityEvent(ctx, event)
+	if err != nil {
+		return typesystem.ServerError
+	}
+
+	return typesystem.TokenRevokedError
+}
+
 func (us *UserService) VerifyToken(ctx context.Context, token string) (*entity.Payload, error) {
 	refreshToken, err := us.tokenMaker.VerifyToken(token)
 	if err != nil {
@@ -222,3 +336,79 @@ func (us *UserService) VerifyToken(ctx context.Context, token string) (*entity.P
 func (us *UserService) RevokeRefreshToken(ctx context.Context, token string) error {
 	return us.userRepository.RevokeRefreshToken(ctx, token)
 }
+
+func (us *UserService) ListSessions(ctx context.Context, userID uuid.UUID) ([]*entity.Session, error) {
+	sessions, err := us.userRepository.FindSessionsByUser(ctx, userID, time.Now())
+	if err != nil {
+		return nil, typesystem.ServerError
+	}
+
+	return sessions, nil
+}
+
+func (us *UserService) RevokeSession(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	err := us.userRepository.RevokeSession(ctx, id, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return typesystem.NotFound
+		}
+		return typesystem.ServerError
+	}
+
+	return nil
+}
+
+func (us *UserService) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	err := us.userRepository.RevokeAllSessions(ctx, userID)
+	if err != nil {
+		return typesystem.ServerError
+	}
+
+	return nil
+}
+
+// RequestEmailVerification stores a verification token and queues the email carrying it
+func (us *UserService) RequestEmailVerification(ctx context.Context, user *entity.User) error {
+	token, err := utils.GenerateSecureRandomString(32)
+	if err != nil {
+		return typesystem.ServerError
+	}
+
+	mailData := entity.MailData{Username: user.Name, Code: token}
+
+	job, err := entity.NewEmailJob(user.Email, "Verify your email", entity.EmailVerificationTemplate, mailData)
+	if err != nil {
+		return typesystem.ServerError
+	}
+
+	verification := entity.NewEmailVerification(user.ID, token)
+
+	err = us.userRepository.StoreEmailVerification(ctx, verification, job)
+	if err != nil {
+		return typesystem.ServerError
+	}
+
+	return nil
+}
+
+// VerifyEmail marks the owner of a verification token as verified. Tokens are single use.
+func (us *UserService) VerifyEmail(ctx context.Context, token string) error {
+	err := us.userRepository.VerifyEmail(ctx, entity.HashToken(token), time.Now())
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return typesystem.TokenInvalidError
+		}
+		return typesystem.ServerError
+	}
+
+	return nil
+}
+
+func (us *UserService) IsEmailVerified(ctx context.Context, userID uuid.UUID) (bool, error) {
+	user, err := us.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return user.EmailVerified, nil
+}
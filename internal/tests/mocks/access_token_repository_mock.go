@@ -0,0 +1,37 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ services.AccessTokenRepository = (*AccessTokenRepository)(nil)
+
+type AccessTokenRepository struct {
+	mock.Mock
+}
+
+func (m *AccessTokenRepository) Insert(ctx context.Context, token *entity.PersonalAccessToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *AccessTokenRepository) FindAllByUser(ctx context.Context, userID uuid.UUID) ([]*entity.PersonalAccessToken, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*entity.PersonalAccessToken), args.Error(1)
+}
+
+func (m *AccessTokenRepository) Revoke(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	args := m.Called(ctx, id, userID)
+	return args.Error(0)
+}
+
+func (m *AccessTokenRepository) Use(ctx context.Context, tokenHash string, now time.Time) (*entity.PersonalAccessToken, error) {
+	args := m.Called(ctx, tokenHash, now)
+	return args.Get(0).(*entity.PersonalAccessToken), args.Error(1)
+}
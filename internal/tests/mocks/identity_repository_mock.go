@@ -0,0 +1,41 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ services.IdentityRepository = (*IdentityRepository)(nil)
+
+type IdentityRepository struct {
+	mock.Mock
+}
+
+func (m *IdentityRepository) CreateOIDCState(ctx context.Context, state *entity.OIDCState) error {
+	args := m.Called(ctx, state)
+	return args.Error(0)
+}
+
+func (m *IdentityRepository) ConsumeOIDCState(ctx context.Context, stateHash string, now time.Time) (*entity.OIDCState, error) {
+	args := m.Called(ctx, stateHash, now)
+	return args.Get(0).(*entity.OIDCState), args.Error(1)
+}
+
+func (m *IdentityRepository) FindUserByIdentity(ctx context.Context, provider, subject string) (*entity.User, error) {
+	args := m.Called(ctx, provider, subject)
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *IdentityRepository) LinkIdentity(ctx context.Context, identity *entity.Identity) error {
+	args := m.Called(ctx, identity)
+	return args.Error(0)
+}
+
+func (m *IdentityRepository) CreateUserWithIdentity(ctx context.Context, user *entity.User, identity *entity.Identity) error {
+	args := m.Called(ctx, user, identity)
+	return args.Error(0)
+}
@@ -0,0 +1,42 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ services.JobRepository = (*JobRepository)(nil)
+
+type JobRepository struct {
+	mock.Mock
+}
+
+func (m *JobRepository) Enqueue(ctx context.Context, job *entity.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *JobRepository) Claim(ctx context.Context, queue string, now time.Time, staleBefore time.Time, limit int) ([]*entity.Job, error) {
+	args := m.Called(ctx, queue, now, staleBefore, limit)
+	return args.Get(0).([]*entity.Job), args.Error(1)
+}
+
+func (m *JobRepository) Complete(ctx context.Context, id uuid.UUID, now time.Time) error {
+	args := m.Called(ctx, id, now)
+	return args.Error(0)
+}
+
+func (m *JobRepository) Retry(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string, now time.Time) error {
+	args := m.Called(ctx, id, runAt, lastError, now)
+	return args.Error(0)
+}
+
+func (m *JobRepository) Bury(ctx context.Context, id uuid.UUID, lastError string, now time.Time) error {
+	args := m.Called(ctx, id, lastError, now)
+	return args.Error(0)
+}
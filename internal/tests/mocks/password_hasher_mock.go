@@ -1,13 +1,18 @@
 package mocks
 
-import "github.com/stretchr/testify/mock"
+import (
+	"github.com/Caixetadev/snippet/pkg/passwordhash"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ passwordhash.PasswordHasher = (*PasswordHasher)(nil)
 
 type PasswordHasher struct {
 	mock.Mock
 }
 
-func (m *PasswordHasher) GenerateFromPassword(password []byte, cost int) ([]byte, error) {
-	args := m.Called(password, cost)
+func (m *PasswordHasher) GenerateFromPassword(password []byte) ([]byte, error) {
+	args := m.Called(password)
 	return args.Get(0).([]byte), args.Error(1)
 }
 
@@ -15,3 +20,8 @@ func (m *PasswordHasher) CompareHashAndPassword(hashedPassword, password []byte)
 	args := m.Called(hashedPassword, password)
 	return args.Error(0)
 }
+
+func (m *PasswordHasher) NeedsRehash(hashedPassword []byte) bool {
+	args := m.Called(hashedPassword)
+	return args.Bool(0)
+}
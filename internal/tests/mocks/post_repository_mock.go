@@ -2,12 +2,20 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 )
 
+var (
+	_ services.PostRepository             = (*PostRepository)(nil)
+	_ services.ExpiredPostRepository      = (*PostRepository)(nil)
+	_ services.ContentMigrationRepository = (*PostRepository)(nil)
+)
+
 type PostRepository struct {
 	mock.Mock
 }
@@ -17,14 +25,14 @@ func (ps *PostRepository) Insert(ctx context.Context, post *entity.PostInput) er
 	return args.Error(0)
 }
 
-func (ps *PostRepository) FindAll(ctx context.Context, id uuid.UUID, limit, offset int) ([]*entity.PostOutput, error) {
-	args := ps.Called(ctx, id)
-	return args.Get(0).([]*entity.PostOutput), args.Error(1)
+func (ps *PostRepository) FindAll(ctx context.Context, id uuid.UUID, page int) ([]*entity.PostOutput, int, error) {
+	args := ps.Called(ctx, id, page)
+	return args.Get(0).([]*entity.PostOutput), args.Int(1), args.Error(2)
 }
 
-func (ps *PostRepository) FindAllPublics(ctx context.Context, limit, offset int) ([]*entity.PostOutput, error) {
-	args := ps.Called(ctx)
-	return args.Get(0).([]*entity.PostOutput), args.Error(1)
+func (ps *PostRepository) FindAllPublics(ctx context.Context, page int, language string) ([]*entity.PostOutput, int, error) {
+	args := ps.Called(ctx, page, language)
+	return args.Get(0).([]*entity.PostOutput), args.Int(1), args.Error(2)
 }
 
 func (ps *PostRepository) CountAllPostsPublics(ctx context.Context) (int, error) {
@@ -42,6 +50,11 @@ func (ps *PostRepository) Delete(ctx context.Context, id string) error {
 	return args.Error(0)
 }
 
+func (ps *PostRepository) Claim(ctx context.Context, id string, userID uuid.UUID, tokenHash string) error {
+	args := ps.Called(ctx, id, userID, tokenHash)
+	return args.Error(0)
+}
+
 func (ps *PostRepository) Update(ctx context.Context, post *entity.PostUpdateInput) error {
 	args := ps.Called(ctx, post)
 	return args.Error(0)
@@ -57,7 +70,57 @@ func (ps *PostRepository) CountUserPosts(ctx context.Context, id uuid.UUID) (int
 	return args.Int(0), args.Error(1)
 }
 
-func (ps *PostRepository) Search(ctx context.Context, query string, limit, offset int) ([]*entity.PostOutput, error) {
-	args := ps.Called(ctx, query)
-	return args.Get(0).([]*entity.PostOutput), args.Error(1)
+func (ps *PostRepository) Search(ctx context.Context, query string, page int, language string) ([]*entity.PostOutput, int, error) {
+	args := ps.Called(ctx, query, page, language)
+	return args.Get(0).([]*entity.PostOutput), args.Int(1), args.Error(2)
+}
+
+func (ps *PostRepository) Burn(ctx context.Context, id string) (*entity.PostOutput, error) {
+	args := ps.Called(ctx, id)
+	return args.Get(0).(*entity.PostOutput), args.Error(1)
+}
+
+func (ps *PostRepository) ReplacePasswordHash(ctx context.Context, id string, oldHash string, newHash string) error {
+	args := ps.Called(ctx, id, oldHash, newHash)
+	return args.Error(0)
+}
+
+func (ps *PostRepository) FindRevisions(ctx context.Context, id string) ([]*entity.PostRevision, error) {
+	args := ps.Called(ctx, id)
+	return args.Get(0).([]*entity.PostRevision), args.Error(1)
+}
+
+func (ps *PostRepository) FindRevision(ctx context.Context, id string, revision int) (*entity.PostRevision, error) {
+	args := ps.Called(ctx, id, revision)
+	return args.Get(0).(*entity.PostRevision), args.Error(1)
+}
+
+func (ps *PostRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) (int, error) {
+	args := ps.Called(ctx, now, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func (ps *PostRepository) ArchiveExpired(ctx context.Context, now time.Time, limit int) (int, error) {
+	args := ps.Called(ctx, now, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func (ps *PostRepository) MoveInlineContent(ctx context.Context, limit int) (int, error) {
+	args := ps.Called(ctx, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func (ps *PostRepository) IndexContent(ctx context.Context, limit int) (int, error) {
+	args := ps.Called(ctx, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func (ps *PostRepository) CompressContent(ctx context.Context, after string, limit int) (string, int, error) {
+	args := ps.Called(ctx, after, limit)
+	return args.String(0), args.Int(1), args.Error(2)
+}
+
+func (ps *PostRepository) DeleteUnreferencedContent(ctx context.Context, limit int) (int, error) {
+	args := ps.Called(ctx, limit)
+	return args.Int(0), args.Error(1)
 }
@@ -0,0 +1,62 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ services.TwoFactorRepository = (*TwoFactorRepository)(nil)
+
+type TwoFactorRepository struct {
+	mock.Mock
+}
+
+func (m *TwoFactorRepository) FindTwoFactor(ctx context.Context, userID uuid.UUID) (*entity.TwoFactor, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(*entity.TwoFactor), args.Error(1)
+}
+
+func (m *TwoFactorRepository) SetTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	args := m.Called(ctx, userID, secret)
+	return args.Error(0)
+}
+
+func (m *TwoFactorRepository) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codes []*entity.RecoveryCode) error {
+	args := m.Called(ctx, userID, step, codes)
+	return args.Error(0)
+}
+
+func (m *TwoFactorRepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	args := m.Called(ctx, userID, step)
+	return args.Error(0)
+}
+
+func (m *TwoFactorRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string, now time.Time) error {
+	args := m.Called(ctx, userID, codeHash, now)
+	return args.Error(0)
+}
+
+func (m *TwoFactorRepository) CreateSigninChallenge(ctx context.Context, challenge *entity.SigninChallenge) error {
+	args := m.Called(ctx, challenge)
+	return args.Error(0)
+}
+
+func (m *TwoFactorRepository) FindSigninChallenge(ctx context.Context, tokenHash string, now time.Time) (*entity.SigninChallenge, error) {
+	args := m.Called(ctx, tokenHash, now)
+	return args.Get(0).(*entity.SigninChallenge), args.Error(1)
+}
+
+func (m *TwoFactorRepository) FailSigninChallenge(ctx context.Context, id uuid.UUID, maxAttempts int) error {
+	args := m.Called(ctx, id, maxAttempts)
+	return args.Error(0)
+}
+
+func (m *TwoFactorRepository) DeleteSigninChallenge(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
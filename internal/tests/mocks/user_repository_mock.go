@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/Caixetadev/snippet/internal/entity"
 	"github.com/Caixetadev/snippet/internal/services"
@@ -35,8 +36,8 @@ func (m *UserRepository) UserExistsByEmail(ctx context.Context, email string) (b
 	return args.Bool(0), args.Error(1)
 }
 
-func (m *UserRepository) StoreVerificationData(ctx context.Context, verification *entity.VerificationData) error {
-	args := m.Called(ctx, verification)
+func (m *UserRepository) StoreVerificationData(ctx context.Context, verification *entity.VerificationData, job *entity.Job) error {
+	args := m.Called(ctx, verification, job)
 	return args.Error(0)
 }
 
@@ -45,6 +46,11 @@ func (m *UserRepository) UpdatePassword(ctx context.Context, password string, id
 	return args.Error(0)
 }
 
+func (m *UserRepository) ReplacePasswordHash(ctx context.Context, id uuid.UUID, oldHash string, newHash string) error {
+	args := m.Called(ctx, id, oldHash, newHash)
+	return args.Error(0)
+}
+
 func (m *UserRepository) VerifyCodeToResetPassword(ctx context.Context, code string) (entity.VerificationData, error) {
 	args := m.Called(ctx, code)
 	return args.Get(0).(entity.VerificationData), args.Error(1)
@@ -69,3 +75,43 @@ func (m *UserRepository) RevokeRefreshToken(ctx context.Context, token string) e
 	args := m.Called(ctx, token)
 	return args.Error(0)
 }
+
+func (m *UserRepository) FindSessionsByUser(ctx context.Context, userID uuid.UUID, now time.Time) ([]*entity.Session, error) {
+	args := m.Called(ctx, userID, now)
+	return args.Get(0).([]*entity.Session), args.Error(1)
+}
+
+func (m *UserRepository) RevokeSession(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	args := m.Called(ctx, id, userID)
+	return args.Error(0)
+}
+
+func (m *UserRepository) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *UserRepository) RotateSession(ctx context.Context, oldID uuid.UUID, session *entity.Session) error {
+	args := m.Called(ctx, oldID, session)
+	return args.Error(0)
+}
+
+func (m *UserRepository) RevokeSessionFamily(ctx context.Context, familyID uuid.UUID) error {
+	args := m.Called(ctx, familyID)
+	return args.Error(0)
+}
+
+func (m *UserRepository) InsertSecurityEvent(ctx context.Context, event *entity.SecurityEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *UserRepository) StoreEmailVerification(ctx context.Context, verification *entity.EmailVerification, job *entity.Job) error {
+	args := m.Called(ctx, verification, job)
+	return args.Error(0)
+}
+
+func (m *UserRepository) VerifyEmail(ctx context.Context, tokenHash string, now time.Time) error {
+	args := m.Called(ctx, tokenHash, now)
+	return args.Error(0)
+}
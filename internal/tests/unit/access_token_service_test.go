@@ -0,0 +1,123 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/Caixetadev/snippet/internal/tests/mocks"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type AccessTokenServiceTestSuite struct {
+	suite.Suite
+	mocksRepo          *mocks.AccessTokenRepository
+	validation         *mocks.Validator
+	accessTokenService *services.AccessTokenService
+}
+
+func (suite *AccessTokenServiceTestSuite) SetupTest() {
+	suite.mocksRepo = new(mocks.AccessTokenRepository)
+	suite.validation = new(mocks.Validator)
+
+	suite.accessTokenService = services.NewAccessTokenService(suite.mocksRepo, suite.validation)
+}
+
+func TestAccessTokenServiceSuite(t *testing.T) {
+	suite.Run(t, new(AccessTokenServiceTestSuite))
+}
+
+func (suite *AccessTokenServiceTestSuite) TestCreate() {
+	ctx := context.TODO()
+	userID := uuid.New()
+	input := &entity.CreateAccessTokenRequest{
+		Name:   "ci",
+		Scopes: []string{entity.ScopePostRead, entity.ScopePostWrite},
+	}
+
+	suite.validation.On("Validate", input).Return(nil)
+	suite.mocksRepo.On("Insert", ctx, mock.AnythingOfType("*entity.PersonalAccessToken")).Return(nil)
+
+	output, err := suite.accessTokenService.Create(ctx, userID, input)
+
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), strings.HasPrefix(output.Token, entity.AccessTokenPrefix))
+	assert.Equal(suite.T(), userID, output.Info.UserID)
+	assert.Equal(suite.T(), entity.HashAccessToken(output.Token), output.Info.TokenHash)
+	assert.True(suite.T(), strings.HasPrefix(output.Token, output.Info.Prefix))
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *AccessTokenServiceTestSuite) TestCreateExpiryInPast() {
+	ctx := context.TODO()
+	expiresAt := time.Now().Add(-time.Hour)
+	input := &entity.CreateAccessTokenRequest{
+		Name:      "ci",
+		Scopes:    []string{entity.ScopePostRead},
+		ExpiresAt: &expiresAt,
+	}
+
+	suite.validation.On("Validate", input).Return(nil)
+
+	output, err := suite.accessTokenService.Create(ctx, uuid.New(), input)
+
+	assert.Nil(suite.T(), output)
+	assert.Equal(suite.T(), services.ErrAccessTokenExpiry, err)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Insert", mock.Anything, mock.Anything)
+}
+
+func (suite *AccessTokenServiceTestSuite) TestVerify() {
+	ctx := context.TODO()
+	token := entity.AccessTokenPrefix + "secret"
+	expected := &entity.PersonalAccessToken{ID: uuid.New(), Scopes: []string{entity.ScopePostRead}}
+
+	suite.mocksRepo.On("Use", ctx, entity.HashAccessToken(token), mock.AnythingOfType("time.Time")).Return(expected, nil)
+
+	accessToken, err := suite.accessTokenService.Verify(ctx, token)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), expected, accessToken)
+}
+
+func (suite *AccessTokenServiceTestSuite) TestVerifyInvalidPrefix() {
+	accessToken, err := suite.accessTokenService.Verify(context.TODO(), "v2.local.token")
+
+	assert.Nil(suite.T(), accessToken)
+	assert.Equal(suite.T(), typesystem.TokenInvalidError, err)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Use", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *AccessTokenServiceTestSuite) TestVerifyRevokedOrExpired() {
+	ctx := context.TODO()
+	token := entity.AccessTokenPrefix + "revoked"
+
+	suite.mocksRepo.On("Use", ctx, entity.HashAccessToken(token), mock.AnythingOfType("time.Time")).Return((*entity.PersonalAccessToken)(nil), sql.ErrNoRows)
+
+	accessToken, err := suite.accessTokenService.Verify(ctx, token)
+
+	assert.Nil(suite.T(), accessToken)
+	assert.Equal(suite.T(), typesystem.TokenInvalidError, err)
+}
+
+func (suite *AccessTokenServiceTestSuite) TestRevokeNotFound() {
+	ctx := context.TODO()
+	userID := uuid.New()
+	id := uuid.New()
+
+	suite.mocksRepo.On("Revoke", ctx, id, userID).Return(sql.ErrNoRows)
+
+	err := suite.accessTokenService.Revoke(ctx, userID, id)
+
+	assert.Equal(suite.T(), typesystem.NotFound, err)
+}
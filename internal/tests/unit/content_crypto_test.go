@@ -0,0 +1,89 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/Caixetadev/snippet/pkg/contentcrypto"
+	"github.com/stretchr/testify/suite"
+)
+
+type ContentCryptoTestSuite struct {
+	suite.Suite
+	params contentcrypto.PasswordParams
+}
+
+func (suite *ContentCryptoTestSuite) SetupTest() {
+	suite.params = contentcrypto.PasswordParams{Memory: 64, Iterations: 1, Parallelism: 1}
+}
+
+func TestContentCryptoTestSuite(t *testing.T) {
+	suite.Run(t, new(ContentCryptoTestSuite))
+}
+
+func (suite *ContentCryptoTestSuite) TestSealOpen() {
+	key, err := contentcrypto.NewKey()
+	suite.NoError(err)
+
+	sealed, err := contentcrypto.Seal(key, []byte("secret"))
+	suite.NoError(err)
+	suite.NotContains(string(sealed), "secret")
+
+	// Every seal uses a new nonce
+	again, err := contentcrypto.Seal(key, []byte("secret"))
+	suite.NoError(err)
+	suite.NotEqual(sealed, again)
+
+	plaintext, err := contentcrypto.Open(key, sealed)
+	suite.NoError(err)
+	suite.Equal("secret", string(plaintext))
+
+	sealed[len(sealed)-1] ^= 1
+
+	_, err = contentcrypto.Open(key, sealed)
+	suite.Equal(contentcrypto.ErrDecrypt, err)
+
+	_, err = contentcrypto.Open(key, sealed[:10])
+	suite.Equal(contentcrypto.ErrDecrypt, err)
+
+	_, err = contentcrypto.Seal([]byte("short"), []byte("secret"))
+	suite.Equal(contentcrypto.ErrInvalidKey, err)
+}
+
+func (suite *ContentCryptoTestSuite) TestWrapWithPassword() {
+	dataKey, err := contentcrypto.NewKey()
+	suite.NoError(err)
+
+	wrapped, err := contentcrypto.WrapWithPassword(dataKey, []byte("12345678"), suite.params)
+	suite.NoError(err)
+
+	unwrapped, err := contentcrypto.UnwrapWithPassword(wrapped, []byte("12345678"))
+	suite.NoError(err)
+	suite.Equal(dataKey, unwrapped)
+
+	_, err = contentcrypto.UnwrapWithPassword(wrapped, []byte("wrong password"))
+	suite.Equal(contentcrypto.ErrDecrypt, err)
+
+	_, err = contentcrypto.UnwrapWithPassword(wrapped[:12], []byte("12345678"))
+	suite.Equal(contentcrypto.ErrDecrypt, err)
+}
+
+func (suite *ContentCryptoTestSuite) TestWrapWithKey() {
+	dataKey, err := contentcrypto.NewKey()
+	suite.NoError(err)
+
+	masterKey, err := contentcrypto.NewKey()
+	suite.NoError(err)
+
+	wrapped, err := contentcrypto.WrapWithKey(dataKey, masterKey)
+	suite.NoError(err)
+
+	unwrapped, err := contentcrypto.UnwrapWithKey(wrapped, masterKey)
+	suite.NoError(err)
+	suite.Equal(dataKey, unwrapped)
+
+	otherKey, err := contentcrypto.NewKey()
+	suite.NoError(err)
+
+	_, err = contentcrypto.UnwrapWithKey(wrapped, otherKey)
+	suite.Equal(contentcrypto.ErrDecrypt, err)
+}
@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/Caixetadev/snippet/internal/tests/mocks"
+	"github.com/stretchr/testify/suite"
+)
+
+type ContentMigratorTestSuite struct {
+	suite.Suite
+	mocksRepo *mocks.PostRepository
+	migrator  *services.ContentMigrator
+}
+
+func (suite *ContentMigratorTestSuite) SetupTest() {
+	suite.mocksRepo = new(mocks.PostRepository)
+	suite.migrator = services.NewContentMigrator(suite.mocksRepo, 10)
+}
+
+func TestContentMigratorTestSuite(t *testing.T) {
+	suite.Run(t, new(ContentMigratorTestSuite))
+}
+
+func (suite *ContentMigratorTestSuite) TestRun_InBatches() {
+	ctx := context.TODO()
+
+	suite.mocksRepo.On("MoveInlineContent", ctx, 10).Return(10, nil).Twice()
+	suite.mocksRepo.On("MoveInlineContent", ctx, 10).Return(3, nil).Once()
+	suite.mocksRepo.On("MoveInlineContent", ctx, 10).Return(0, nil).Once()
+
+	suite.mocksRepo.On("IndexContent", ctx, 10).Return(7, nil).Once()
+	suite.mocksRepo.On("IndexContent", ctx, 10).Return(0, nil).Once()
+
+	// The cursor moves past bodies that were looked at but did not compress
+	suite.mocksRepo.On("CompressContent", ctx, "", 10).Return("0a", 4, nil).Once()
+	suite.mocksRepo.On("CompressContent", ctx, "0a", 10).Return("ff", 0, nil).Once()
+	suite.mocksRepo.On("CompressContent", ctx, "ff", 10).Return("", 0, nil).Once()
+
+	migration, err := suite.migrator.Run(ctx)
+
+	suite.NoError(err)
+	suite.Equal(services.ContentMigration{Moved: 23, Indexed: 7, Compressed: 4}, migration)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *ContentMigratorTestSuite) TestRun_StopsOnError() {
+	ctx := context.TODO()
+
+	suite.mocksRepo.On("MoveInlineContent", ctx, 10).Return(10, nil).Once()
+	suite.mocksRepo.On("MoveInlineContent", ctx, 10).Return(0, errors.New("error")).Once()
+
+	migration, err := suite.migrator.Run(ctx)
+
+	suite.Error(err)
+	suite.Equal(10, migration.Moved)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "IndexContent", ctx, 10)
+	suite.mocksRepo.AssertNotCalled(suite.T(), "CompressContent", ctx, "", 10)
+}
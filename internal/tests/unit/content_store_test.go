@@ -0,0 +1,197 @@
+package unit
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/pkg/contentstore"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeS3 is a local stand-in for an S3-compatible service. It keeps objects in memory
+// and serves path-style PUT, GET, HEAD and DELETE requests.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := r.URL.Path
+
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[name] = data
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[name]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`)
+			}
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+type ContentStoreTestSuite struct {
+	suite.Suite
+	s3     *fakeS3
+	server *httptest.Server
+	stores map[string]contentstore.Store
+}
+
+func (suite *ContentStoreTestSuite) SetupTest() {
+	suite.s3 = &fakeS3{objects: make(map[string][]byte)}
+	suite.server = httptest.NewServer(suite.s3)
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String(suite.server.URL),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	suite.Require().NoError(err)
+
+	fileStore, err := contentstore.NewFileStore(suite.T().TempDir())
+	suite.Require().NoError(err)
+
+	suite.stores = map[string]contentstore.Store{
+		contentstore.DriverFile: fileStore,
+		contentstore.DriverS3:   contentstore.NewS3Store(s3.New(sess), "pastes", "bodies/"),
+	}
+}
+
+func (suite *ContentStoreTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func TestContentStoreTestSuite(t *testing.T) {
+	suite.Run(t, new(ContentStoreTestSuite))
+}
+
+func (suite *ContentStoreTestSuite) TestPutGetDelete() {
+	ctx := context.TODO()
+	name := contentstore.Name(contentstore.Key([]byte("package main")), contentstore.CodecIdentity)
+
+	for driver, store := range suite.stores {
+		exists, err := store.Exists(ctx, name)
+		suite.NoError(err, driver)
+		suite.False(exists, driver)
+
+		suite.NoError(store.Put(ctx, name, []byte("package main")), driver)
+
+		exists, err = store.Exists(ctx, name)
+		suite.NoError(err, driver)
+		suite.True(exists, driver)
+
+		data, err := store.Get(ctx, name)
+		suite.NoError(err, driver)
+		suite.Equal("package main", string(data), driver)
+
+		suite.NoError(store.Delete(ctx, name), driver)
+		suite.NoError(store.Delete(ctx, name), driver)
+
+		_, err = store.Get(ctx, name)
+		suite.Equal(contentstore.ErrNotFound, err, driver)
+	}
+
+	suite.Empty(suite.s3.objects)
+}
+
+func (suite *ContentStoreTestSuite) TestPut_CodecInName() {
+	ctx := context.TODO()
+	key := contentstore.Key([]byte("body"))
+
+	for driver, store := range suite.stores {
+		suite.NoError(store.Put(ctx, contentstore.Name(key, contentstore.CodecGzip), []byte("compressed")), driver)
+
+		exists, err := store.Exists(ctx, contentstore.Name(key, contentstore.CodecIdentity))
+		suite.NoError(err, driver)
+		suite.False(exists, driver)
+	}
+
+	suite.Contains(suite.s3.objects, "/pastes/bodies/"+key+".gz")
+}
+
+func (suite *ContentStoreTestSuite) TestGet_RejectsInvalidNames() {
+	ctx := context.TODO()
+
+	for driver, store := range suite.stores {
+		for _, name := range []string{"../../etc/passwd", contentstore.Key(nil) + ".zip", "a/" + contentstore.Key(nil)} {
+			_, err := store.Get(ctx, name)
+			suite.Error(err, driver)
+			suite.NotEqual(contentstore.ErrNotFound, err, driver)
+		}
+	}
+}
+
+func (suite *ContentStoreTestSuite) TestCompression() {
+	compression := contentstore.Compression{Codec: contentstore.CodecGzip, Threshold: 100}
+	log := []byte(strings.Repeat("panic: runtime error: index out of range\n", 50))
+
+	encoded, codec, err := compression.Encode(log)
+	suite.NoError(err)
+	suite.Equal(contentstore.CodecGzip, codec)
+	suite.Less(len(encoded)*10, len(log))
+
+	decoded, err := contentstore.Decode(encoded, codec)
+	suite.NoError(err)
+	suite.Equal(log, decoded)
+
+	// Below the threshold content is stored as is
+	encoded, codec, err = compression.Encode([]byte("short"))
+	suite.NoError(err)
+	suite.Equal(contentstore.CodecIdentity, codec)
+	suite.Equal("short", string(encoded))
+
+	// So is content that does not get smaller
+	random := make([]byte, 200)
+	rand.Read(random)
+
+	encoded, codec, err = compression.Encode(random)
+	suite.NoError(err)
+	suite.Equal(contentstore.CodecIdentity, codec)
+	suite.Equal(random, encoded)
+
+	encoded, codec, err = contentstore.Compression{Codec: contentstore.CodecIdentity}.Encode(log)
+	suite.NoError(err)
+	suite.Equal(contentstore.CodecIdentity, codec)
+	suite.Equal(log, encoded)
+
+	suite.False(contentstore.ValidCodec("zip"))
+}
+
+func (suite *ContentStoreTestSuite) TestContentPreview() {
+	suite.Equal("short", entity.ContentPreview("short"))
+
+	long := strings.Repeat("a", entity.ContentPreviewSize-1) + "é"
+	preview := entity.ContentPreview(long)
+
+	// The two byte character straddles the limit and is left out whole
+	suite.Equal(strings.Repeat("a", entity.ContentPreviewSize-1), preview)
+}
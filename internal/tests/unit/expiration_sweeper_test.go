@@ -0,0 +1,118 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/Caixetadev/snippet/internal/tests/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExpirationSweeperTestSuite struct {
+	suite.Suite
+	mocksRepo *mocks.PostRepository
+}
+
+func (suite *ExpirationSweeperTestSuite) SetupTest() {
+	suite.mocksRepo = new(mocks.PostRepository)
+}
+
+func TestExpirationSweeperTestSuite(t *testing.T) {
+	suite.Run(t, new(ExpirationSweeperTestSuite))
+}
+
+func (suite *ExpirationSweeperTestSuite) TestSweep_DeletesInBatches() {
+	ctx := context.TODO()
+	now := time.Now()
+
+	sweeper := services.NewExpirationSweeper(suite.mocksRepo, time.Minute, 100, false)
+
+	suite.mocksRepo.On("DeleteExpired", ctx, now, 100).Return(100, nil).Twice()
+	suite.mocksRepo.On("DeleteExpired", ctx, now, 100).Return(42, nil).Once()
+
+	removed, err := sweeper.Sweep(ctx, now)
+
+	suite.NoError(err)
+	suite.Equal(242, removed)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+	suite.mocksRepo.AssertNotCalled(suite.T(), "ArchiveExpired", ctx, now, 100)
+}
+
+func (suite *ExpirationSweeperTestSuite) TestSweep_Archive() {
+	ctx := context.TODO()
+	now := time.Now()
+
+	sweeper := services.NewExpirationSweeper(suite.mocksRepo, time.Minute, 100, true)
+
+	suite.mocksRepo.On("ArchiveExpired", ctx, now, 100).Return(3, nil).Once()
+
+	removed, err := sweeper.Sweep(ctx, now)
+
+	suite.NoError(err)
+	suite.Equal(3, removed)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+	suite.mocksRepo.AssertNotCalled(suite.T(), "DeleteExpired", ctx, now, 100)
+}
+
+func (suite *ExpirationSweeperTestSuite) TestSweep_RepositoryError() {
+	ctx := context.TODO()
+	now := time.Now()
+
+	sweeper := services.NewExpirationSweeper(suite.mocksRepo, time.Minute, 100, false)
+
+	suite.mocksRepo.On("DeleteExpired", ctx, now, 100).Return(100, nil).Once()
+	suite.mocksRepo.On("DeleteExpired", ctx, now, 100).Return(0, errors.New("error")).Once()
+
+	removed, err := sweeper.Sweep(ctx, now)
+
+	suite.Error(err)
+	suite.Equal(100, removed)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *ExpirationSweeperTestSuite) TestCollectContent_InBatches() {
+	ctx := context.TODO()
+
+	sweeper := services.NewExpirationSweeper(suite.mocksRepo, time.Minute, 100, false)
+
+	suite.mocksRepo.On("DeleteUnreferencedContent", ctx, 100).Return(100, nil).Once()
+	suite.mocksRepo.On("DeleteUnreferencedContent", ctx, 100).Return(7, nil).Once()
+
+	removed, err := sweeper.CollectContent(ctx)
+
+	suite.NoError(err)
+	suite.Equal(107, removed)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *ExpirationSweeperTestSuite) TestRun_StopsOnCancel() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sweeper := services.NewExpirationSweeper(suite.mocksRepo, time.Hour, 100, false)
+
+	suite.mocksRepo.On("DeleteExpired", ctx, mock.AnythingOfType("time.Time"), 100).Return(0, nil).Run(func(mock.Arguments) {
+		cancel()
+	}).Once()
+
+	done := make(chan struct{})
+	go func() {
+		sweeper.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		suite.Fail("sweeper did not stop after cancellation")
+	}
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
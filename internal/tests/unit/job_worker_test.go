@@ -0,0 +1,151 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/Caixetadev/snippet/internal/tests/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type JobWorkerTestSuite struct {
+	suite.Suite
+	mocksRepo *mocks.JobRepository
+}
+
+func (suite *JobWorkerTestSuite) SetupTest() {
+	suite.mocksRepo = new(mocks.JobRepository)
+}
+
+func TestJobWorkerTestSuite(t *testing.T) {
+	suite.Run(t, new(JobWorkerTestSuite))
+}
+
+func (suite *JobWorkerTestSuite) claim(ctx context.Context, now time.Time, jobs ...*entity.Job) {
+	suite.mocksRepo.On("Claim", ctx, entity.EmailQueue, now, now.Add(-services.JobLockTimeout), 10).Return(jobs, nil).Once()
+}
+
+func (suite *JobWorkerTestSuite) job(attempts int) *entity.Job {
+	return &entity.Job{ID: uuid.New(), Queue: entity.EmailQueue, Attempts: attempts, MaxAttempts: 3}
+}
+
+func (suite *JobWorkerTestSuite) TestProcess_CompletesSuccessfulJobs() {
+	ctx := context.TODO()
+	now := time.Now()
+	first, second := suite.job(1), suite.job(1)
+
+	suite.claim(ctx, now, first, second)
+	suite.mocksRepo.On("Complete", ctx, first.ID, mock.AnythingOfType("time.Time")).Return(nil)
+	suite.mocksRepo.On("Complete", ctx, second.ID, mock.AnythingOfType("time.Time")).Return(nil)
+
+	handled := 0
+	worker := services.NewJobWorker(suite.mocksRepo, entity.EmailQueue, func(ctx context.Context, job *entity.Job) error {
+		handled++
+		return nil
+	}, time.Second, 10)
+
+	processed, err := worker.Process(ctx, now)
+
+	suite.NoError(err)
+	suite.Equal(2, processed)
+	suite.Equal(2, handled)
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *JobWorkerTestSuite) TestProcess_RetriesWithBackoff() {
+	ctx := context.TODO()
+	now := time.Now()
+	job := suite.job(2)
+
+	suite.claim(ctx, now, job)
+	suite.mocksRepo.On(
+		"Retry",
+		ctx,
+		job.ID,
+		mock.MatchedBy(func(runAt time.Time) bool {
+			delay := time.Until(runAt)
+			return delay > services.JobBackoff(2)-time.Second && delay <= services.JobBackoff(2)
+		}),
+		"smtp unavailable",
+		mock.AnythingOfType("time.Time"),
+	).Return(nil)
+
+	worker := services.NewJobWorker(suite.mocksRepo, entity.EmailQueue, func(ctx context.Context, job *entity.Job) error {
+		return errors.New("smtp unavailable")
+	}, time.Second, 10)
+
+	_, err := worker.Process(ctx, now)
+
+	suite.NoError(err)
+	suite.mocksRepo.AssertExpectations(suite.T())
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Complete", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *JobWorkerTestSuite) TestProcess_DeadLettersAfterMaxAttempts() {
+	ctx := context.TODO()
+	now := time.Now()
+	job := suite.job(3)
+
+	suite.claim(ctx, now, job)
+	suite.mocksRepo.On("Bury", ctx, job.ID, "smtp unavailable", mock.AnythingOfType("time.Time")).Return(nil)
+
+	worker := services.NewJobWorker(suite.mocksRepo, entity.EmailQueue, func(ctx context.Context, job *entity.Job) error {
+		return errors.New("smtp unavailable")
+	}, time.Second, 10)
+
+	_, err := worker.Process(ctx, now)
+
+	suite.NoError(err)
+	suite.mocksRepo.AssertExpectations(suite.T())
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Retry", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *JobWorkerTestSuite) TestProcess_BuriesJobsThatKeepCrashingWorkers() {
+	ctx := context.TODO()
+	now := time.Now()
+	job := suite.job(4)
+
+	suite.claim(ctx, now, job)
+	suite.mocksRepo.On("Bury", ctx, job.ID, "exceeded max attempts", mock.AnythingOfType("time.Time")).Return(nil)
+
+	worker := services.NewJobWorker(suite.mocksRepo, entity.EmailQueue, func(ctx context.Context, job *entity.Job) error {
+		suite.Fail("handler must not run")
+		return nil
+	}, time.Second, 10)
+
+	_, err := worker.Process(ctx, now)
+
+	suite.NoError(err)
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *JobWorkerTestSuite) TestProcess_RecoversFromPanics() {
+	ctx := context.TODO()
+	now := time.Now()
+	job := suite.job(1)
+
+	suite.claim(ctx, now, job)
+	suite.mocksRepo.On("Retry", ctx, job.ID, mock.AnythingOfType("time.Time"), "panic: boom", mock.AnythingOfType("time.Time")).Return(nil)
+
+	worker := services.NewJobWorker(suite.mocksRepo, entity.EmailQueue, func(ctx context.Context, job *entity.Job) error {
+		panic("boom")
+	}, time.Second, 10)
+
+	_, err := worker.Process(ctx, now)
+
+	suite.NoError(err)
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *JobWorkerTestSuite) TestJobBackoff() {
+	suite.Equal(services.JobBackoffBase, services.JobBackoff(1))
+	suite.Equal(2*services.JobBackoffBase, services.JobBackoff(2))
+	suite.Equal(8*services.JobBackoffBase, services.JobBackoff(4))
+	suite.Equal(services.JobBackoffMax, services.JobBackoff(20))
+}
@@ -0,0 +1,160 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/infra/memory"
+	"github.com/Caixetadev/snippet/internal/services"
+	httpmiddleware "github.com/Caixetadev/snippet/pkg/middleware/http"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+)
+
+type LockoutServiceTestSuite struct {
+	suite.Suite
+	store   *memory.AttemptStore
+	policy  entity.LockoutPolicy
+	lockout *services.LockoutService
+}
+
+func (suite *LockoutServiceTestSuite) SetupTest() {
+	suite.store = memory.NewAttemptStore()
+	suite.policy = entity.LockoutPolicy{Threshold: 3, BaseDelay: time.Minute, MaxDelay: 10 * time.Minute, Window: time.Hour}
+
+	ipPolicy := suite.policy
+	ipPolicy.Threshold = 5
+
+	suite.lockout = services.NewLockoutService(suite.store, map[string]entity.LockoutPolicy{
+		entity.AttemptScopeAccount: suite.policy,
+		entity.AttemptScopeIP:      ipPolicy,
+	})
+}
+
+func TestLockoutServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(LockoutServiceTestSuite))
+}
+
+func (suite *LockoutServiceTestSuite) TestLockFor_Exponential() {
+	suite.Equal(time.Duration(0), suite.policy.LockFor(2))
+	suite.Equal(time.Minute, suite.policy.LockFor(3))
+	suite.Equal(2*time.Minute, suite.policy.LockFor(4))
+	suite.Equal(8*time.Minute, suite.policy.LockFor(6))
+	suite.Equal(10*time.Minute, suite.policy.LockFor(7))
+	suite.Equal(10*time.Minute, suite.policy.LockFor(1000))
+}
+
+func (suite *LockoutServiceTestSuite) TestLocksAfterThreshold() {
+	ctx := context.TODO()
+	account := entity.AccountAttemptKey("User@Example.com ")
+
+	for i := 0; i < 2; i++ {
+		suite.NoError(suite.lockout.Fail(ctx, account))
+		suite.NoError(suite.lockout.Check(ctx, account))
+	}
+
+	suite.NoError(suite.lockout.Fail(ctx, account))
+
+	err := suite.lockout.Check(ctx, entity.AccountAttemptKey("user@example.com"))
+
+	var tooMany typesystem.TooManyRequests
+	suite.Require().ErrorAs(err, &tooMany)
+	suite.Equal(http.StatusTooManyRequests, tooMany.StatusCode)
+	suite.Equal(60, tooMany.RetryAfterSeconds())
+
+	suite.NoError(suite.lockout.Fail(ctx, account))
+
+	err = suite.lockout.Check(ctx, account)
+	suite.Require().ErrorAs(err, &tooMany)
+	suite.Equal(120, tooMany.RetryAfterSeconds())
+}
+
+func (suite *LockoutServiceTestSuite) TestScopesHaveOwnThresholds() {
+	ctx := context.TODO()
+	account := entity.AccountAttemptKey("user@example.com")
+	ip := entity.IPAttemptKey("203.0.113.7")
+
+	for i := 0; i < 3; i++ {
+		suite.NoError(suite.lockout.Fail(ctx, account, ip))
+	}
+
+	suite.Error(suite.lockout.Check(ctx, account))
+	suite.NoError(suite.lockout.Check(ctx, ip))
+	suite.NoError(suite.lockout.Check(ctx, entity.AccountAttemptKey("other@example.com"), ip))
+
+	suite.Error(suite.lockout.Check(ctx, entity.AccountAttemptKey("other@example.com"), ip, account), "any locked key locks the attempt")
+}
+
+func (suite *LockoutServiceTestSuite) TestResetForgetsFailures() {
+	ctx := context.TODO()
+	account := entity.AccountAttemptKey("user@example.com")
+
+	suite.NoError(suite.lockout.Fail(ctx, account))
+	suite.NoError(suite.lockout.Fail(ctx, account))
+	suite.NoError(suite.lockout.Reset(ctx, account))
+	suite.NoError(suite.lockout.Fail(ctx, account))
+
+	suite.NoError(suite.lockout.Check(ctx, account))
+}
+
+func (suite *LockoutServiceTestSuite) TestFailuresOutsideWindowStartOver() {
+	ctx := context.TODO()
+	key := entity.AccountAttemptKey("user@example.com").String()
+	start := time.Now()
+
+	suite.store.RecordFailure(ctx, key, start, time.Hour)
+	suite.store.RecordFailure(ctx, key, start.Add(time.Minute), time.Hour)
+
+	attempts, err := suite.store.RecordFailure(ctx, key, start.Add(2*time.Hour), time.Hour)
+
+	suite.NoError(err)
+	suite.Equal(1, attempts.Failures)
+}
+
+func (suite *LockoutServiceTestSuite) TestUntrackedKeysAreIgnored() {
+	ctx := context.TODO()
+
+	for i := 0; i < 10; i++ {
+		suite.NoError(suite.lockout.Fail(ctx, entity.PostAttemptKey("post"), entity.IPAttemptKey("")))
+	}
+
+	suite.NoError(suite.lockout.Check(ctx, entity.PostAttemptKey("post"), entity.IPAttemptKey("")))
+}
+
+func (suite *LockoutServiceTestSuite) TestPrune() {
+	ctx := context.TODO()
+	now := time.Now()
+
+	suite.store.RecordFailure(ctx, "old", now.Add(-2*time.Hour), time.Hour)
+	suite.store.RecordFailure(ctx, "recent", now, time.Hour)
+
+	removed, err := suite.store.PruneAttempts(ctx, now.Add(-time.Hour))
+
+	suite.NoError(err)
+	suite.Equal(1, removed)
+
+	attempts, _ := suite.store.FindAttempts(ctx, "recent")
+	suite.Equal(1, attempts.Failures)
+}
+
+func (suite *LockoutServiceTestSuite) TestErrorHandler_RetryAfter() {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(httpmiddleware.ErrorHandler())
+	router.GET("/", func(ctx *gin.Context) {
+		ctx.Error(typesystem.NewTooManyRequestsError("Too many failed attempts, try again later", 1500*time.Millisecond))
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	suite.Equal(http.StatusTooManyRequests, recorder.Code)
+	suite.Equal("2", recorder.Header().Get("Retry-After"))
+	suite.Contains(recorder.Body.String(), `"statusCode":429`)
+}
@@ -0,0 +1,246 @@
+package unit
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Caixetadev/snippet/pkg/mail"
+	"github.com/stretchr/testify/suite"
+)
+
+type MailTransportTestSuite struct {
+	suite.Suite
+}
+
+func TestMailTransportTestSuite(t *testing.T) {
+	suite.Run(t, new(MailTransportTestSuite))
+}
+
+func (suite *MailTransportTestSuite) message() *mail.Message {
+	return mail.NewMessage(
+		"Paste <noreply@example.com>",
+		"john@example.com",
+		"Réinitialiser le mot de passe",
+		"Hello John,\nfollow https://example.com/reset",
+		"<p>Hello John,</p><p><a href=\"https://example.com/reset\">Reset</a></p>",
+	)
+}
+
+// parseAlternative returns the decoded bodies of a multipart/alternative message keyed by media type
+func (suite *MailTransportTestSuite) parseAlternative(msg *netmail.Message) map[string]string {
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	suite.Require().NoError(err)
+	suite.Require().Equal("multipart/alternative", mediaType)
+
+	bodies := map[string]string{}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		suite.Require().NoError(err)
+
+		partType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		suite.Require().NoError(err)
+
+		body, err := io.ReadAll(part)
+		suite.Require().NoError(err)
+
+		bodies[partType] = string(body)
+	}
+
+	return bodies
+}
+
+func (suite *MailTransportTestSuite) TestMessage_Multipart() {
+	data, err := suite.message().Bytes()
+	suite.Require().NoError(err)
+
+	msg, err := netmail.ReadMessage(strings.NewReader(string(data)))
+	suite.Require().NoError(err)
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	suite.NoError(err)
+	suite.Equal("Réinitialiser le mot de passe", subject)
+	suite.Equal("john@example.com", msg.Header.Get("To"))
+	suite.Contains(msg.Header.Get("Message-ID"), "@example.com>")
+
+	bodies := suite.parseAlternative(msg)
+
+	suite.Equal("Hello John,\r\nfollow https://example.com/reset", bodies["text/plain"])
+	suite.Contains(bodies["text/html"], `<a href="https://example.com/reset">`)
+}
+
+func (suite *MailTransportTestSuite) TestMessage_HTMLOnly() {
+	msg := suite.message()
+	msg.Text = ""
+
+	data, err := msg.Bytes()
+	suite.Require().NoError(err)
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(string(data)))
+	suite.Require().NoError(err)
+
+	suite.Equal("text/html; charset=UTF-8", parsed.Header.Get("Content-Type"))
+}
+
+func (suite *MailTransportTestSuite) TestMessage_RequiresRecipient() {
+	msg := suite.message()
+	msg.To = nil
+
+	_, err := msg.Bytes()
+
+	suite.Error(err)
+}
+
+func (suite *MailTransportTestSuite) TestFileTransport() {
+	dir := filepath.Join(suite.T().TempDir(), "outbox")
+
+	transport, err := mail.NewFileTransport(dir)
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(transport.Send(context.TODO(), suite.message()))
+	suite.Require().NoError(transport.Send(context.TODO(), suite.message()))
+
+	entries, err := os.ReadDir(dir)
+	suite.Require().NoError(err)
+	suite.Len(entries, 2)
+
+	for _, entry := range entries {
+		suite.True(strings.HasSuffix(entry.Name(), ".eml"))
+		suite.False(strings.HasPrefix(entry.Name(), "."))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	suite.Require().NoError(err)
+
+	msg, err := netmail.ReadMessage(strings.NewReader(string(data)))
+	suite.Require().NoError(err)
+	suite.Contains(suite.parseAlternative(msg)["text/plain"], "Hello John")
+}
+
+// fakeSMTPServer accepts a single SMTP session without STARTTLS or AUTH and records it
+type fakeSMTPServer struct {
+	listener net.Listener
+	from     string
+	to       []string
+	data     string
+	done     chan struct{}
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &fakeSMTPServer{listener: listener, done: make(chan struct{})}
+	t.Cleanup(func() { listener.Close() })
+
+	go server.serve()
+
+	return server
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) serve() {
+	defer close(s.done)
+
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		command := strings.TrimSpace(line)
+		upper := strings.ToUpper(command)
+
+		switch {
+		case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(upper, "MAIL FROM:"):
+			s.from = strings.Trim(command[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			s.to = append(s.to, strings.Trim(command[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case upper == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+
+			s.data = data.String()
+			reply("250 OK")
+		case upper == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func (suite *MailTransportTestSuite) TestSMTPTransport() {
+	server := newFakeSMTPServer(suite.T())
+
+	transport := &mail.SMTPTransport{Host: "127.0.0.1", Port: server.port()}
+
+	err := transport.Send(context.TODO(), suite.message())
+	suite.Require().NoError(err)
+
+	<-server.done
+
+	suite.Equal("noreply@example.com", server.from)
+	suite.Equal([]string{"john@example.com"}, server.to)
+
+	msg, err := netmail.ReadMessage(strings.NewReader(server.data))
+	suite.Require().NoError(err)
+	suite.Contains(suite.parseAlternative(msg)["text/html"], "Hello John")
+}
+
+func (suite *MailTransportTestSuite) TestSMTPTransport_RequireTLS() {
+	server := newFakeSMTPServer(suite.T())
+
+	transport := &mail.SMTPTransport{Host: "127.0.0.1", Port: server.port(), RequireTLS: true}
+
+	err := transport.Send(context.TODO(), suite.message())
+
+	suite.ErrorIs(err, mail.ErrStartTLSUnsupported)
+	suite.Empty(server.from)
+}
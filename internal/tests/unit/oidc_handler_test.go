@@ -0,0 +1,116 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Caixetadev/snippet/internal/app"
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/handlers"
+	"github.com/Caixetadev/snippet/internal/services"
+	httpmiddleware "github.com/Caixetadev/snippet/pkg/middleware/http"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeOIDCService starts logins with a fixed state and records the callbacks it gets
+type fakeOIDCService struct {
+	callbacks []string
+}
+
+func (f *fakeOIDCService) Start(ctx context.Context, provider string) (string, string, error) {
+	return "https://idp.example.com/authorize", "state-" + provider, nil
+}
+
+func (f *fakeOIDCService) Callback(ctx context.Context, provider string, state string, code string) (*entity.User, error) {
+	f.callbacks = append(f.callbacks, state)
+	return nil, services.ErrOIDCLoginFailed
+}
+
+type OIDCHandlerTestSuite struct {
+	suite.Suite
+	oidc   *fakeOIDCService
+	server *httptest.Server
+	client *http.Client
+}
+
+func (suite *OIDCHandlerTestSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+
+	suite.oidc = &fakeOIDCService{}
+
+	handler := &handlers.AuthHandler{OIDCService: suite.oidc}
+
+	router := gin.New()
+	router.Use(httpmiddleware.ErrorHandler())
+
+	group := router.Group(app.BASE_PATH)
+	group.GET("/auth/oidc/:provider/start", handler.OIDCStart)
+	group.GET("/auth/oidc/:provider/callback", handler.OIDCCallback)
+
+	suite.server = httptest.NewServer(router)
+
+	jar, err := cookiejar.New(nil)
+	suite.NoError(err)
+
+	// The jar applies the cookie path like a browser, redirects to the provider are not followed
+	suite.client = &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (suite *OIDCHandlerTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func TestOIDCHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(OIDCHandlerTestSuite))
+}
+
+func (suite *OIDCHandlerTestSuite) get(path string) *http.Response {
+	response, err := suite.client.Get(suite.server.URL + app.BASE_PATH + path)
+	suite.NoError(err)
+
+	response.Body.Close()
+
+	return response
+}
+
+func (suite *OIDCHandlerTestSuite) TestStateCookieReachesCallback() {
+	response := suite.get("/auth/oidc/google/start")
+
+	suite.Equal(http.StatusFound, response.StatusCode)
+	suite.Equal("https://idp.example.com/authorize", response.Header.Get("Location"))
+
+	response = suite.get("/auth/oidc/google/callback?state=state-google&code=code")
+
+	suite.Equal(http.StatusUnauthorized, response.StatusCode)
+	suite.Equal([]string{"state-google"}, suite.oidc.callbacks)
+
+	// The callback clears the cookie, so the state cannot be used twice
+	callback, err := url.Parse(suite.server.URL + app.BASE_PATH + "/auth/oidc/google/callback")
+	suite.NoError(err)
+	suite.Empty(suite.client.Jar.Cookies(callback))
+}
+
+func (suite *OIDCHandlerTestSuite) TestStateCookieScopedToProvider() {
+	suite.get("/auth/oidc/google/start")
+
+	suite.get("/auth/oidc/github/callback?state=state-google&code=code")
+
+	suite.Empty(suite.oidc.callbacks)
+}
+
+func (suite *OIDCHandlerTestSuite) TestCallbackWithoutCookie() {
+	response := suite.get("/auth/oidc/google/callback?state=state-google&code=code")
+
+	suite.Equal(http.StatusUnauthorized, response.StatusCode)
+	suite.Empty(suite.oidc.callbacks)
+}
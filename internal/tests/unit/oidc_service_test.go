@@ -0,0 +1,376 @@
+package unit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/Caixetadev/snippet/internal/tests/mocks"
+	"github.com/Caixetadev/snippet/pkg/oidc"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	testClientID     = "paste"
+	testClientSecret = "secret"
+	testRedirectURL  = "http://paste.test/auth/oidc/company/callback"
+)
+
+// fakeIdP is a stand-in OpenID Connect provider. Authorizations are granted by calling
+// authorize directly with the parameters of the URL the user would be sent to.
+type fakeIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]url.Values
+
+	// claims are added to the ID tokens issued by the token endpoint
+	claims jwt.MapClaims
+	// signingKey signs the ID tokens instead of key when set
+	signingKey *rsa.PrivateKey
+}
+
+func newFakeIdP() *fakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	idp := &fakeIdP{key: key, codes: make(map[string]url.Values)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/token", idp.token)
+
+	idp.server = httptest.NewServer(mux)
+
+	return idp
+}
+
+func (idp *fakeIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 idp.server.URL,
+		"authorization_endpoint": idp.server.URL + "/authorize",
+		"token_endpoint":         idp.server.URL + "/token",
+		"jwks_uri":               idp.server.URL + "/jwks",
+	})
+}
+
+func (idp *fakeIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (idp *fakeIdP) authorize(authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		panic(err)
+	}
+
+	code := uuid.NewString()
+
+	idp.mu.Lock()
+	idp.codes[code] = u.Query()
+	idp.mu.Unlock()
+
+	return code
+}
+
+func (idp *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, _ := r.BasicAuth()
+	if clientID != testClientID || clientSecret != testClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	idp.mu.Lock()
+	params, ok := idp.codes[r.FormValue("code")]
+	delete(idp.codes, r.FormValue("code"))
+	idp.mu.Unlock()
+
+	if !ok ||
+		r.FormValue("redirect_uri") != params.Get("redirect_uri") ||
+		params.Get("code_challenge_method") != "S256" ||
+		oidc.ChallengeS256(r.FormValue("code_verifier")) != params.Get("code_challenge") {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   idp.server.URL,
+		"sub":   "subject-1",
+		"aud":   testClientID,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": params.Get("nonce"),
+	}
+
+	for k, v := range idp.claims {
+		claims[k] = v
+	}
+
+	signingKey := idp.key
+	if idp.signingKey != nil {
+		signingKey = idp.signingKey
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "k1"
+
+	idToken, err := token.SignedString(signingKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+type OIDCServiceTestSuite struct {
+	suite.Suite
+	idp          *fakeIdP
+	identityRepo *mocks.IdentityRepository
+	userRepo     *mocks.UserRepository
+	service      *services.OIDCService
+}
+
+func (suite *OIDCServiceTestSuite) SetupTest() {
+	suite.idp = newFakeIdP()
+	suite.identityRepo = new(mocks.IdentityRepository)
+	suite.userRepo = new(mocks.UserRepository)
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       suite.idp.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	}, suite.idp.server.Client())
+
+	suite.service = services.NewOIDCService(
+		map[string]services.OIDCProvider{"company": provider},
+		suite.identityRepo,
+		suite.userRepo,
+	)
+}
+
+func (suite *OIDCServiceTestSuite) TearDownTest() {
+	suite.idp.server.Close()
+}
+
+func TestOIDCServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(OIDCServiceTestSuite))
+}
+
+// login starts a login, lets the IdP authorize it and returns the state and code the
+// callback receives
+func (suite *OIDCServiceTestSuite) login(ctx context.Context) (string, string) {
+	var stored *entity.OIDCState
+	suite.identityRepo.On("CreateOIDCState", ctx, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*entity.OIDCState)
+	}).Once()
+
+	authURL, state, err := suite.service.Start(ctx, "company")
+	suite.Require().NoError(err)
+
+	u, err := url.Parse(authURL)
+	suite.Require().NoError(err)
+
+	query := u.Query()
+	suite.Equal(suite.idp.server.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	suite.Equal(state, query.Get("state"))
+	suite.Equal(stored.Nonce, query.Get("nonce"))
+	suite.Equal(oidc.ChallengeS256(stored.CodeVerifier), query.Get("code_challenge"))
+	suite.Equal(entity.HashToken(state), stored.StateHash)
+	suite.NotContains(authURL, stored.CodeVerifier)
+
+	suite.identityRepo.On("ConsumeOIDCState", ctx, stored.StateHash, mock.AnythingOfType("time.Time")).Return(stored, nil).Once()
+
+	return state, suite.idp.authorize(authURL)
+}
+
+func (suite *OIDCServiceTestSuite) TestCallback_ExistingIdentity() {
+	ctx := context.TODO()
+	user := &entity.User{ID: uuid.New(), Email: "user@example.com"}
+
+	state, code := suite.login(ctx)
+
+	suite.identityRepo.On("FindUserByIdentity", ctx, "company", "subject-1").Return(user, nil)
+
+	got, err := suite.service.Callback(ctx, "company", state, code)
+
+	suite.NoError(err)
+	suite.Equal(user, got)
+	suite.identityRepo.AssertNotCalled(suite.T(), "LinkIdentity", mock.Anything, mock.Anything)
+}
+
+func (suite *OIDCServiceTestSuite) TestCallback_CreatesUser() {
+	ctx := context.TODO()
+	suite.idp.claims = jwt.MapClaims{"email": "new@example.com", "email_verified": true, "name": "New User"}
+
+	state, code := suite.login(ctx)
+
+	suite.identityRepo.On("FindUserByIdentity", ctx, "company", "subject-1").Return((*entity.User)(nil), pgx.ErrNoRows)
+	suite.userRepo.On("FindOneByEmail", ctx, "new@example.com").Return((*entity.User)(nil), pgx.ErrNoRows)
+
+	var identity *entity.Identity
+	suite.identityRepo.On("CreateUserWithIdentity", ctx, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		identity = args.Get(2).(*entity.Identity)
+	})
+
+	user, err := suite.service.Callback(ctx, "company", state, code)
+
+	suite.NoError(err)
+	suite.Equal("New User", user.Name)
+	suite.Equal("new@example.com", user.Email)
+	suite.True(user.EmailVerified)
+	suite.Empty(user.Password)
+	suite.Equal(user.ID, identity.UserID)
+	suite.Equal("subject-1", identity.Subject)
+}
+
+func (suite *OIDCServiceTestSuite) TestCallback_LinksVerifiedEmail() {
+	ctx := context.TODO()
+	suite.idp.claims = jwt.MapClaims{"email": "user@example.com", "email_verified": true}
+	existing := &entity.User{ID: uuid.New(), Email: "user@example.com"}
+
+	state, code := suite.login(ctx)
+
+	suite.identityRepo.On("FindUserByIdentity", ctx, "company", "subject-1").Return((*entity.User)(nil), pgx.ErrNoRows)
+	suite.userRepo.On("FindOneByEmail", ctx, "user@example.com").Return(existing, nil)
+	suite.identityRepo.On("LinkIdentity", ctx, mock.MatchedBy(func(identity *entity.Identity) bool {
+		return identity.UserID == existing.ID && identity.Provider == "company" && identity.Subject == "subject-1"
+	})).Return(nil)
+
+	user, err := suite.service.Callback(ctx, "company", state, code)
+
+	suite.NoError(err)
+	suite.Equal(existing, user)
+	suite.identityRepo.AssertExpectations(suite.T())
+}
+
+func (suite *OIDCServiceTestSuite) TestCallback_DoesNotLinkUnverifiedEmail() {
+	ctx := context.TODO()
+	suite.idp.claims = jwt.MapClaims{"email": "user@example.com", "email_verified": false}
+
+	state, code := suite.login(ctx)
+
+	suite.identityRepo.On("FindUserByIdentity", ctx, "company", "subject-1").Return((*entity.User)(nil), pgx.ErrNoRows)
+	suite.userRepo.On("FindOneByEmail", ctx, "user@example.com").Return(&entity.User{ID: uuid.New()}, nil)
+
+	_, err := suite.service.Callback(ctx, "company", state, code)
+
+	suite.Equal(services.ErrOIDCEmailNotVerified, err)
+	suite.identityRepo.AssertNotCalled(suite.T(), "LinkIdentity", mock.Anything, mock.Anything)
+}
+
+func (suite *OIDCServiceTestSuite) TestCallback_NonceMismatch() {
+	ctx := context.TODO()
+	suite.idp.claims = jwt.MapClaims{"nonce": "replayed"}
+
+	state, code := suite.login(ctx)
+
+	_, err := suite.service.Callback(ctx, "company", state, code)
+
+	suite.Equal(services.ErrOIDCLoginFailed, err)
+	suite.identityRepo.AssertNotCalled(suite.T(), "FindUserByIdentity", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *OIDCServiceTestSuite) TestCallback_WrongAudience() {
+	ctx := context.TODO()
+	suite.idp.claims = jwt.MapClaims{"aud": "another-client"}
+
+	state, code := suite.login(ctx)
+
+	_, err := suite.service.Callback(ctx, "company", state, code)
+
+	suite.Equal(services.ErrOIDCLoginFailed, err)
+}
+
+func (suite *OIDCServiceTestSuite) TestCallback_ExpiredIDToken() {
+	ctx := context.TODO()
+	suite.idp.claims = jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}
+
+	state, code := suite.login(ctx)
+
+	_, err := suite.service.Callback(ctx, "company", state, code)
+
+	suite.Equal(services.ErrOIDCLoginFailed, err)
+}
+
+func (suite *OIDCServiceTestSuite) TestCallback_ForgedSignature() {
+	ctx := context.TODO()
+
+	forger, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Require().NoError(err)
+	suite.idp.signingKey = forger
+
+	state, code := suite.login(ctx)
+
+	_, err = suite.service.Callback(ctx, "company", state, code)
+
+	suite.Equal(services.ErrOIDCLoginFailed, err)
+}
+
+func (suite *OIDCServiceTestSuite) TestCallback_CodeRedeemedOnce() {
+	ctx := context.TODO()
+	user := &entity.User{ID: uuid.New()}
+
+	state, code := suite.login(ctx)
+
+	suite.identityRepo.On("FindUserByIdentity", ctx, "company", "subject-1").Return(user, nil)
+
+	_, err := suite.service.Callback(ctx, "company", state, code)
+	suite.NoError(err)
+
+	suite.identityRepo.On("ConsumeOIDCState", ctx, entity.HashToken(state), mock.AnythingOfType("time.Time")).Return((*entity.OIDCState)(nil), pgx.ErrNoRows).Once()
+
+	_, err = suite.service.Callback(ctx, "company", state, code)
+	suite.Equal(typesystem.TokenInvalidError, err)
+}
+
+func (suite *OIDCServiceTestSuite) TestCallback_StateOfAnotherProvider() {
+	ctx := context.TODO()
+	state := "state"
+
+	suite.identityRepo.On("ConsumeOIDCState", ctx, entity.HashToken(state), mock.AnythingOfType("time.Time")).
+		Return(entity.NewOIDCState("other", state, "nonce", "verifier"), nil)
+
+	_, err := suite.service.Callback(ctx, "company", state, "code")
+
+	suite.Equal(typesystem.TokenInvalidError, err)
+}
+
+func (suite *OIDCServiceTestSuite) TestStart_UnknownProvider() {
+	_, _, err := suite.service.Start(context.TODO(), "unknown")
+
+	suite.Equal(services.ErrUnknownOIDCProvider, err)
+}
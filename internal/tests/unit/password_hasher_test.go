@@ -0,0 +1,111 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Caixetadev/snippet/pkg/passwordhash"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2Params keep the tests fast
+var testArgon2Params = passwordhash.Argon2Params{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+type PasswordHasherTestSuite struct {
+	suite.Suite
+	argon2id *passwordhash.Argon2idPasswordHasher
+	bcrypt   *passwordhash.BcryptPasswordHasher
+}
+
+func (suite *PasswordHasherTestSuite) SetupTest() {
+	suite.argon2id = &passwordhash.Argon2idPasswordHasher{Params: testArgon2Params}
+	suite.bcrypt = &passwordhash.BcryptPasswordHasher{Cost: bcrypt.MinCost}
+}
+
+func TestPasswordHasherTestSuite(t *testing.T) {
+	suite.Run(t, new(PasswordHasherTestSuite))
+}
+
+func (suite *PasswordHasherTestSuite) TestArgon2id_PHCFormat() {
+	hash, err := suite.argon2id.GenerateFromPassword([]byte("secret"))
+	suite.Require().NoError(err)
+
+	parts := strings.Split(string(hash), "$")
+	suite.Len(parts, 6)
+	suite.Equal("argon2id", parts[1])
+	suite.Equal("v=19", parts[2])
+	suite.Equal("m=64,t=1,p=1", parts[3])
+	suite.Equal(passwordhash.AlgorithmArgon2id, passwordhash.Identify(hash))
+
+	other, err := suite.argon2id.GenerateFromPassword([]byte("secret"))
+	suite.Require().NoError(err)
+	suite.NotEqual(hash, other, "every hash gets its own salt")
+}
+
+func (suite *PasswordHasherTestSuite) TestArgon2id_Compare() {
+	hash, err := suite.argon2id.GenerateFromPassword([]byte("secret"))
+	suite.Require().NoError(err)
+
+	suite.NoError(suite.argon2id.CompareHashAndPassword(hash, []byte("secret")))
+	suite.Equal(passwordhash.ErrMismatchedHashAndPassword, suite.argon2id.CompareHashAndPassword(hash, []byte("wrong")))
+}
+
+// TestArgon2id_KnownHash checks compatibility with hashes made by other implementations
+func (suite *PasswordHasherTestSuite) TestArgon2id_KnownHash() {
+	hash := []byte("$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc")
+
+	suite.NoError(passwordhash.Compare(hash, []byte("password")))
+}
+
+func (suite *PasswordHasherTestSuite) TestCompare_AcrossAlgorithms() {
+	bcryptHash, err := suite.bcrypt.GenerateFromPassword([]byte("secret"))
+	suite.Require().NoError(err)
+
+	argon2Hash, err := suite.argon2id.GenerateFromPassword([]byte("secret"))
+	suite.Require().NoError(err)
+
+	suite.NoError(suite.argon2id.CompareHashAndPassword(bcryptHash, []byte("secret")))
+	suite.NoError(suite.bcrypt.CompareHashAndPassword(argon2Hash, []byte("secret")))
+	suite.Error(suite.argon2id.CompareHashAndPassword([]byte(""), []byte("")))
+	suite.Error(suite.argon2id.CompareHashAndPassword([]byte("$argon2id$v=19$m=64,t=1,p=1$bad"), []byte("secret")))
+}
+
+func (suite *PasswordHasherTestSuite) TestArgon2id_NeedsRehash() {
+	current, err := suite.argon2id.GenerateFromPassword([]byte("secret"))
+	suite.Require().NoError(err)
+
+	bcryptHash, err := suite.bcrypt.GenerateFromPassword([]byte("secret"))
+	suite.Require().NoError(err)
+
+	stronger := &passwordhash.Argon2idPasswordHasher{Params: testArgon2Params}
+	stronger.Params.Memory = 128
+
+	suite.False(suite.argon2id.NeedsRehash(current))
+	suite.True(suite.argon2id.NeedsRehash(bcryptHash))
+	suite.True(stronger.NeedsRehash(current))
+}
+
+func (suite *PasswordHasherTestSuite) TestBcrypt_NeedsRehash() {
+	hash, err := suite.bcrypt.GenerateFromPassword([]byte("secret"))
+	suite.Require().NoError(err)
+
+	argon2Hash, err := suite.argon2id.GenerateFromPassword([]byte("secret"))
+	suite.Require().NoError(err)
+
+	suite.False(suite.bcrypt.NeedsRehash(hash))
+	suite.True(suite.bcrypt.NeedsRehash(argon2Hash))
+	suite.True((&passwordhash.BcryptPasswordHasher{Cost: bcrypt.MinCost + 1}).NeedsRehash(hash))
+}
+
+func (suite *PasswordHasherTestSuite) TestNew_UnknownAlgorithm() {
+	_, err := passwordhash.New("md5", 0, testArgon2Params)
+
+	suite.Error(err)
+}
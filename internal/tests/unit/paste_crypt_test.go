@@ -0,0 +1,99 @@
+package unit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Caixetadev/snippet/pkg/pastecrypt"
+	"github.com/stretchr/testify/suite"
+)
+
+type PasteCryptTestSuite struct {
+	suite.Suite
+}
+
+func TestPasteCryptTestSuite(t *testing.T) {
+	suite.Run(t, new(PasteCryptTestSuite))
+}
+
+func (suite *PasteCryptTestSuite) TestEncryptDecrypt() {
+	key, err := pastecrypt.NewKey()
+	suite.NoError(err)
+
+	envelope, err := pastecrypt.Encrypt([]byte("AWS_SECRET_KEY=abc"), key, []byte("hunter22"))
+	suite.NoError(err)
+
+	data, err := envelope.Marshal()
+	suite.NoError(err)
+	suite.NotContains(string(data), "AWS_SECRET_KEY")
+
+	parsed, err := pastecrypt.Parse(data)
+	suite.NoError(err)
+
+	fragment, err := pastecrypt.DecodeKey(pastecrypt.EncodeKey(key))
+	suite.NoError(err)
+
+	plaintext, err := pastecrypt.Decrypt(parsed, fragment, []byte("hunter22"))
+	suite.NoError(err)
+	suite.Equal("AWS_SECRET_KEY=abc", string(plaintext))
+
+	_, err = pastecrypt.Decrypt(parsed, fragment, nil)
+	suite.Equal(pastecrypt.ErrDecrypt, err)
+
+	other, err := pastecrypt.NewKey()
+	suite.NoError(err)
+
+	_, err = pastecrypt.Decrypt(parsed, other, []byte("hunter22"))
+	suite.Equal(pastecrypt.ErrDecrypt, err)
+}
+
+func (suite *PasteCryptTestSuite) TestParse_Invalid() {
+	valid := map[string]any{
+		"v":          1,
+		"cipher":     "aes-256-gcm",
+		"kdf":        "pbkdf2-sha256",
+		"iterations": 100000,
+		"salt":       "AAAAAAAAAAAAAAAAAAAAAA==",
+		"iv":         "AAAAAAAAAAAAAAAA",
+		"ct":         "AAAAAAAAAAAAAAAAAAAAAA==",
+	}
+
+	data, err := json.Marshal(valid)
+	suite.NoError(err)
+
+	_, err = pastecrypt.Parse(data)
+	suite.NoError(err)
+
+	changes := []map[string]any{
+		{"v": 2},
+		{"cipher": "aes-128-cbc"},
+		{"kdf": "none"},
+		{"iterations": 1},
+		{"salt": "AAAA"},
+		{"iv": "AAAAAAAAAAAAAAAAAAAA"},
+		{"ct": ""},
+		{"key": "AAAA"},
+	}
+
+	for _, change := range changes {
+		envelope := map[string]any{}
+		for name, value := range valid {
+			envelope[name] = value
+		}
+
+		for name, value := range change {
+			envelope[name] = value
+		}
+
+		data, err := json.Marshal(envelope)
+		suite.NoError(err)
+
+		_, err = pastecrypt.Parse(data)
+		suite.Equal(pastecrypt.ErrInvalidEnvelope, err, change)
+	}
+
+	for _, data := range []string{"", "plain text", "{}", `{"v":1} {"v":1}`} {
+		_, err = pastecrypt.Parse([]byte(data))
+		suite.Equal(pastecrypt.ErrInvalidEnvelope, err, data)
+	}
+}
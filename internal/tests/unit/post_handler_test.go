@@ -0,0 +1,274 @@
+package unit
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/config"
+	"github.com/Caixetadev/snippet/internal/app"
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/handlers"
+	"github.com/Caixetadev/snippet/internal/infra/memory"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/Caixetadev/snippet/internal/tests/mocks"
+	"github.com/Caixetadev/snippet/pkg/contentcrypto"
+	httpmiddleware "github.com/Caixetadev/snippet/pkg/middleware/http"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type PostHandlerTestSuite struct {
+	suite.Suite
+	mocksRepo           *mocks.PostRepository
+	mocksPasswordHasher *mocks.PasswordHasher
+	router              *gin.Engine
+}
+
+func (suite *PostHandlerTestSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+
+	suite.mocksRepo = new(mocks.PostRepository)
+	suite.mocksPasswordHasher = new(mocks.PasswordHasher)
+
+	lockout := services.NewLockoutService(memory.NewAttemptStore(), map[string]entity.LockoutPolicy{
+		entity.AttemptScopePost: {Threshold: 3, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+		entity.AttemptScopeIP:   {Threshold: 4, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+	})
+
+	postService := services.NewPostService(suite.mocksRepo, new(mocks.Validator), suite.mocksPasswordHasher, lockout, entity.ContentLimits{MaxSize: 64, MaxPostSize: 96}, contentcrypto.Keys{})
+
+	cfg := &config.Config{ContentMaxPostSize: 96}
+
+	handler := &handlers.PostHandler{
+		PostService: postService,
+		Env:         cfg,
+	}
+
+	router, err := app.NewRouter(cfg)
+	suite.Require().NoError(err)
+
+	suite.router = router
+	suite.router.Use(httpmiddleware.ErrorHandler())
+
+	group := suite.router.Group(app.BASE_PATH)
+	group.POST("/post/create", handler.Post)
+	group.PATCH("/post/:id", handler.UpdatePost)
+	group.GET("/raw/:id", handler.GetRawPost)
+	group.GET("/raw/:id/:filename", handler.GetRawPostFile)
+}
+
+func TestPostHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(PostHandlerTestSuite))
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	reader io.Reader
+	read   int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.read += n
+
+	return n, err
+}
+
+func (suite *PostHandlerTestSuite) serve(method string, path string, body io.Reader) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, app.BASE_PATH+path, body)
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+// oversizedPost is a request body far larger than posts limited to 96 bytes can need
+func oversizedPost() []byte {
+	body, _ := json.Marshal(map[string]string{
+		"title":   "Title",
+		"content": strings.Repeat("a", 1<<20),
+	})
+
+	return body
+}
+
+func (suite *PostHandlerTestSuite) TestPost_RequestTooLarge() {
+	body := oversizedPost()
+	reader := &countingReader{reader: bytes.NewReader(body)}
+
+	recorder := suite.serve(http.MethodPost, "/post/create", reader)
+
+	suite.Equal(http.StatusRequestEntityTooLarge, recorder.Code)
+	suite.Less(reader.read, len(body)/2)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Insert")
+}
+
+func (suite *PostHandlerTestSuite) TestUpdatePost_RequestTooLarge() {
+	body := oversizedPost()
+	reader := &countingReader{reader: bytes.NewReader(body)}
+
+	recorder := suite.serve(http.MethodPatch, "/post/4f1d3f5c-6c2b-4d8a-9a55-3f1e2b7c9d10", reader)
+
+	suite.Equal(http.StatusRequestEntityTooLarge, recorder.Code)
+	suite.Less(reader.read, len(body)/2)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "FindOneByID")
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Update")
+}
+
+func (suite *PostHandlerTestSuite) TestPost_MalformedRequest() {
+	recorder := suite.serve(http.MethodPost, "/post/create", strings.NewReader(`{"title":`))
+
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+}
+
+func (suite *PostHandlerTestSuite) get(path string, header http.Header) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, app.BASE_PATH+path, nil)
+	for name, values := range header {
+		request.Header[name] = values
+	}
+
+	recorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func (suite *PostHandlerTestSuite) TestGetRawPost() {
+	postID := "4f1d3f5c-6c2b-4d8a-9a55-3f1e2b7c9d10"
+	post := &entity.PostOutput{ID: postID, Content: "<h1>raw</h1>\n", Visibility: entity.Public}
+
+	suite.mocksRepo.On("FindOneByID", mock.Anything, postID).Return(post, nil).Once()
+
+	recorder := suite.get("/raw/"+postID, nil)
+
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Equal("<h1>raw</h1>\n", recorder.Body.String())
+
+	header := recorder.Result().Header
+	suite.Equal("text/plain; charset=utf-8", header.Get("Content-Type"))
+	suite.Equal("nosniff", header.Get("X-Content-Type-Options"))
+	suite.Equal(`inline; filename=`+postID+`.txt`, header.Get("Content-Disposition"))
+}
+
+func (suite *PostHandlerTestSuite) TestGetRawPost_ContentLength() {
+	postID := "4f1d3f5c-6c2b-4d8a-9a55-3f1e2b7c9d10"
+
+	// Larger than the buffer net/http sizes responses from on its own
+	content := strings.Repeat("0123456789\n", 500)
+	post := &entity.PostOutput{ID: postID, Content: content, Visibility: entity.Public}
+
+	suite.mocksRepo.On("FindOneByID", mock.Anything, postID).Return(post, nil).Once()
+
+	recorder := suite.get("/raw/"+postID, nil)
+
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Equal(int64(len(content)), recorder.Result().ContentLength)
+	suite.Equal(content, recorder.Body.String())
+}
+
+func (suite *PostHandlerTestSuite) TestGetRawPostFile() {
+	postID := "4f1d3f5c-6c2b-4d8a-9a55-3f1e2b7c9d10"
+	post := &entity.PostOutput{
+		ID:         postID,
+		Visibility: entity.Public,
+		Files: []entity.PostFile{
+			{Filename: "main.go", Content: "package main\n"},
+			{Filename: "my notes.md", Content: "# notes\n"},
+		},
+	}
+
+	suite.mocksRepo.On("FindOneByID", mock.Anything, postID).Return(post, nil).Twice()
+
+	recorder := suite.get("/raw/"+postID+"/my%20notes.md", nil)
+
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Equal("# notes\n", recorder.Body.String())
+	suite.Equal(`inline; filename="my notes.md"`, recorder.Result().Header.Get("Content-Disposition"))
+
+	recorder = suite.get("/raw/"+postID+"/missing.go", nil)
+
+	suite.Equal(http.StatusNotFound, recorder.Code)
+}
+
+func (suite *PostHandlerTestSuite) TestGetRawPost_Password() {
+	postID := "4f1d3f5c-6c2b-4d8a-9a55-3f1e2b7c9d10"
+	post := &entity.PostOutput{ID: postID, Content: "secret", Password: "hash", HasPassword: true, Visibility: entity.Public}
+
+	suite.mocksRepo.On("FindOneByID", mock.Anything, postID).Return(post, nil)
+	suite.mocksPasswordHasher.On("CompareHashAndPassword", []byte("hash"), []byte("")).Return(errors.New("error")).Once()
+	suite.mocksPasswordHasher.On("CompareHashAndPassword", []byte("hash"), []byte("123")).Return(nil).Twice()
+	suite.mocksPasswordHasher.On("NeedsRehash", []byte("hash")).Return(false)
+
+	recorder := suite.get("/raw/"+postID, nil)
+
+	suite.Equal(http.StatusUnauthorized, recorder.Code)
+	suite.NotContains(recorder.Body.String(), "secret")
+
+	recorder = suite.get("/raw/"+postID, http.Header{"X-Post-Password": {"123"}})
+
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Equal("secret", recorder.Body.String())
+
+	recorder = suite.get("/raw/"+postID+"?password=123", nil)
+
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Equal("secret", recorder.Body.String())
+
+	suite.mocksPasswordHasher.AssertExpectations(suite.T())
+}
+
+func (suite *PostHandlerTestSuite) TestGetRawPost_BurnAfterRead() {
+	postID := "4f1d3f5c-6c2b-4d8a-9a55-3f1e2b7c9d10"
+	post := &entity.PostOutput{ID: postID, Content: "once", DeleteAfterView: true, Visibility: entity.Public}
+
+	suite.mocksRepo.On("FindOneByID", mock.Anything, postID).Return(post, nil).Once()
+	suite.mocksRepo.On("Burn", mock.Anything, postID).Return(post, nil).Once()
+
+	recorder := suite.get("/raw/"+postID, nil)
+
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Equal("once", recorder.Body.String())
+
+	suite.mocksRepo.On("FindOneByID", mock.Anything, postID).Return((*entity.PostOutput)(nil), sql.ErrNoRows).Once()
+
+	recorder = suite.get("/raw/"+postID, nil)
+
+	suite.Equal(http.StatusNotFound, recorder.Code)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostHandlerTestSuite) TestGetRawPost_ForwardedForDoesNotResetLockout() {
+	posts := []string{"4f1d3f5c-6c2b-4d8a-9a55-3f1e2b7c9d10", "8a0c2e4b-1d3f-4a5b-8c7d-9e0f1a2b3c4d"}
+
+	for _, postID := range posts {
+		post := &entity.PostOutput{ID: postID, Content: "secret", Password: "hash", HasPassword: true, Visibility: entity.Public}
+		suite.mocksRepo.On("FindOneByID", mock.Anything, postID).Return(post, nil)
+	}
+
+	suite.mocksPasswordHasher.On("CompareHashAndPassword", []byte("hash"), []byte("wrong")).Return(errors.New("error"))
+
+	// Guesses spread over two posts stay below the post threshold, each claiming another address
+	for i, postID := range []string{posts[0], posts[0], posts[1], posts[1]} {
+		recorder := suite.get("/raw/"+postID+"?password=wrong", http.Header{"X-Forwarded-For": {fmt.Sprintf("198.51.100.%d", i+1)}})
+		suite.Equal(http.StatusUnauthorized, recorder.Code)
+	}
+
+	recorder := suite.get("/raw/"+posts[1]+"?password=wrong", http.Header{"X-Forwarded-For": {"198.51.100.99"}})
+
+	suite.Equal(http.StatusTooManyRequests, recorder.Code)
+}
@@ -1,16 +1,27 @@
 package unit
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
 	"context"
 	"database/sql"
 	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/infra/memory"
 	"github.com/Caixetadev/snippet/internal/services"
 	"github.com/Caixetadev/snippet/internal/tests/mocks"
 	"github.com/Caixetadev/snippet/internal/utils"
+	"github.com/Caixetadev/snippet/pkg/contentcrypto"
+	"github.com/Caixetadev/snippet/pkg/highlight"
+	"github.com/Caixetadev/snippet/pkg/pastecrypt"
 	"github.com/Caixetadev/snippet/pkg/typesystem"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
@@ -23,13 +34,22 @@ type PostServiceTestSuite struct {
 	validation          *mocks.Validator
 	postService         *services.PostService
 	mocksPasswordHasher *mocks.PasswordHasher
+	lockout             *services.LockoutService
+	keys                contentcrypto.Keys
 }
 
 func (suite *PostServiceTestSuite) SetupTest() {
 	suite.mocksRepo = new(mocks.PostRepository)
 	suite.validation = new(mocks.Validator)
 	suite.mocksPasswordHasher = new(mocks.PasswordHasher)
-	suite.postService = services.NewPostService(suite.mocksRepo, suite.validation, suite.mocksPasswordHasher)
+	suite.lockout = services.NewLockoutService(memory.NewAttemptStore(), map[string]entity.LockoutPolicy{
+		entity.AttemptScopePost: {Threshold: 3, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+	})
+	suite.keys = contentcrypto.Keys{
+		MasterKey: bytes.Repeat([]byte{7}, contentcrypto.KeySize),
+		Password:  contentcrypto.PasswordParams{Memory: 64, Iterations: 1, Parallelism: 1},
+	}
+	suite.postService = services.NewPostService(suite.mocksRepo, suite.validation, suite.mocksPasswordHasher, suite.lockout, entity.ContentLimits{MaxSize: 64, MaxPostSize: 96}, suite.keys)
 }
 
 func TestPostServiceTestSuite(t *testing.T) {
@@ -59,6 +79,41 @@ func (suite *PostServiceTestSuite) TestCreate() {
 	suite.validation.AssertExpectations(suite.T())
 }
 
+func (suite *PostServiceTestSuite) TestCreate_ContentTooLarge() {
+	ctx := context.TODO()
+
+	userID := "22c15b0d-5445-4c84-a52a-40888798d1d0"
+
+	inputs := []*entity.PostInput{
+		{UserID: &userID, Title: "Title", Content: strings.Repeat("a", 65)},
+		{UserID: &userID, Title: "Title", Files: []entity.PostFile{{Filename: "a.txt", Content: strings.Repeat("a", 65)}}},
+		{UserID: &userID, Title: "Title", Content: strings.Repeat("a", 64), Files: []entity.PostFile{{Filename: "a.txt", Content: strings.Repeat("a", 33)}}},
+	}
+
+	for _, input := range inputs {
+		suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+
+		err := suite.postService.Create(ctx, input)
+
+		suite.Equal(services.ErrContentTooLarge, err)
+	}
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Insert", mock.Anything, mock.Anything)
+}
+
+func (suite *PostServiceTestSuite) TestUpdatePost_ContentTooLarge() {
+	ctx := context.TODO()
+	userID := uuid.New()
+	owner := userID.String()
+
+	suite.mocksRepo.On("FindOneByID", ctx, "abc").Return(&entity.PostOutput{ID: "abc", UserID: &owner}, nil).Once()
+
+	err := suite.postService.UpdatePost(ctx, &entity.PostUpdateInput{Content: strings.Repeat("a", 65)}, userID, "abc", "")
+
+	suite.Equal(services.ErrContentTooLarge, err)
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+}
+
 func (suite *PostServiceTestSuite) TestCreatePrivatePostByUnauthenticatedUser() {
 	ctx := context.TODO()
 
@@ -115,7 +170,7 @@ func (suite *PostServiceTestSuite) TestCreateValidatePasswordLength() {
 		UserID:      &userID,
 		Title:       "Title",
 		Content:     "Body",
-		Password:    "12",
+		Password:    "1234567",
 		Visibility:  "public",
 		HasPassword: true,
 	}
@@ -139,13 +194,13 @@ func (suite *PostServiceTestSuite) TestCreatePrivate() {
 		UserID:      &userID,
 		Title:       "Title",
 		Content:     "Body",
-		Password:    "123",
+		Password:    "12345678",
 		HasPassword: true,
 	}
 
 	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
 	suite.mocksRepo.On("Insert", ctx, mock.AnythingOfType("*entity.PostInput")).Return(nil).Once()
-	suite.mocksPasswordHasher.On("GenerateFromPassword", []byte(input.Password), 10).Return([]byte("password_hashed"), nil)
+	suite.mocksPasswordHasher.On("GenerateFromPassword", []byte(input.Password)).Return([]byte("password_hashed"), nil)
 
 	err := suite.postService.Create(ctx, input)
 
@@ -166,12 +221,12 @@ func (suite *PostServiceTestSuite) TestCreatePrivateError() {
 		UserID:      &userID,
 		Title:       "Title",
 		Content:     "Body",
-		Password:    "123",
+		Password:    "12345678",
 		HasPassword: true,
 	}
 
 	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
-	suite.mocksPasswordHasher.On("GenerateFromPassword", []byte(input.Password), 10).Return([]byte(""), errors.New("error"))
+	suite.mocksPasswordHasher.On("GenerateFromPassword", []byte(input.Password)).Return([]byte(""), errors.New("error"))
 
 	err := suite.postService.Create(ctx, input)
 
@@ -259,8 +314,7 @@ func (suite *PostServiceTestSuite) TestGetPosts() {
 		},
 	}
 
-	suite.mocksRepo.On("CountUserPosts", ctx, userID).Return(10, nil).Once()
-	suite.mocksRepo.On("FindAll", ctx, mock.Anything).Return(output, nil).Once()
+	suite.mocksRepo.On("FindAll", ctx, userID, 1).Return(output, 1, nil).Once()
 
 	posts, _, err := suite.postService.GetPosts(ctx, userID, page)
 
@@ -276,8 +330,8 @@ func (suite *PostServiceTestSuite) TestGetPosts_PostRepositoryError() {
 	userID := uuid.New()
 	page := "1"
 
-	suite.mocksRepo.On("FindAll", ctx, mock.Anything).Return([]*entity.PostOutput{}, errors.New("error")).Once()
-	suite.mocksRepo.On("CountUserPosts", ctx, userID).Return(10, nil).Once()
+	suite.mocksRepo.On("FindAll", ctx, userID, 1).Return([]*entity.PostOutput{}, 0, errors.New("error")).Once()
+
 	posts, _, err := suite.postService.GetPosts(ctx, userID, page)
 
 	suite.Equal(typesystem.ServerError, err)
@@ -303,7 +357,7 @@ func (suite *PostServiceTestSuite) TestDeletePost() {
 	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(output, nil).Once()
 	suite.mocksRepo.On("Delete", ctx, postID).Return(nil).Once()
 
-	err := suite.postService.DeletePost(ctx, postID, userID)
+	err := suite.postService.DeletePost(ctx, postID, userID, "")
 
 	suite.NoError(err)
 
@@ -328,7 +382,7 @@ func (suite *PostServiceTestSuite) TestDeletePost_UserNonAuth() {
 
 	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(output, nil).Once()
 
-	err := suite.postService.DeletePost(ctx, postID, userIDNon)
+	err := suite.postService.DeletePost(ctx, postID, userIDNon, "")
 
 	suite.Equal(typesystem.Forbidden, err)
 
@@ -343,7 +397,7 @@ func (suite *PostServiceTestSuite) TestDeletePost_PostNotFound() {
 
 	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(&entity.PostOutput{}, sql.ErrNoRows).Once()
 
-	err := suite.postService.DeletePost(ctx, postID, userID)
+	err := suite.postService.DeletePost(ctx, postID, userID, "")
 
 	suite.Equal(typesystem.NotFound, err)
 
@@ -358,7 +412,7 @@ func (suite *PostServiceTestSuite) TestDeletePost_GetPostsRepositoryError() {
 
 	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(&entity.PostOutput{}, errors.New("error")).Once()
 
-	err := suite.postService.DeletePost(ctx, postID, userID)
+	err := suite.postService.DeletePost(ctx, postID, userID, "")
 
 	suite.Equal(typesystem.ServerError, err)
 
@@ -382,9 +436,1100 @@ func (suite *PostServiceTestSuite) TestDeletePost_DeleteRepositoryError() {
 	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(output, nil).Once()
 	suite.mocksRepo.On("Delete", ctx, postID).Return(errors.New("error")).Once()
 
-	err := suite.postService.DeletePost(ctx, postID, userID)
+	err := suite.postService.DeletePost(ctx, postID, userID, "")
 
 	suite.Equal(typesystem.ServerError, err)
 
 	suite.mocksRepo.AssertExpectations(suite.T())
 }
+
+func (suite *PostServiceTestSuite) TestGetRevisions() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{ID: postID, Visibility: entity.Public, Revision: 2}
+	revisions := []*entity.PostRevision{
+		{PostID: postID, Revision: 2, Title: "Title"},
+		{PostID: postID, Revision: 1, Title: "Title"},
+	}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+	suite.mocksRepo.On("FindRevisions", ctx, postID).Return(revisions, nil).Once()
+
+	output, err := suite.postService.GetRevisions(ctx, postID, "", "")
+
+	suite.NoError(err)
+	suite.Equal(revisions, output)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestGetRevisions_WrongPassword() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{ID: postID, Visibility: entity.Public, HasPassword: true, Password: "hash"}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+	suite.mocksPasswordHasher.On("CompareHashAndPassword", []byte("hash"), []byte("wrong")).Return(errors.New("error")).Once()
+
+	output, err := suite.postService.GetRevisions(ctx, postID, "", "wrong")
+
+	suite.Equal(typesystem.Unauthorized, err)
+	suite.Nil(output)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "FindRevisions", ctx, postID)
+}
+
+func (suite *PostServiceTestSuite) TestGetPost_LocksAfterFailedPasswords() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{ID: postID, Visibility: entity.Public, HasPassword: true, Password: "hash"}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil)
+	suite.mocksPasswordHasher.On("CompareHashAndPassword", []byte("hash"), []byte("wrong")).Return(errors.New("error")).Times(3)
+
+	for i := 0; i < 3; i++ {
+		_, err := suite.postService.GetPost(ctx, postID, "", "wrong")
+		suite.Equal(typesystem.Unauthorized, err)
+	}
+
+	_, err := suite.postService.GetPost(ctx, postID, "", "right")
+
+	var tooMany typesystem.TooManyRequests
+	suite.Require().ErrorAs(err, &tooMany)
+	suite.Equal(60, tooMany.RetryAfterSeconds())
+
+	suite.mocksPasswordHasher.AssertNotCalled(suite.T(), "CompareHashAndPassword", []byte("hash"), []byte("right"))
+	suite.mocksPasswordHasher.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestGetPost_RehashesPassword() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{ID: postID, Visibility: entity.Public, HasPassword: true, Password: "old"}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+	suite.mocksPasswordHasher.On("CompareHashAndPassword", []byte("old"), []byte("123")).Return(nil).Once()
+	suite.mocksPasswordHasher.On("NeedsRehash", []byte("old")).Return(true).Once()
+	suite.mocksPasswordHasher.On("GenerateFromPassword", []byte("123")).Return([]byte("new"), nil).Once()
+	suite.mocksRepo.On("ReplacePasswordHash", ctx, postID, "old", "new").Return(nil).Once()
+
+	output, err := suite.postService.GetPost(ctx, postID, "", "123")
+
+	suite.NoError(err)
+	suite.Equal("new", output.Password)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+	suite.mocksPasswordHasher.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestGetPost_RehashFailureStillUnlocks() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{ID: postID, Visibility: entity.Public, HasPassword: true, Password: "old"}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+	suite.mocksPasswordHasher.On("CompareHashAndPassword", []byte("old"), []byte("123")).Return(nil).Once()
+	suite.mocksPasswordHasher.On("NeedsRehash", []byte("old")).Return(true).Once()
+	suite.mocksPasswordHasher.On("GenerateFromPassword", []byte("123")).Return([]byte("new"), nil).Once()
+	suite.mocksRepo.On("ReplacePasswordHash", ctx, postID, "old", "new").Return(errors.New("error")).Once()
+
+	output, err := suite.postService.GetPost(ctx, postID, "", "123")
+
+	suite.NoError(err)
+	suite.Equal(postID, output.ID)
+}
+
+func (suite *PostServiceTestSuite) TestGetRevisions_DeleteAfterViewNotOwner() {
+	ctx := context.TODO()
+
+	ownerID := uuid.New().String()
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{ID: postID, UserID: &ownerID, Visibility: entity.Public, DeleteAfterView: true}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+
+	output, err := suite.postService.GetRevisions(ctx, postID, "", "")
+
+	suite.Equal(typesystem.Forbidden, err)
+	suite.Nil(output)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Delete", ctx, postID)
+}
+
+func (suite *PostServiceTestSuite) TestGetRevision_Invalid() {
+	ctx := context.TODO()
+
+	output, err := suite.postService.GetRevision(ctx, "id", "0", "", "")
+
+	suite.Equal(services.ErrInvalidRevision, err)
+	suite.Nil(output)
+}
+
+func (suite *PostServiceTestSuite) TestGetRevision_NotFound() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{ID: postID, Visibility: entity.Public, Revision: 1}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+	suite.mocksRepo.On("FindRevision", ctx, postID, 3).Return(&entity.PostRevision{}, sql.ErrNoRows).Once()
+
+	output, err := suite.postService.GetRevision(ctx, postID, "3", "", "")
+
+	suite.Equal(typesystem.NotFound, err)
+	suite.Nil(output)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestDiffRevisions() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{ID: postID, Visibility: entity.Public, Revision: 2}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+	suite.mocksRepo.On("FindRevision", ctx, postID, 1).Return(&entity.PostRevision{Revision: 1, Content: "a\nb\n"}, nil).Once()
+	suite.mocksRepo.On("FindRevision", ctx, postID, 2).Return(&entity.PostRevision{Revision: 2, Content: "a\nc\n"}, nil).Once()
+
+	output, err := suite.postService.DiffRevisions(ctx, postID, "", "", "", "")
+
+	suite.NoError(err)
+	suite.Equal(1, output.From)
+	suite.Equal(2, output.To)
+	suite.Contains(output.Diff, "-b\n")
+	suite.Contains(output.Diff, "+c\n")
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestFork() {
+	ctx := context.TODO()
+
+	userID := uuid.New().String()
+	postID := utils.GenerateRandomString(8)
+
+	source := &entity.PostOutput{
+		ID:          postID,
+		Title:       "Title",
+		Content:     "Body",
+		Visibility:  entity.Public,
+		HasPassword: true,
+		Password:    "hash",
+	}
+
+	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(source, nil).Once()
+	suite.mocksPasswordHasher.On("CompareHashAndPassword", []byte("hash"), []byte("123")).Return(nil).Once()
+	suite.mocksPasswordHasher.On("NeedsRehash", []byte("hash")).Return(false).Once()
+	suite.mocksRepo.On("Insert", ctx, mock.MatchedBy(func(post *entity.PostInput) bool {
+		return *post.UserID == userID &&
+			*post.ForkedFrom == postID &&
+			post.Encryption == entity.EncryptedWithPassword &&
+			openSealed(post.DataKey, "123", post.Content) == "Body" &&
+			post.Password == "hash" &&
+			post.HasPassword
+	})).Return(nil).Once()
+
+	fork, err := suite.postService.Fork(ctx, postID, userID, "123", &entity.ForkPostInput{})
+
+	suite.NoError(err)
+	suite.Equal(postID, *fork.ForkedFrom)
+	suite.Equal("Title", fork.Title)
+	suite.Equal(entity.Public, fork.Visibility)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+	suite.mocksPasswordHasher.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestFork_Unauthenticated() {
+	ctx := context.TODO()
+
+	fork, err := suite.postService.Fork(ctx, "id", "", "", &entity.ForkPostInput{})
+
+	suite.Equal(typesystem.Unauthorized, err)
+	suite.Nil(fork)
+}
+
+func (suite *PostServiceTestSuite) TestFork_PrivateOfAnotherUser() {
+	ctx := context.TODO()
+
+	ownerID := uuid.New().String()
+	postID := utils.GenerateRandomString(8)
+
+	source := &entity.PostOutput{ID: postID, UserID: &ownerID, Visibility: entity.Private}
+
+	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(source, nil).Once()
+
+	fork, err := suite.postService.Fork(ctx, postID, uuid.New().String(), "", &entity.ForkPostInput{})
+
+	suite.Equal(typesystem.NotFound, err)
+	suite.Nil(fork)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Insert", ctx, mock.Anything)
+}
+
+func (suite *PostServiceTestSuite) TestFork_DeleteAfterView() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	source := &entity.PostOutput{ID: postID, Visibility: entity.Public, DeleteAfterView: true}
+
+	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(source, nil).Once()
+
+	fork, err := suite.postService.Fork(ctx, postID, uuid.New().String(), "", &entity.ForkPostInput{})
+
+	suite.Equal(services.ErrForkDeleteAfterView, err)
+	suite.Nil(fork)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Insert", ctx, mock.Anything)
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Delete", ctx, postID)
+}
+
+func (suite *PostServiceTestSuite) TestCreate_NormalizesLanguage() {
+	ctx := context.TODO()
+
+	userID := "22c15b0d-5445-4c84-a52a-40888798d1d0"
+
+	input := &entity.PostInput{
+		UserID:   &userID,
+		Title:    "Title",
+		Content:  "a: b",
+		Language: "yml",
+	}
+
+	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+	suite.mocksRepo.On("Insert", ctx, mock.MatchedBy(func(post *entity.PostInput) bool {
+		return post.Language == "yaml"
+	})).Return(nil).Once()
+
+	err := suite.postService.Create(ctx, input)
+
+	suite.NoError(err)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestCreate_DetectsLanguage() {
+	ctx := context.TODO()
+
+	userID := "22c15b0d-5445-4c84-a52a-40888798d1d0"
+
+	input := &entity.PostInput{
+		UserID:  &userID,
+		Title:   "main.go",
+		Content: "package main",
+	}
+
+	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+	suite.mocksRepo.On("Insert", ctx, mock.MatchedBy(func(post *entity.PostInput) bool {
+		return post.Language == "go"
+	})).Return(nil).Once()
+
+	err := suite.postService.Create(ctx, input)
+
+	suite.NoError(err)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestCreate_UnsupportedLanguage() {
+	ctx := context.TODO()
+
+	userID := "22c15b0d-5445-4c84-a52a-40888798d1d0"
+
+	input := &entity.PostInput{
+		UserID:   &userID,
+		Title:    "Title",
+		Content:  "Body",
+		Language: "not-a-language",
+	}
+
+	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+
+	err := suite.postService.Create(ctx, input)
+
+	suite.Equal(services.ErrUnsupportedLanguage, err)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Insert", ctx, mock.Anything)
+}
+
+func (suite *PostServiceTestSuite) TestGetAllPublics_LanguageFilter() {
+	ctx := context.TODO()
+
+	output := []*entity.PostOutput{{ID: utils.GenerateRandomString(8), Language: "go"}}
+
+	suite.mocksRepo.On("FindAllPublics", ctx, 1, "go").Return(output, 11, nil).Once()
+
+	posts, paginationInfo, err := suite.postService.GetAllPublics(ctx, "1", "Go")
+
+	suite.NoError(err)
+	suite.Equal(output, posts)
+	suite.Equal("/post/all?language=go&page=2", *paginationInfo.Next)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestRenderPost() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{ID: postID, Visibility: entity.Public, Content: "<script>", Language: "go"}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+
+	rendered, err := suite.postService.RenderPost(ctx, postID, "", "")
+
+	suite.NoError(err)
+	suite.Contains(rendered, "&lt;script&gt;")
+	suite.NotContains(rendered, "<script>")
+}
+
+func (suite *PostServiceTestSuite) TestGetPost_DeleteAfterView() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{ID: postID, Visibility: entity.Public, DeleteAfterView: true}
+	burned := &entity.PostOutput{ID: postID, Visibility: entity.Public, DeleteAfterView: true, Content: "secret"}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+	suite.mocksRepo.On("Burn", ctx, postID).Return(burned, nil).Once()
+
+	output, err := suite.postService.GetPost(ctx, postID, "", "")
+
+	suite.NoError(err)
+	suite.Equal("secret", output.Content)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Delete", ctx, postID)
+}
+
+func (suite *PostServiceTestSuite) TestGetPost_DeleteAfterViewWrongPasswordDoesNotBurn() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{ID: postID, Visibility: entity.Public, DeleteAfterView: true, HasPassword: true, Password: "hash"}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+	suite.mocksPasswordHasher.On("CompareHashAndPassword", []byte("hash"), []byte("wrong")).Return(errors.New("error")).Once()
+
+	output, err := suite.postService.GetPost(ctx, postID, "", "wrong")
+
+	suite.Equal(typesystem.Unauthorized, err)
+	suite.Nil(output)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Burn", ctx, postID)
+}
+
+func (suite *PostServiceTestSuite) TestGetPost_DeleteAfterViewPrivateNotOwnerDoesNotBurn() {
+	ctx := context.TODO()
+
+	ownerID := uuid.New().String()
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{ID: postID, UserID: &ownerID, Visibility: entity.Private, DeleteAfterView: true}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+
+	output, err := suite.postService.GetPost(ctx, postID, uuid.New().String(), "")
+
+	suite.Equal(typesystem.NotFound, err)
+	suite.Nil(output)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Burn", ctx, postID)
+}
+
+func (suite *PostServiceTestSuite) TestGetPost_DeleteAfterViewConcurrentReaders() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+	readers := 50
+
+	post := &entity.PostOutput{ID: postID, Visibility: entity.Public, DeleteAfterView: true}
+	burned := &entity.PostOutput{ID: postID, Visibility: entity.Public, DeleteAfterView: true, Content: "secret"}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil)
+	suite.mocksRepo.On("Burn", ctx, postID).Return(burned, nil).Once()
+	suite.mocksRepo.On("Burn", ctx, postID).Return(&entity.PostOutput{}, sql.ErrNoRows)
+
+	var wg sync.WaitGroup
+	var delivered, notFound atomic.Int32
+
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			output, err := suite.postService.GetPost(ctx, postID, "", "")
+			if err == nil && output.Content == "secret" {
+				delivered.Add(1)
+			} else if err == typesystem.NotFound {
+				notFound.Add(1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	suite.Equal(int32(1), delivered.Load())
+	suite.Equal(int32(readers-1), notFound.Load())
+}
+
+func (suite *PostServiceTestSuite) TestCreate_WithFiles() {
+	ctx := context.TODO()
+
+	userID := "22c15b0d-5445-4c84-a52a-40888798d1d0"
+
+	input := &entity.PostInput{
+		UserID: &userID,
+		Title:  "Title",
+		Files: []entity.PostFile{
+			{Filename: "Dockerfile", Content: "FROM golang"},
+			{Filename: "config.yml", Content: "a: b"},
+		},
+	}
+
+	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+	suite.mocksRepo.On("Insert", ctx, mock.MatchedBy(func(post *entity.PostInput) bool {
+		return len(post.Files) == 2 &&
+			post.Files[0].Language == "docker" &&
+			post.Files[1].Language == "yaml"
+	})).Return(nil).Once()
+
+	err := suite.postService.Create(ctx, input)
+
+	suite.NoError(err)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestCreate_WithInvalidFiles() {
+	ctx := context.TODO()
+
+	userID := "22c15b0d-5445-4c84-a52a-40888798d1d0"
+
+	tests := []struct {
+		files []entity.PostFile
+		err   error
+	}{
+		{[]entity.PostFile{{Filename: "../etc/passwd", Content: "x"}}, services.ErrInvalidFilename},
+		{[]entity.PostFile{{Filename: "..", Content: "x"}}, services.ErrInvalidFilename},
+		{[]entity.PostFile{{Filename: "a.go", Content: "x"}, {Filename: "a.go", Content: "y"}}, services.ErrDuplicateFilename},
+		{make([]entity.PostFile, services.MaxPostFiles+1), services.ErrTooManyFiles},
+	}
+
+	for _, tt := range tests {
+		input := &entity.PostInput{UserID: &userID, Title: "Title", Files: tt.files}
+
+		suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+
+		err := suite.postService.Create(ctx, input)
+
+		suite.Equal(tt.err, err)
+	}
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Insert", ctx, mock.Anything)
+}
+
+func (suite *PostServiceTestSuite) TestGetPostFile() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{
+		ID:         postID,
+		Visibility: entity.Public,
+		Files: []entity.PostFile{
+			{Filename: "a.sh", Content: "echo a"},
+			{Filename: "b.sh", Content: "echo b"},
+		},
+	}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Twice()
+
+	file, err := suite.postService.GetPostFile(ctx, postID, "b.sh", "", "")
+
+	suite.NoError(err)
+	suite.Equal("echo b", file.Content)
+
+	file, err = suite.postService.GetPostFile(ctx, postID, "c.sh", "", "")
+
+	suite.Equal(typesystem.NotFound, err)
+	suite.Nil(file)
+}
+
+func (suite *PostServiceTestSuite) TestGetPostFile_MissingFileKeepsBurnAfterReadPost() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{
+		ID:              postID,
+		Visibility:      entity.Public,
+		DeleteAfterView: true,
+		Files:           []entity.PostFile{{Filename: "a.sh", Content: "echo a"}},
+	}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Twice()
+	suite.mocksRepo.On("Burn", ctx, postID).Return(&entity.PostOutput{ID: postID, Visibility: entity.Public}, nil).Once()
+
+	file, err := suite.postService.GetPostFile(ctx, postID, "typo.sh", "", "")
+
+	suite.Equal(typesystem.NotFound, err)
+	suite.Nil(file)
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Burn", ctx, postID)
+
+	// The post is still there for the right name
+	file, err = suite.postService.GetPostFile(ctx, postID, "a.sh", "", "")
+
+	suite.NoError(err)
+	suite.Equal("echo a", file.Content)
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestArchivePost_Zip() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{
+		ID:         postID,
+		Visibility: entity.Public,
+		Files: []entity.PostFile{
+			{Filename: "a.sh", Content: "echo a"},
+			{Filename: "b.sh", Content: "echo b"},
+		},
+	}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+
+	archive, err := suite.postService.ArchivePost(ctx, postID, "", "", "")
+
+	suite.NoError(err)
+	suite.Equal(postID+".zip", archive.Filename)
+	suite.Equal("application/zip", archive.ContentType)
+
+	reader, err := zip.NewReader(bytes.NewReader(archive.Data), int64(len(archive.Data)))
+	suite.NoError(err)
+	suite.Len(reader.File, 2)
+	suite.Equal("a.sh", reader.File[0].Name)
+	suite.Equal("b.sh", reader.File[1].Name)
+}
+
+func (suite *PostServiceTestSuite) TestArchivePost_Tar() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{ID: postID, Visibility: entity.Public, Content: "Body"}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+
+	archive, err := suite.postService.ArchivePost(ctx, postID, "tar", "", "")
+
+	suite.NoError(err)
+	suite.Equal("application/x-tar", archive.ContentType)
+
+	reader := tar.NewReader(bytes.NewReader(archive.Data))
+
+	header, err := reader.Next()
+	suite.NoError(err)
+	suite.Equal(postID+".txt", header.Name)
+
+	content, err := io.ReadAll(reader)
+	suite.NoError(err)
+	suite.Equal("Body", string(content))
+}
+
+func (suite *PostServiceTestSuite) TestArchivePost_UnsupportedFormat() {
+	ctx := context.TODO()
+
+	archive, err := suite.postService.ArchivePost(ctx, "id", "rar", "", "")
+
+	suite.Equal(services.ErrUnsupportedArchiveFormat, err)
+	suite.Nil(archive)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "FindOneByID", ctx, "id")
+}
+
+// openSealed decrypts content sealed for a password-protected post
+func openSealed(wrapped []byte, password string, content string) string {
+	dataKey, err := contentcrypto.UnwrapWithPassword(wrapped, []byte(password))
+	if err != nil {
+		return ""
+	}
+
+	data, err := contentcrypto.Open(dataKey, []byte(content))
+	if err != nil {
+		return ""
+	}
+
+	return string(data)
+}
+
+func (suite *PostServiceTestSuite) TestCreate_SealsPasswordProtectedPost() {
+	ctx := context.TODO()
+
+	userID := uuid.New().String()
+
+	input := &entity.PostInput{
+		UserID:      &userID,
+		Title:       "Title",
+		Content:     "Body",
+		Files:       []entity.PostFile{{Filename: "main.go", Content: "package main"}},
+		Password:    "12345678",
+		HasPassword: true,
+		Visibility:  entity.Public,
+	}
+
+	var stored *entity.PostInput
+
+	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+	suite.mocksPasswordHasher.On("GenerateFromPassword", []byte("12345678")).Return([]byte("hash"), nil).Once()
+	suite.mocksRepo.On("Insert", ctx, mock.AnythingOfType("*entity.PostInput")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*entity.PostInput)
+	}).Return(nil).Once()
+
+	err := suite.postService.Create(ctx, input)
+
+	suite.NoError(err)
+	suite.Equal(entity.EncryptedWithPassword, stored.Encryption)
+	suite.NotContains(stored.Content, "Body")
+	suite.NotContains(stored.Files[0].Content, "package")
+
+	// The master key alone does not open it
+	_, err = contentcrypto.UnwrapWithKey(stored.DataKey, suite.keys.MasterKey)
+	suite.Error(err)
+
+	post := &entity.PostOutput{
+		ID:          stored.ID,
+		Title:       stored.Title,
+		Content:     stored.Content,
+		Files:       stored.Files,
+		Visibility:  entity.Public,
+		HasPassword: true,
+		Password:    "hash",
+		Encryption:  stored.Encryption,
+		DataKey:     stored.DataKey,
+	}
+
+	suite.mocksRepo.On("FindOneByID", ctx, stored.ID).Return(post, nil).Once()
+	suite.mocksPasswordHasher.On("CompareHashAndPassword", []byte("hash"), []byte("12345678")).Return(nil).Once()
+	suite.mocksPasswordHasher.On("NeedsRehash", []byte("hash")).Return(false).Once()
+
+	output, err := suite.postService.GetPost(ctx, stored.ID, "", "12345678")
+
+	suite.NoError(err)
+	suite.Equal("Body", output.Content)
+	suite.Equal("package main", output.Files[0].Content)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+	suite.mocksPasswordHasher.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestCreate_SealsPrivatePostWithMasterKey() {
+	ctx := context.TODO()
+
+	userID := uuid.New().String()
+
+	input := &entity.PostInput{UserID: &userID, Title: "Title", Content: "Body", Visibility: entity.Private}
+
+	var stored *entity.PostInput
+
+	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+	suite.mocksRepo.On("Insert", ctx, mock.AnythingOfType("*entity.PostInput")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*entity.PostInput)
+	}).Return(nil).Once()
+
+	err := suite.postService.Create(ctx, input)
+
+	suite.NoError(err)
+	suite.Equal(entity.EncryptedWithMasterKey, stored.Encryption)
+	suite.NotEqual("Body", stored.Content)
+
+	post := &entity.PostOutput{
+		ID:         stored.ID,
+		UserID:     &userID,
+		Content:    stored.Content,
+		Visibility: entity.Private,
+		Encryption: stored.Encryption,
+		DataKey:    stored.DataKey,
+	}
+
+	suite.mocksRepo.On("FindOneByID", ctx, stored.ID).Return(post, nil).Once()
+	suite.mocksRepo.On("FindRevision", ctx, stored.ID, 1).Return(&entity.PostRevision{PostID: stored.ID, Revision: 1, Content: stored.Content}, nil).Once()
+
+	revision, err := suite.postService.GetRevision(ctx, stored.ID, "1", userID, "")
+
+	suite.NoError(err)
+	suite.Equal("Body", revision.Content)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestCreate_PublicPostNotSealed() {
+	ctx := context.TODO()
+
+	userID := uuid.New().String()
+
+	input := &entity.PostInput{UserID: &userID, Title: "Title", Content: "Body", Visibility: entity.Public}
+
+	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+	suite.mocksRepo.On("Insert", ctx, mock.MatchedBy(func(post *entity.PostInput) bool {
+		return post.Encryption == entity.Unencrypted && post.DataKey == nil && post.Content == "Body"
+	})).Return(nil).Once()
+
+	err := suite.postService.Create(ctx, input)
+
+	suite.NoError(err)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestCreate_PrivatePostWithoutMasterKeyNotSealed() {
+	ctx := context.TODO()
+
+	postService := services.NewPostService(suite.mocksRepo, suite.validation, suite.mocksPasswordHasher, suite.lockout, entity.ContentLimits{}, contentcrypto.Keys{})
+
+	userID := uuid.New().String()
+
+	input := &entity.PostInput{UserID: &userID, Title: "Title", Content: "Body", Visibility: entity.Private}
+
+	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+	suite.mocksRepo.On("Insert", ctx, mock.MatchedBy(func(post *entity.PostInput) bool {
+		return post.Encryption == entity.Unencrypted && post.Content == "Body"
+	})).Return(nil).Once()
+
+	err := postService.Create(ctx, input)
+
+	suite.NoError(err)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestUpdatePost_SealedNeedsPassword() {
+	ctx := context.TODO()
+
+	userID := uuid.New()
+	postID := utils.GenerateRandomString(8)
+	owner := userID.String()
+
+	dataKey, err := contentcrypto.NewKey()
+	suite.NoError(err)
+
+	wrapped, err := contentcrypto.WrapWithPassword(dataKey, []byte("12345678"), suite.keys.Password)
+	suite.NoError(err)
+
+	post := &entity.PostOutput{
+		ID:          postID,
+		UserID:      &owner,
+		HasPassword: true,
+		Password:    "hash",
+		Encryption:  entity.EncryptedWithPassword,
+		DataKey:     wrapped,
+	}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Twice()
+	suite.mocksPasswordHasher.On("CompareHashAndPassword", []byte("hash"), []byte("")).Return(errors.New("error")).Once()
+	suite.mocksPasswordHasher.On("CompareHashAndPassword", []byte("hash"), []byte("12345678")).Return(nil).Once()
+	suite.mocksPasswordHasher.On("NeedsRehash", []byte("hash")).Return(false).Once()
+
+	err = suite.postService.UpdatePost(ctx, &entity.PostUpdateInput{Content: "New body"}, userID, postID, "")
+
+	suite.Equal(typesystem.Unauthorized, err)
+
+	suite.mocksRepo.On("Update", ctx, mock.MatchedBy(func(update *entity.PostUpdateInput) bool {
+		return update.Encryption == entity.EncryptedWithPassword &&
+			openSealed(wrapped, "12345678", update.Content) == "New body"
+	})).Return(nil).Once()
+
+	err = suite.postService.UpdatePost(ctx, &entity.PostUpdateInput{Content: "New body", Password: "12345678"}, userID, postID, "")
+
+	suite.NoError(err)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+	suite.mocksPasswordHasher.AssertExpectations(suite.T())
+}
+
+// testEnvelope returns a client-side encrypted body
+func testEnvelope(plaintext string) string {
+	key, _ := pastecrypt.NewKey()
+	envelope, _ := pastecrypt.Encrypt([]byte(plaintext), key, nil)
+	data, _ := envelope.Marshal()
+
+	return string(data)
+}
+
+func (suite *PostServiceTestSuite) TestCreate_Encrypted() {
+	ctx := context.TODO()
+
+	// Envelopes are larger than the suite's content limits
+	postService := services.NewPostService(suite.mocksRepo, suite.validation, suite.mocksPasswordHasher, suite.lockout, entity.ContentLimits{}, suite.keys)
+
+	userID := uuid.New().String()
+	envelope := testEnvelope("secret")
+
+	input := &entity.PostInput{UserID: &userID, Title: "Title", Content: envelope, Visibility: entity.Unlisted, Encrypted: true}
+
+	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+	suite.mocksRepo.On("Insert", ctx, mock.MatchedBy(func(post *entity.PostInput) bool {
+		return post.Encrypted &&
+			post.Content == envelope &&
+			post.Language == highlight.PlainText &&
+			post.Encryption == entity.Unencrypted
+	})).Return(nil).Once()
+
+	err := postService.Create(ctx, input)
+
+	suite.NoError(err)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestCreate_EncryptedInvalidEnvelope() {
+	ctx := context.TODO()
+
+	// Envelopes are larger than the suite's content limits
+	postService := services.NewPostService(suite.mocksRepo, suite.validation, suite.mocksPasswordHasher, suite.lockout, entity.ContentLimits{}, suite.keys)
+
+	userID := uuid.New().String()
+
+	inputs := []*entity.PostInput{
+		{UserID: &userID, Title: "Title", Content: "secret", Visibility: entity.Public, Encrypted: true},
+		{UserID: &userID, Title: "Title", Content: testEnvelope("secret"), Files: []entity.PostFile{{Filename: "a.txt", Content: "secret"}}, Visibility: entity.Public, Encrypted: true},
+	}
+
+	for _, input := range inputs {
+		suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+
+		err := postService.Create(ctx, input)
+
+		suite.Equal(services.ErrInvalidEnvelope, err)
+	}
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Insert", mock.Anything, mock.Anything)
+}
+
+func (suite *PostServiceTestSuite) TestGetPost_EncryptedReturnedVerbatim() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+	envelope := testEnvelope("secret")
+
+	post := &entity.PostOutput{ID: postID, Content: envelope, Visibility: entity.Public, Encrypted: true}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Twice()
+
+	output, err := suite.postService.GetPost(ctx, postID, "", "")
+
+	suite.NoError(err)
+	suite.Equal(envelope, output.Content)
+	suite.True(output.Encrypted)
+
+	_, err = suite.postService.RenderPost(ctx, postID, "", "")
+
+	suite.Equal(services.ErrRenderEncrypted, err)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestRenderPost_EncryptedKeepsBurnAfterReadPost() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+
+	post := &entity.PostOutput{ID: postID, Content: testEnvelope("secret"), Visibility: entity.Public, Encrypted: true, DeleteAfterView: true}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+
+	_, err := suite.postService.RenderPost(ctx, postID, "", "")
+
+	suite.Equal(services.ErrRenderEncrypted, err)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Burn", ctx, postID)
+}
+
+func (suite *PostServiceTestSuite) TestCreate_AnonymousGetsManagementToken() {
+	ctx := context.TODO()
+
+	userID := ""
+
+	input := &entity.PostInput{UserID: &userID, Title: "Title", Content: "Body", Visibility: entity.Public}
+
+	var stored *entity.PostInput
+
+	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+	suite.mocksRepo.On("Insert", ctx, mock.AnythingOfType("*entity.PostInput")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*entity.PostInput)
+	}).Return(nil).Once()
+
+	err := suite.postService.Create(ctx, input)
+
+	suite.NoError(err)
+	suite.Nil(stored.UserID)
+	suite.Equal(stored.ID, input.ID)
+	suite.True(strings.HasPrefix(input.ManagementToken, entity.ManagementTokenPrefix))
+	suite.Equal(entity.HashToken(input.ManagementToken), stored.ManagementTokenHash)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestCreate_UserGetsNoManagementToken() {
+	ctx := context.TODO()
+
+	userID := uuid.New().String()
+
+	input := &entity.PostInput{UserID: &userID, Title: "Title", Content: "Body", Visibility: entity.Public}
+
+	suite.validation.On("Validate", mock.Anything).Return(nil).Once()
+	suite.mocksRepo.On("Insert", ctx, mock.MatchedBy(func(post *entity.PostInput) bool {
+		return post.ManagementTokenHash == ""
+	})).Return(nil).Once()
+
+	err := suite.postService.Create(ctx, input)
+
+	suite.NoError(err)
+	suite.NotEmpty(input.ID)
+	suite.Empty(input.ManagementToken)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestDeletePost_ManagementToken() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+	token, hash, err := entity.NewManagementToken()
+	suite.NoError(err)
+
+	post := &entity.PostOutput{ID: postID, ManagementTokenHash: hash}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Times(4)
+
+	// Anonymous posts are no longer a nil dereference without a token
+	err = suite.postService.DeletePost(ctx, postID, uuid.New(), "")
+	suite.Equal(typesystem.Forbidden, err)
+
+	err = suite.postService.DeletePost(ctx, postID, uuid.Nil, "")
+	suite.Equal(typesystem.Forbidden, err)
+
+	err = suite.postService.DeletePost(ctx, postID, uuid.Nil, entity.ManagementTokenPrefix+"wrong")
+	suite.Equal(typesystem.Forbidden, err)
+
+	suite.mocksRepo.On("Delete", ctx, postID).Return(nil).Once()
+
+	err = suite.postService.DeletePost(ctx, postID, uuid.Nil, token)
+	suite.NoError(err)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestDeletePost_ManagementTokenDoesNotManageOwnedPost() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+	owner := uuid.New().String()
+	token, hash, err := entity.NewManagementToken()
+	suite.NoError(err)
+
+	post := &entity.PostOutput{ID: postID, UserID: &owner, ManagementTokenHash: hash}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Once()
+
+	err = suite.postService.DeletePost(ctx, postID, uuid.Nil, token)
+	suite.Equal(typesystem.Forbidden, err)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "Delete", ctx, postID)
+}
+
+func (suite *PostServiceTestSuite) TestUpdatePost_ManagementToken() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+	token, hash, err := entity.NewManagementToken()
+	suite.NoError(err)
+
+	post := &entity.PostOutput{ID: postID, ManagementTokenHash: hash}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Twice()
+
+	err = suite.postService.UpdatePost(ctx, &entity.PostUpdateInput{Title: "New"}, uuid.Nil, postID, "")
+	suite.Equal(typesystem.Forbidden, err)
+
+	suite.mocksRepo.On("Update", ctx, mock.MatchedBy(func(update *entity.PostUpdateInput) bool {
+		return update.ID == postID && update.Title == "New"
+	})).Return(nil).Once()
+
+	err = suite.postService.UpdatePost(ctx, &entity.PostUpdateInput{Title: "New"}, uuid.Nil, postID, token)
+	suite.NoError(err)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestClaimPost() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+	userID := uuid.New()
+	token, hash, err := entity.NewManagementToken()
+	suite.NoError(err)
+
+	post := &entity.PostOutput{ID: postID, ManagementTokenHash: hash}
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(post, nil).Twice()
+
+	err = suite.postService.ClaimPost(ctx, postID, userID, "")
+	suite.Equal(typesystem.Forbidden, err)
+
+	suite.mocksRepo.On("Claim", ctx, postID, userID, hash).Return(nil).Once()
+
+	err = suite.postService.ClaimPost(ctx, postID, userID, token)
+	suite.NoError(err)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PostServiceTestSuite) TestClaimPost_AlreadyClaimed() {
+	ctx := context.TODO()
+
+	postID := utils.GenerateRandomString(8)
+	userID := uuid.New()
+	owner := uuid.New().String()
+	token, hash, err := entity.NewManagementToken()
+	suite.NoError(err)
+
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(&entity.PostOutput{ID: postID, UserID: &owner}, nil).Once()
+
+	err = suite.postService.ClaimPost(ctx, postID, userID, token)
+	suite.Equal(services.ErrAlreadyClaimed, err)
+
+	// Claimed by a concurrent request between the read and the update
+	suite.mocksRepo.On("FindOneByID", ctx, postID).Return(&entity.PostOutput{ID: postID, ManagementTokenHash: hash}, nil).Once()
+	suite.mocksRepo.On("Claim", ctx, postID, userID, hash).Return(sql.ErrNoRows).Once()
+
+	err = suite.postService.ClaimPost(ctx, postID, userID, token)
+	suite.Equal(services.ErrAlreadyClaimed, err)
+
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
@@ -0,0 +1,239 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/config"
+	"github.com/Caixetadev/snippet/internal/app"
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/infra/memory"
+	"github.com/Caixetadev/snippet/internal/middleware"
+	"github.com/Caixetadev/snippet/internal/services"
+	httpmiddleware "github.com/Caixetadev/snippet/pkg/middleware/http"
+	"github.com/Caixetadev/snippet/pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+)
+
+type RateLimitServiceTestSuite struct {
+	suite.Suite
+	store   *memory.RateLimitStore
+	limiter *services.RateLimitService
+}
+
+func (suite *RateLimitServiceTestSuite) SetupTest() {
+	suite.store = memory.NewRateLimitStore()
+	suite.limiter = services.NewRateLimitService(suite.store, map[string]entity.RateLimitPolicy{
+		entity.RateLimitPostCreate: {
+			entity.RateLimitAnonymous: {Burst: 2, Period: time.Hour},
+			entity.RateLimitUser:      {Burst: 5, Period: time.Hour},
+		},
+	})
+}
+
+func TestRateLimitServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(RateLimitServiceTestSuite))
+}
+
+func (suite *RateLimitServiceTestSuite) TestParseLimit() {
+	limit, err := ratelimit.ParseLimit("100/1h")
+
+	suite.NoError(err)
+	suite.Equal(ratelimit.Limit{Burst: 100, Period: time.Hour}, limit)
+
+	for _, s := range []string{"100", "0/1h", "10/0s", "x/1h", "10/day"} {
+		_, err := ratelimit.ParseLimit(s)
+		suite.Error(err, s)
+	}
+}
+
+func (suite *RateLimitServiceTestSuite) TestTake_Refills() {
+	limit := ratelimit.Limit{Burst: 4, Period: 4 * time.Minute}
+	now := time.Now()
+
+	var bucket ratelimit.Bucket
+	var result ratelimit.Result
+
+	for i := 3; i >= 0; i-- {
+		bucket, result = limit.Take(bucket, now)
+		suite.True(result.Allowed)
+		suite.Equal(i, result.Remaining)
+	}
+
+	bucket, result = limit.Take(bucket, now)
+	suite.False(result.Allowed)
+	suite.Equal(time.Minute, result.RetryAfter)
+	suite.Equal(4*time.Minute, result.Reset)
+
+	// One token comes back every minute
+	bucket, result = limit.Take(bucket, now.Add(time.Minute))
+	suite.True(result.Allowed)
+	suite.Equal(0, result.Remaining)
+
+	// And the bucket never holds more than Burst
+	_, result = limit.Take(bucket, now.Add(time.Hour))
+	suite.True(result.Allowed)
+	suite.Equal(3, result.Remaining)
+}
+
+func (suite *RateLimitServiceTestSuite) TestRateLimitPolicies() {
+	policies, err := services.RateLimitPolicies(&config.Config{
+		RateLimits: []string{"post_create.anonymous=10/1h", " search.token=60/1m "},
+	})
+
+	suite.NoError(err)
+	suite.Equal(ratelimit.Limit{Burst: 10, Period: time.Hour}, policies["post_create"]["anonymous"])
+	suite.Equal(ratelimit.Limit{Burst: 60, Period: time.Minute}, policies["search"]["token"])
+
+	for _, entry := range []string{"post_create=10/1h", "post_create.robot=10/1h", "search.user"} {
+		_, err := services.RateLimitPolicies(&config.Config{RateLimits: []string{entry}})
+		suite.Error(err, entry)
+	}
+}
+
+func (suite *RateLimitServiceTestSuite) TestTake_PerIdentity() {
+	ctx := context.TODO()
+	anonymous := entity.RateLimitIdentity{Kind: entity.RateLimitAnonymous, Value: "203.0.113.7"}
+	other := entity.RateLimitIdentity{Kind: entity.RateLimitAnonymous, Value: "203.0.113.8"}
+
+	for i := 0; i < 2; i++ {
+		result, err := suite.limiter.Take(ctx, entity.RateLimitPostCreate, anonymous)
+		suite.NoError(err)
+		suite.True(result.Allowed)
+	}
+
+	result, _ := suite.limiter.Take(ctx, entity.RateLimitPostCreate, anonymous)
+	suite.False(result.Allowed)
+
+	result, _ = suite.limiter.Take(ctx, entity.RateLimitPostCreate, other)
+	suite.True(result.Allowed)
+
+	result, _ = suite.limiter.Take(ctx, entity.RateLimitPostCreate, entity.RateLimitIdentity{Kind: entity.RateLimitUser, Value: "u"})
+	suite.Equal(5, result.Limit)
+}
+
+func (suite *RateLimitServiceTestSuite) TestTake_Unlimited() {
+	ctx := context.TODO()
+
+	result, err := suite.limiter.Take(ctx, entity.RateLimitPostCreate, entity.RateLimitIdentity{Kind: entity.RateLimitToken, Value: "t"})
+	suite.NoError(err)
+	suite.Nil(result)
+
+	result, err = suite.limiter.Take(ctx, entity.RateLimitSearch, entity.RateLimitIdentity{Kind: entity.RateLimitAnonymous, Value: "ip"})
+	suite.NoError(err)
+	suite.Nil(result)
+}
+
+func (suite *RateLimitServiceTestSuite) TestPruneBuckets() {
+	ctx := context.TODO()
+	limit := ratelimit.Limit{Burst: 1, Period: time.Hour}
+	now := time.Now()
+
+	suite.store.TakeToken(ctx, "old", limit, now.Add(-2*time.Hour))
+	suite.store.TakeToken(ctx, "recent", limit, now)
+
+	removed, err := suite.store.PruneBuckets(ctx, now.Add(-time.Hour))
+
+	suite.NoError(err)
+	suite.Equal(1, removed)
+
+	result, _ := suite.store.TakeToken(ctx, "recent", limit, now)
+	suite.False(result.Allowed)
+}
+
+func (suite *RateLimitServiceTestSuite) TestMiddleware_Headers() {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(httpmiddleware.ErrorHandler())
+	router.POST("/post/create", middleware.RateLimit(suite.limiter, entity.RateLimitPostCreate), func(ctx *gin.Context) {
+		ctx.Status(http.StatusCreated)
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/post/create", nil))
+		return recorder
+	}
+
+	recorder := send()
+	suite.Equal(http.StatusCreated, recorder.Code)
+	suite.Equal("2", recorder.Header().Get("X-RateLimit-Limit"))
+	suite.Equal("1", recorder.Header().Get("X-RateLimit-Remaining"))
+	suite.Equal("1800", recorder.Header().Get("X-RateLimit-Reset"))
+
+	send()
+
+	// Result has the headers as they were written, Header() would also show later changes
+	recorder = send()
+	suite.Equal(http.StatusTooManyRequests, recorder.Code)
+	suite.Equal("0", recorder.Result().Header.Get("X-RateLimit-Remaining"))
+	suite.Equal("1800", recorder.Result().Header.Get("Retry-After"))
+	suite.Contains(recorder.Body.String(), "Rate limit exceeded")
+}
+
+func (suite *RateLimitServiceTestSuite) TestMiddleware_Identity() {
+	gin.SetMode(gin.TestMode)
+
+	identify := func(values map[string]any) entity.RateLimitIdentity {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		ctx.Request.RemoteAddr = "203.0.113.7:1234"
+
+		for key, value := range values {
+			ctx.Set(key, value)
+		}
+
+		return middleware.RateLimitIdentity(ctx)
+	}
+
+	suite.Equal(entity.RateLimitIdentity{Kind: entity.RateLimitAnonymous, Value: "203.0.113.7"}, identify(map[string]any{"x-user-id": nil}))
+	suite.Equal(entity.RateLimitIdentity{Kind: entity.RateLimitUser, Value: "u"}, identify(map[string]any{"x-user-id": "u"}))
+	suite.Equal(entity.RateLimitIdentity{Kind: entity.RateLimitToken, Value: "t"}, identify(map[string]any{"x-user-id": "u", "x-token-id": "t"}))
+}
+
+func (suite *RateLimitServiceTestSuite) TestMiddleware_ForwardedFor() {
+	gin.SetMode(gin.TestMode)
+
+	// httptest requests come from 192.0.2.1
+	send := func(router *gin.Engine, forwardedFor string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/post/create", nil)
+		request.Header.Set("X-Forwarded-For", forwardedFor)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	newRouter := func(cfg *config.Config) *gin.Engine {
+		router, err := app.NewRouter(cfg)
+		suite.Require().NoError(err)
+
+		router.Use(httpmiddleware.ErrorHandler())
+		router.POST("/post/create", middleware.RateLimit(suite.limiter, entity.RateLimitPostCreate), func(ctx *gin.Context) {
+			ctx.Status(http.StatusCreated)
+		})
+
+		return router
+	}
+
+	// Without trusted proxies a forged header does not get the caller a fresh bucket
+	router := newRouter(&config.Config{})
+
+	suite.Equal(http.StatusCreated, send(router, "198.51.100.1").Code)
+	suite.Equal(http.StatusCreated, send(router, "198.51.100.2").Code)
+	suite.Equal(http.StatusTooManyRequests, send(router, "198.51.100.3").Code)
+
+	// Behind a trusted proxy the forwarded address identifies the caller
+	router = newRouter(&config.Config{TrustedProxies: []string{"192.0.2.1"}})
+
+	suite.Equal(http.StatusCreated, send(router, "198.51.100.4").Code)
+	suite.Equal(http.StatusCreated, send(router, "198.51.100.5").Code)
+	suite.Equal(http.StatusCreated, send(router, "198.51.100.5").Code)
+	suite.Equal(http.StatusTooManyRequests, send(router, "198.51.100.5").Code)
+}
@@ -0,0 +1,293 @@
+package unit
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/Caixetadev/snippet/internal/tests/mocks"
+	"github.com/Caixetadev/snippet/internal/token"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/suite"
+)
+
+// sessionStore keeps sessions in memory so that a full sign-in, rotate and replay
+// sequence can run against the real UserService
+type sessionStore struct {
+	*mocks.UserRepository
+	mu       sync.Mutex
+	sessions map[uuid.UUID]*entity.Session
+	events   []*entity.SecurityEvent
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{
+		UserRepository: new(mocks.UserRepository),
+		sessions:       map[uuid.UUID]*entity.Session{},
+	}
+}
+
+func (s *sessionStore) CreateSession(ctx context.Context, session *entity.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *session
+	s.sessions[session.ID] = &stored
+	return nil
+}
+
+func (s *sessionStore) GetSession(ctx context.Context, id uuid.UUID) (*entity.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+
+	found := *session
+	return &found, nil
+}
+
+func (s *sessionStore) RotateSession(ctx context.Context, oldID uuid.UUID, session *entity.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.sessions[oldID]
+	if !ok || old.IsRevoked {
+		return pgx.ErrNoRows
+	}
+
+	old.IsRevoked = true
+	old.RevokedFor = entity.SessionRotated
+	stored := *session
+	s.sessions[session.ID] = &stored
+	return nil
+}
+
+func (s *sessionStore) RevokeSessionFamily(ctx context.Context, familyID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.FamilyID == familyID && !session.IsRevoked {
+			session.IsRevoked = true
+			session.RevokedFor = entity.SessionReused
+		}
+	}
+	return nil
+}
+
+func (s *sessionStore) RevokeSession(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || session.UserID != userID || session.IsRevoked {
+		return pgx.ErrNoRows
+	}
+
+	session.IsRevoked = true
+	session.RevokedFor = entity.SessionSignedOut
+	return nil
+}
+
+func (s *sessionStore) InsertSecurityEvent(ctx context.Context, event *entity.SecurityEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+	return nil
+}
+
+type RefreshTokenReuseTestSuite struct {
+	suite.Suite
+	store       *sessionStore
+	tokenMaker  token.Maker
+	userService *services.UserService
+	user        *entity.User
+}
+
+func (suite *RefreshTokenReuseTestSuite) SetupTest() {
+	tokenMaker, err := token.NewPasetoMaker("12345678901234567890123456789012")
+	suite.Require().NoError(err)
+
+	suite.store = newSessionStore()
+	suite.tokenMaker = tokenMaker
+	suite.userService = services.NewUserService(suite.store, new(mocks.Validator), new(mocks.PasswordHasher), tokenMaker)
+	suite.user = &entity.User{ID: uuid.New(), Name: "John"}
+}
+
+func TestRefreshTokenReuseSuite(t *testing.T) {
+	suite.Run(t, new(RefreshTokenReuseTestSuite))
+}
+
+func (suite *RefreshTokenReuseTestSuite) requestContext() *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest("POST", "/api/v1/auth/refresh-token", nil)
+	return ctx
+}
+
+func (suite *RefreshTokenReuseTestSuite) signin() string {
+	ctx := suite.requestContext()
+
+	refreshToken, payload, err := suite.userService.CreateRefreshToken(ctx, suite.user, time.Hour)
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.userService.CreateSession(ctx, payload, refreshToken))
+
+	return refreshToken
+}
+
+// refresh performs the same steps as AuthHandler.RefreshToken
+func (suite *RefreshTokenReuseTestSuite) refresh(refreshToken string) (string, error) {
+	ctx := suite.requestContext()
+
+	payload, err := suite.userService.VerifyToken(ctx, refreshToken)
+	if err != nil {
+		return "", err
+	}
+
+	session, err := suite.userService.GetSession(ctx, payload, refreshToken)
+	if err != nil {
+		return "", err
+	}
+
+	newToken, newPayload, err := suite.userService.CreateRefreshToken(ctx, suite.user, time.Hour)
+	if err != nil {
+		return "", err
+	}
+
+	err = suite.userService.RotateSession(ctx, session, newPayload, newToken)
+	if err != nil {
+		return "", err
+	}
+
+	return newToken, nil
+}
+
+func (suite *RefreshTokenReuseTestSuite) activeSessions() int {
+	active := 0
+	for _, session := range suite.store.sessions {
+		if !session.IsRevoked {
+			active++
+		}
+	}
+	return active
+}
+
+func (suite *RefreshTokenReuseTestSuite) TestRotationKeepsFamily() {
+	first := suite.signin()
+
+	second, err := suite.refresh(first)
+	suite.Require().NoError(err)
+
+	_, err = suite.refresh(second)
+	suite.Require().NoError(err)
+
+	families := map[uuid.UUID]bool{}
+	for _, session := range suite.store.sessions {
+		families[session.FamilyID] = true
+	}
+
+	suite.Len(suite.store.sessions, 3)
+	suite.Len(families, 1)
+	suite.Equal(1, suite.activeSessions())
+	suite.Empty(suite.store.events)
+}
+
+func (suite *RefreshTokenReuseTestSuite) TestStolenTokenReplayedAfterRotation() {
+	stolen := suite.signin()
+
+	// The legitimate client rotates first, then the attacker replays the copied token
+	legitimate, err := suite.refresh(stolen)
+	suite.Require().NoError(err)
+
+	_, err = suite.refresh(stolen)
+	suite.Equal(typesystem.TokenRevokedError, err)
+
+	suite.Equal(0, suite.activeSessions())
+	suite.Require().Len(suite.store.events, 1)
+	suite.Equal(entity.SecurityEventRefreshTokenReuse, suite.store.events[0].Type)
+	suite.Equal(suite.user.ID, suite.store.events[0].UserID)
+
+	_, err = suite.refresh(legitimate)
+	suite.Equal(typesystem.TokenRevokedError, err)
+}
+
+func (suite *RefreshTokenReuseTestSuite) TestStolenTokenUsedBeforeVictim() {
+	stolen := suite.signin()
+
+	// The attacker rotates first, so the victim's next refresh is the reuse
+	attacker, err := suite.refresh(stolen)
+	suite.Require().NoError(err)
+
+	_, err = suite.refresh(stolen)
+	suite.Equal(typesystem.TokenRevokedError, err)
+
+	_, err = suite.refresh(attacker)
+	suite.Equal(typesystem.TokenRevokedError, err)
+
+	suite.Equal(0, suite.activeSessions())
+}
+
+func (suite *RefreshTokenReuseTestSuite) TestReuseDoesNotAffectOtherFamilies() {
+	stolen := suite.signin()
+	other := suite.signin()
+
+	_, err := suite.refresh(stolen)
+	suite.Require().NoError(err)
+
+	_, err = suite.refresh(stolen)
+	suite.Equal(typesystem.TokenRevokedError, err)
+
+	_, err = suite.refresh(other)
+	suite.NoError(err)
+}
+
+func (suite *RefreshTokenReuseTestSuite) TestConcurrentReplayRevokesFamily() {
+	stolen := suite.signin()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = suite.refresh(stolen)
+		}(i)
+	}
+
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+
+	suite.LessOrEqual(succeeded, 1)
+	suite.Equal(0, suite.activeSessions())
+	suite.NotEmpty(suite.store.events)
+}
+
+func (suite *RefreshTokenReuseTestSuite) TestRefreshAfterLogout() {
+	refreshToken := suite.signin()
+
+	payload, err := suite.userService.VerifyToken(suite.requestContext(), refreshToken)
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.userService.RevokeSession(suite.requestContext(), payload.UserID, payload.ID))
+
+	_, err = suite.refresh(refreshToken)
+	suite.Equal(typesystem.Unauthorized, err)
+
+	suite.Empty(suite.store.events)
+}
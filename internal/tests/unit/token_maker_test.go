@@ -0,0 +1,154 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/token"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+	"github.com/google/uuid"
+	"github.com/o1egl/paseto"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	oldTokenKey = "12345678901234567890123456789012"
+	newTokenKey = "abcdefghijklmnopqrstuvwxyz123456"
+)
+
+type TokenMakerTestSuite struct {
+	suite.Suite
+	user *entity.User
+}
+
+func (suite *TokenMakerTestSuite) SetupTest() {
+	suite.user = &entity.User{ID: uuid.New(), Name: "John"}
+}
+
+func TestTokenMakerTestSuite(t *testing.T) {
+	suite.Run(t, new(TokenMakerTestSuite))
+}
+
+func (suite *TokenMakerTestSuite) keyringMaker(activeID string, keys map[string]string) token.Maker {
+	keyring, err := token.NewKeyring(activeID, keys)
+	suite.Require().NoError(err)
+
+	return token.NewPasetoKeyringMaker(keyring)
+}
+
+func (suite *TokenMakerTestSuite) TestCreateAndVerify() {
+	maker, err := token.NewPasetoMaker(oldTokenKey)
+	suite.Require().NoError(err)
+
+	tokenString, payload, err := maker.CreateToken(suite.user, time.Minute)
+	suite.NoError(err)
+
+	verified, err := maker.VerifyToken(tokenString)
+	suite.NoError(err)
+	suite.Equal(payload.ID, verified.ID)
+	suite.Equal(suite.user.ID, verified.UserID)
+	suite.Equal("John", verified.Username)
+}
+
+func (suite *TokenMakerTestSuite) TestFooterNamesActiveKey() {
+	maker := suite.keyringMaker("2026-10", map[string]string{"default": oldTokenKey, "2026-10": newTokenKey})
+
+	tokenString, _, err := maker.CreateToken(suite.user, time.Minute)
+	suite.NoError(err)
+
+	var footer map[string]string
+	suite.NoError(paseto.ParseFooter(tokenString, &footer))
+	suite.Equal("2026-10", footer["kid"])
+}
+
+func (suite *TokenMakerTestSuite) TestRetiredKeyStillVerifies() {
+	before := suite.keyringMaker("default", map[string]string{"default": oldTokenKey})
+	tokenString, payload, err := before.CreateToken(suite.user, time.Minute)
+	suite.NoError(err)
+
+	after := suite.keyringMaker("2026-10", map[string]string{"default": oldTokenKey, "2026-10": newTokenKey})
+
+	verified, err := after.VerifyToken(tokenString)
+	suite.NoError(err)
+	suite.Equal(payload.ID, verified.ID)
+
+	// Once the retired key is removed its tokens are rejected
+	removed := suite.keyringMaker("2026-10", map[string]string{"2026-10": newTokenKey})
+
+	_, err = removed.VerifyToken(tokenString)
+	suite.Equal(typesystem.TokenInvalidError, err)
+}
+
+func (suite *TokenMakerTestSuite) TestTokenWithoutFooterUsesDefaultKey() {
+	payload, err := entity.NewPayload(suite.user.Name, suite.user.ID, time.Minute)
+	suite.Require().NoError(err)
+
+	legacy, err := paseto.NewV2().Encrypt([]byte(oldTokenKey), payload, nil)
+	suite.Require().NoError(err)
+
+	maker := suite.keyringMaker("2026-10", map[string]string{"default": oldTokenKey, "2026-10": newTokenKey})
+
+	verified, err := maker.VerifyToken(legacy)
+	suite.NoError(err)
+	suite.Equal(payload.ID, verified.ID)
+}
+
+func (suite *TokenMakerTestSuite) TestForgedKeyIDIsRejected() {
+	payload, err := entity.NewPayload(suite.user.Name, suite.user.ID, time.Minute)
+	suite.Require().NoError(err)
+
+	// Encrypted with the old key but claiming to use the new one
+	forged, err := paseto.NewV2().Encrypt([]byte(oldTokenKey), payload, map[string]string{"kid": "2026-10"})
+	suite.Require().NoError(err)
+
+	maker := suite.keyringMaker("2026-10", map[string]string{"default": oldTokenKey, "2026-10": newTokenKey})
+
+	_, err = maker.VerifyToken(forged)
+	suite.Equal(typesystem.TokenInvalidError, err)
+
+	unknown, err := paseto.NewV2().Encrypt([]byte(oldTokenKey), payload, map[string]string{"kid": "unknown"})
+	suite.Require().NoError(err)
+
+	_, err = maker.VerifyToken(unknown)
+	suite.Equal(typesystem.TokenInvalidError, err)
+}
+
+func (suite *TokenMakerTestSuite) TestExpiredToken() {
+	maker := suite.keyringMaker("default", map[string]string{"default": oldTokenKey})
+
+	tokenString, _, err := maker.CreateToken(suite.user, -time.Minute)
+	suite.NoError(err)
+
+	_, err = maker.VerifyToken(tokenString)
+	suite.Equal(typesystem.TokenExpiredError, err)
+}
+
+func (suite *TokenMakerTestSuite) TestMalformedToken() {
+	maker := suite.keyringMaker("default", map[string]string{"default": oldTokenKey})
+
+	_, err := maker.VerifyToken("not-a-token")
+	suite.Equal(typesystem.TokenInvalidError, err)
+}
+
+func (suite *TokenMakerTestSuite) TestParseKeyring() {
+	keyring, err := token.ParseKeyring("b", []string{"a:" + oldTokenKey, " b:" + newTokenKey})
+	suite.NoError(err)
+	suite.Equal("b", keyring.ActiveID())
+
+	invalid := [][]string{
+		{"a:" + oldTokenKey},                     // active key missing
+		{"b" + newTokenKey},                      // no id
+		{"b:short"},                              // wrong size
+		{":" + newTokenKey},                      // empty id
+		{"b:" + newTokenKey, "b:" + oldTokenKey}, // duplicate id
+	}
+
+	for _, entries := range invalid {
+		_, err := token.ParseKeyring("b", entries)
+		suite.Error(err, entries)
+	}
+
+	_, err = token.NewPasetoMaker("short")
+	suite.Error(err)
+}
@@ -0,0 +1,132 @@
+package unit
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/pkg/totp"
+	"github.com/stretchr/testify/suite"
+)
+
+type TOTPTestSuite struct {
+	suite.Suite
+	// secret is the SHA-1 key of the RFC 6238 test vectors
+	secret string
+}
+
+func (suite *TOTPTestSuite) SetupTest() {
+	suite.secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+}
+
+func TestTOTPTestSuite(t *testing.T) {
+	suite.Run(t, new(TOTPTestSuite))
+}
+
+func (suite *TOTPTestSuite) TestCodeAt_RFC6238Vectors() {
+	// The last six digits of the SHA-1 vectors in RFC 6238 appendix B
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, want := range vectors {
+		code, err := totp.CodeAt(suite.secret, totp.Step(time.Unix(unix, 0)))
+
+		suite.NoError(err)
+		suite.Equal(want, code, "time %d", unix)
+	}
+}
+
+func (suite *TOTPTestSuite) TestCodeAt_SecretFormatting() {
+	want, err := totp.CodeAt(suite.secret, 1)
+	suite.Require().NoError(err)
+
+	// Authenticator apps show secrets in lower case groups, without padding
+	code, err := totp.CodeAt("gezd gnbv gy3t qojq gezd gnbv gy3t qojq", 1)
+
+	suite.NoError(err)
+	suite.Equal(want, code)
+
+	_, err = totp.CodeAt("not base32!", 1)
+	suite.Error(err)
+}
+
+func (suite *TOTPTestSuite) TestStep() {
+	suite.Equal(int64(0), totp.Step(time.Unix(29, 0)))
+	suite.Equal(int64(1), totp.Step(time.Unix(30, 0)))
+	suite.Equal(int64(37037037), totp.Step(time.Unix(1111111111, 0)))
+}
+
+func (suite *TOTPTestSuite) TestValidate_Skew() {
+	now := time.Unix(1111111111, 0)
+
+	previous, err := totp.CodeAt(suite.secret, totp.Step(now)-1)
+	suite.Require().NoError(err)
+
+	step, ok := totp.Validate(suite.secret, previous, now, 1)
+	suite.True(ok)
+	suite.Equal(totp.Step(now)-1, step)
+
+	_, ok = totp.Validate(suite.secret, previous, now, 0)
+	suite.False(ok)
+
+	twoAgo, err := totp.CodeAt(suite.secret, totp.Step(now)-2)
+	suite.Require().NoError(err)
+
+	_, ok = totp.Validate(suite.secret, twoAgo, now, 1)
+	suite.False(ok)
+}
+
+func (suite *TOTPTestSuite) TestValidate_CodeFormatting() {
+	now := time.Unix(1111111111, 0)
+
+	step, ok := totp.Validate(suite.secret, " 050 471 ", now, 0)
+	suite.True(ok)
+	suite.Equal(totp.Step(now), step)
+
+	for _, code := range []string{"", "50471", "0504710", "abcdef", "050472"} {
+		_, ok := totp.Validate(suite.secret, code, now, 1)
+		suite.False(ok, "code %q", code)
+	}
+
+	_, ok = totp.Validate("not base32!", "050471", now, 1)
+	suite.False(ok)
+}
+
+func (suite *TOTPTestSuite) TestGenerateSecret() {
+	secret, err := totp.GenerateSecret()
+	suite.NoError(err)
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	suite.NoError(err)
+	suite.Len(key, totp.SecretSize)
+
+	other, err := totp.GenerateSecret()
+	suite.NoError(err)
+	suite.NotEqual(secret, other)
+
+	_, err = totp.CodeAt(secret, totp.Step(time.Now()))
+	suite.NoError(err)
+}
+
+func (suite *TOTPTestSuite) TestURI() {
+	uri, err := url.Parse(totp.URI("Paste Bin", "user@example.com", "JBSWY3DPEHPK3PXP"))
+	suite.Require().NoError(err)
+
+	suite.Equal("otpauth", uri.Scheme)
+	suite.Equal("totp", uri.Host)
+	suite.Equal("/Paste Bin:user@example.com", uri.Path)
+
+	query := uri.Query()
+	suite.Equal("JBSWY3DPEHPK3PXP", query.Get("secret"))
+	suite.Equal("Paste Bin", query.Get("issuer"))
+	suite.Equal("SHA1", query.Get("algorithm"))
+	suite.Equal("6", query.Get("digits"))
+	suite.Equal("30", query.Get("period"))
+}
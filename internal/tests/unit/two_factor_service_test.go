@@ -0,0 +1,232 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Caixetadev/snippet/internal/entity"
+	"github.com/Caixetadev/snippet/internal/infra/memory"
+	"github.com/Caixetadev/snippet/internal/services"
+	"github.com/Caixetadev/snippet/internal/tests/mocks"
+	"github.com/Caixetadev/snippet/pkg/totp"
+	"github.com/Caixetadev/snippet/pkg/typesystem"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type TwoFactorServiceTestSuite struct {
+	suite.Suite
+	mocksRepo *mocks.TwoFactorRepository
+	service   *services.TwoFactorService
+	userID    uuid.UUID
+	secret    string
+}
+
+func (suite *TwoFactorServiceTestSuite) SetupTest() {
+	suite.mocksRepo = new(mocks.TwoFactorRepository)
+	lockout := services.NewLockoutService(memory.NewAttemptStore(), map[string]entity.LockoutPolicy{
+		entity.AttemptScopeTwoFactor: {Threshold: 3, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+	})
+	suite.service = services.NewTwoFactorService(suite.mocksRepo, lockout, "Paste")
+	suite.userID = uuid.New()
+
+	secret, err := totp.GenerateSecret()
+	suite.Require().NoError(err)
+	suite.secret = secret
+}
+
+func TestTwoFactorServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(TwoFactorServiceTestSuite))
+}
+
+func (suite *TwoFactorServiceTestSuite) currentCode() string {
+	code, err := totp.CodeAt(suite.secret, totp.Step(time.Now()))
+	suite.Require().NoError(err)
+	return code
+}
+
+func (suite *TwoFactorServiceTestSuite) TestSetup() {
+	ctx := context.TODO()
+	user := &entity.User{ID: suite.userID, Email: "user@example.com"}
+
+	suite.mocksRepo.On("SetTOTPSecret", ctx, suite.userID, mock.AnythingOfType("string")).Return(nil)
+
+	setup, err := suite.service.Setup(ctx, user)
+
+	suite.NoError(err)
+	suite.NotEmpty(setup.Secret)
+	suite.Contains(setup.URI, "otpauth://totp/Paste:user@example.com?")
+	suite.Contains(setup.URI, "secret="+setup.Secret)
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *TwoFactorServiceTestSuite) TestSetup_AlreadyEnabled() {
+	ctx := context.TODO()
+	user := &entity.User{ID: suite.userID, Email: "user@example.com"}
+
+	suite.mocksRepo.On("SetTOTPSecret", ctx, suite.userID, mock.AnythingOfType("string")).Return(pgx.ErrNoRows)
+
+	_, err := suite.service.Setup(ctx, user)
+
+	suite.Equal(services.ErrTwoFactorEnabled, err)
+}
+
+func (suite *TwoFactorServiceTestSuite) TestConfirm() {
+	ctx := context.TODO()
+
+	suite.mocksRepo.On("FindTwoFactor", ctx, suite.userID).Return(&entity.TwoFactor{UserID: suite.userID, Secret: suite.secret}, nil)
+
+	var stored []*entity.RecoveryCode
+	suite.mocksRepo.On("EnableTOTP", ctx, suite.userID, mock.AnythingOfType("int64"), mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		stored = args.Get(3).([]*entity.RecoveryCode)
+	})
+
+	codes, err := suite.service.Confirm(ctx, suite.userID, suite.currentCode())
+
+	suite.NoError(err)
+	suite.Len(codes, entity.RecoveryCodeCount)
+	suite.Len(stored, entity.RecoveryCodeCount)
+
+	for i, code := range codes {
+		suite.NotEqual(code, stored[i].CodeHash)
+		suite.Equal(entity.HashToken(code), stored[i].CodeHash)
+	}
+}
+
+func (suite *TwoFactorServiceTestSuite) TestConfirm_InvalidCode() {
+	ctx := context.TODO()
+
+	suite.mocksRepo.On("FindTwoFactor", ctx, suite.userID).Return(&entity.TwoFactor{UserID: suite.userID, Secret: suite.secret}, nil)
+
+	_, err := suite.service.Confirm(ctx, suite.userID, "000000x")
+
+	suite.Equal(services.ErrInvalidTwoFactorCode, err)
+	suite.mocksRepo.AssertNotCalled(suite.T(), "EnableTOTP", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *TwoFactorServiceTestSuite) TestConfirm_NotSetUp() {
+	ctx := context.TODO()
+
+	suite.mocksRepo.On("FindTwoFactor", ctx, suite.userID).Return(&entity.TwoFactor{UserID: suite.userID}, nil)
+
+	_, err := suite.service.Confirm(ctx, suite.userID, "123456")
+
+	suite.Equal(services.ErrTwoFactorNotSetUp, err)
+}
+
+func (suite *TwoFactorServiceTestSuite) TestCreateChallenge() {
+	ctx := context.TODO()
+
+	var challenge *entity.SigninChallenge
+	suite.mocksRepo.On("CreateSigninChallenge", ctx, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		challenge = args.Get(1).(*entity.SigninChallenge)
+	})
+
+	token, err := suite.service.CreateChallenge(ctx, suite.userID)
+
+	suite.NoError(err)
+	suite.Equal(suite.userID, challenge.UserID)
+	suite.Equal(entity.HashToken(token), challenge.TokenHash)
+	suite.True(challenge.ExpiresAt.After(time.Now()))
+}
+
+func (suite *TwoFactorServiceTestSuite) expectChallenge(ctx context.Context, token string) *entity.SigninChallenge {
+	challenge := entity.NewSigninChallenge(suite.userID, token)
+
+	suite.mocksRepo.On("FindSigninChallenge", ctx, entity.HashToken(token), mock.AnythingOfType("time.Time")).Return(challenge, nil)
+	suite.mocksRepo.On("FindTwoFactor", ctx, suite.userID).Return(&entity.TwoFactor{UserID: suite.userID, Secret: suite.secret, Enabled: true}, nil)
+
+	return challenge
+}
+
+func (suite *TwoFactorServiceTestSuite) TestVerifyChallenge_TOTP() {
+	ctx := context.TODO()
+	challenge := suite.expectChallenge(ctx, "token")
+
+	suite.mocksRepo.On("UseTOTPStep", ctx, suite.userID, mock.AnythingOfType("int64")).Return(nil)
+	suite.mocksRepo.On("DeleteSigninChallenge", ctx, challenge.ID).Return(nil)
+
+	userID, err := suite.service.VerifyChallenge(ctx, "token", suite.currentCode())
+
+	suite.NoError(err)
+	suite.Equal(suite.userID, userID)
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *TwoFactorServiceTestSuite) TestVerifyChallenge_ReplayedStep() {
+	ctx := context.TODO()
+	challenge := suite.expectChallenge(ctx, "token")
+
+	suite.mocksRepo.On("UseTOTPStep", ctx, suite.userID, mock.AnythingOfType("int64")).Return(pgx.ErrNoRows)
+	suite.mocksRepo.On("FailSigninChallenge", ctx, challenge.ID, entity.SigninChallengeMaxAttempts).Return(nil)
+
+	_, err := suite.service.VerifyChallenge(ctx, "token", suite.currentCode())
+
+	suite.Equal(services.ErrInvalidTwoFactorCode, err)
+	suite.mocksRepo.AssertNotCalled(suite.T(), "DeleteSigninChallenge", ctx, challenge.ID)
+}
+
+func (suite *TwoFactorServiceTestSuite) TestVerifyChallenge_RecoveryCode() {
+	ctx := context.TODO()
+	challenge := suite.expectChallenge(ctx, "token")
+
+	suite.mocksRepo.On("UseRecoveryCode", ctx, suite.userID, entity.HashToken("abcde-fghij"), mock.AnythingOfType("time.Time")).Return(nil)
+	suite.mocksRepo.On("DeleteSigninChallenge", ctx, challenge.ID).Return(nil)
+
+	userID, err := suite.service.VerifyChallenge(ctx, "token", " ABCDE-FGHIJ ")
+
+	suite.NoError(err)
+	suite.Equal(suite.userID, userID)
+	suite.mocksRepo.AssertNotCalled(suite.T(), "UseTOTPStep", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *TwoFactorServiceTestSuite) TestVerifyChallenge_WrongCode() {
+	ctx := context.TODO()
+	challenge := suite.expectChallenge(ctx, "token")
+
+	suite.mocksRepo.On("UseRecoveryCode", ctx, suite.userID, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(pgx.ErrNoRows)
+	suite.mocksRepo.On("FailSigninChallenge", ctx, challenge.ID, entity.SigninChallengeMaxAttempts).Return(nil)
+
+	_, err := suite.service.VerifyChallenge(ctx, "token", "wrong")
+
+	suite.Equal(services.ErrInvalidTwoFactorCode, err)
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *TwoFactorServiceTestSuite) TestVerifyChallenge_UnknownChallenge() {
+	ctx := context.TODO()
+
+	suite.mocksRepo.On("FindSigninChallenge", ctx, entity.HashToken("token"), mock.AnythingOfType("time.Time")).Return((*entity.SigninChallenge)(nil), pgx.ErrNoRows)
+
+	_, err := suite.service.VerifyChallenge(ctx, "token", "123456")
+
+	suite.Equal(typesystem.TokenInvalidError, err)
+}
+
+func (suite *TwoFactorServiceTestSuite) TestVerifyChallenge_LockedAcrossChallenges() {
+	ctx := context.TODO()
+
+	suite.mocksRepo.On("UseRecoveryCode", ctx, suite.userID, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(pgx.ErrNoRows)
+	suite.mocksRepo.On("FailSigninChallenge", ctx, mock.AnythingOfType("uuid.UUID"), entity.SigninChallengeMaxAttempts).Return(nil)
+
+	// Each sign-in gets a fresh challenge, the failures still add up
+	for _, token := range []string{"first", "second", "third"} {
+		suite.expectChallenge(ctx, token)
+
+		_, err := suite.service.VerifyChallenge(ctx, token, "wrong")
+		suite.Equal(services.ErrInvalidTwoFactorCode, err)
+	}
+
+	suite.expectChallenge(ctx, "fourth")
+
+	_, err := suite.service.VerifyChallenge(ctx, "fourth", suite.currentCode())
+
+	var tooMany typesystem.TooManyRequests
+	suite.ErrorAs(err, &tooMany)
+
+	suite.mocksRepo.AssertNotCalled(suite.T(), "UseTOTPStep", mock.Anything, mock.Anything, mock.Anything)
+	suite.mocksRepo.AssertNotCalled(suite.T(), "DeleteSigninChallenge", mock.Anything, mock.Anything)
+}
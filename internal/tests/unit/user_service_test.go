@@ -2,6 +2,7 @@ package unit
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"testing"
 	"time"
@@ -53,7 +54,7 @@ func (suite *UserServiceTestSuite) TestCreate() {
 	}
 
 	suite.validation.On("Validate", input).Return(nil)
-	suite.mocksPasswordHasher.On("GenerateFromPassword", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("int")).Return([]byte("password_hashed"), nil)
+	suite.mocksPasswordHasher.On("GenerateFromPassword", mock.AnythingOfType("[]uint8")).Return([]byte("password_hashed"), nil)
 	suite.mocksRepo.On("Insert", ctx, mock.AnythingOfType("*entity.User")).Return(nil)
 
 	user, err := suite.userService.Create(ctx, input)
@@ -64,7 +65,7 @@ func (suite *UserServiceTestSuite) TestCreate() {
 
 	suite.mocksRepo.AssertCalled(suite.T(), "Insert", ctx, mock.AnythingOfType("*entity.User"))
 	suite.validation.AssertCalled(suite.T(), "Validate", input)
-	suite.mocksPasswordHasher.AssertCalled(suite.T(), "GenerateFromPassword", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("int"))
+	suite.mocksPasswordHasher.AssertCalled(suite.T(), "GenerateFromPassword", mock.AnythingOfType("[]uint8"))
 }
 
 func (suite *UserServiceTestSuite) TestValidationFails() {
@@ -97,7 +98,7 @@ func (suite *UserServiceTestSuite) TestCreateWithUserRepositoryFailure() {
 
 	suite.validation.On("Validate", input).Return(nil)
 
-	suite.mocksPasswordHasher.On("GenerateFromPassword", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("int")).Return([]byte("password_hashed"), nil)
+	suite.mocksPasswordHasher.On("GenerateFromPassword", mock.AnythingOfType("[]uint8")).Return([]byte("password_hashed"), nil)
 
 	createdUser, err := suite.userService.Create(ctx, input)
 
@@ -106,7 +107,7 @@ func (suite *UserServiceTestSuite) TestCreateWithUserRepositoryFailure() {
 
 	suite.validation.AssertCalled(suite.T(), "Validate", input)
 	suite.mocksRepo.AssertCalled(suite.T(), "Insert", ctx, mock.Anything)
-	suite.mocksPasswordHasher.AssertCalled(suite.T(), "GenerateFromPassword", mock.Anything, mock.AnythingOfType("int"))
+	suite.mocksPasswordHasher.AssertCalled(suite.T(), "GenerateFromPassword", mock.Anything)
 }
 
 func (suite *UserServiceTestSuite) TestCreate_ErrorOnHash() {
@@ -118,7 +119,7 @@ func (suite *UserServiceTestSuite) TestCreate_ErrorOnHash() {
 	}
 
 	suite.validation.On("Validate", mock.Anything).Return(nil)
-	suite.mocksPasswordHasher.On("GenerateFromPassword", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("int")).Return([]byte(""), errors.New("error"))
+	suite.mocksPasswordHasher.On("GenerateFromPassword", mock.AnythingOfType("[]uint8")).Return([]byte(""), errors.New("error"))
 	suite.mocksRepo.On("Insert", ctx, mock.AnythingOfType("*entity.User")).Return(nil)
 
 	_, err := suite.userService.Create(ctx, input)
@@ -212,14 +213,14 @@ func (suite *UserServiceTestSuite) TestUpdatePassword() {
 
 	passwordHashed := "password_hashed"
 
-	suite.mocksPasswordHasher.On("GenerateFromPassword", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("int")).Return([]byte(passwordHashed), nil)
+	suite.mocksPasswordHasher.On("GenerateFromPassword", mock.AnythingOfType("[]uint8")).Return([]byte(passwordHashed), nil)
 	suite.mocksRepo.On("UpdatePassword", ctx, passwordHashed, id).Return(nil)
 
 	err := suite.userService.UpdatePassword(ctx, password, passwordConfirm, id)
 
 	suite.Nil(err)
 	suite.mocksRepo.AssertCalled(suite.T(), "UpdatePassword", ctx, passwordHashed, id)
-	suite.mocksPasswordHasher.AssertCalled(suite.T(), "GenerateFromPassword", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("int"))
+	suite.mocksPasswordHasher.AssertCalled(suite.T(), "GenerateFromPassword", mock.AnythingOfType("[]uint8"))
 }
 
 func (suite *UserServiceTestSuite) TestUpdatePassword_PasswordNotMatch() {
@@ -241,7 +242,7 @@ func (suite *UserServiceTestSuite) TestUpdatePassword_HashError() {
 	password := "password"
 	passwordConfirm := "password"
 
-	suite.mocksPasswordHasher.On("GenerateFromPassword", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("int")).Return([]byte(""), errors.New("error"))
+	suite.mocksPasswordHasher.On("GenerateFromPassword", mock.AnythingOfType("[]uint8")).Return([]byte(""), errors.New("error"))
 
 	err := suite.userService.UpdatePassword(ctx, password, passwordConfirm, id)
 
@@ -257,14 +258,14 @@ func (suite *UserServiceTestSuite) TestUpdatePassword_RepoError() {
 
 	passwordHashed := "password_hashed"
 
-	suite.mocksPasswordHasher.On("GenerateFromPassword", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("int")).Return([]byte(passwordHashed), nil)
+	suite.mocksPasswordHasher.On("GenerateFromPassword", mock.AnythingOfType("[]uint8")).Return([]byte(passwordHashed), nil)
 	suite.mocksRepo.On("UpdatePassword", ctx, passwordHashed, id).Return(errors.New("error"))
 
 	err := suite.userService.UpdatePassword(ctx, password, passwordConfirm, id)
 
 	suite.Assert().Equal(typesystem.ServerError, err)
 	suite.mocksRepo.AssertCalled(suite.T(), "UpdatePassword", ctx, passwordHashed, id)
-	suite.mocksPasswordHasher.AssertCalled(suite.T(), "GenerateFromPassword", mock.AnythingOfType("[]uint8"), mock.AnythingOfType("int"))
+	suite.mocksPasswordHasher.AssertCalled(suite.T(), "GenerateFromPassword", mock.AnythingOfType("[]uint8"))
 }
 
 func (suite *UserServiceTestSuite) TestVerifyCodeToResetPassword() {
@@ -345,11 +346,11 @@ func (suite *UserServiceTestSuite) TestVerifyToken_Invalid() {
 func (suite *UserServiceTestSuite) TestGetSession() {
 	ctx := context.TODO()
 
-	id := uuid.New()
+	payload := &entity.Payload{ID: uuid.New(), Username: "John"}
 
-	suite.mocksRepo.On("GetSession", ctx, id).Return(&entity.Session{}, nil)
+	suite.mocksRepo.On("GetSession", ctx, payload.ID).Return(&entity.Session{Name: "John", RefreshToken: "token"}, nil)
 
-	session, err := suite.userService.GetSession(ctx, id)
+	session, err := suite.userService.GetSession(ctx, payload, "token")
 
 	suite.Nil(err)
 	suite.NotNil(session)
@@ -358,11 +359,11 @@ func (suite *UserServiceTestSuite) TestGetSession() {
 func (suite *UserServiceTestSuite) TestGetSession_Error() {
 	ctx := context.TODO()
 
-	id := uuid.New()
+	payload := &entity.Payload{ID: uuid.New(), Username: "John"}
 
-	suite.mocksRepo.On("GetSession", ctx, id).Return(&entity.Session{}, errors.New("error"))
+	suite.mocksRepo.On("GetSession", ctx, payload.ID).Return(&entity.Session{}, errors.New("error"))
 
-	session, err := suite.userService.GetSession(ctx, id)
+	session, err := suite.userService.GetSession(ctx, payload, "token")
 
 	suite.Equal(err, typesystem.ServerError)
 	suite.Nil(session)
@@ -383,3 +384,229 @@ func (suite *UserServiceTestSuite) TestCompareHashAndPassowrd_Error() {
 
 	suite.Equal(err, typesystem.ServerError)
 }
+
+func (suite *UserServiceTestSuite) TestRehashPassword() {
+	ctx := context.TODO()
+	user := &entity.User{ID: uuid.New(), Password: "old"}
+
+	suite.mocksPasswordHasher.On("NeedsRehash", []byte("old")).Return(true)
+	suite.mocksPasswordHasher.On("GenerateFromPassword", []byte("secret")).Return([]byte("new"), nil)
+	suite.mocksRepo.On("ReplacePasswordHash", ctx, user.ID, "old", "new").Return(nil)
+
+	suite.userService.RehashPassword(ctx, user, "secret")
+
+	suite.Equal("new", user.Password)
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *UserServiceTestSuite) TestRehashPassword_UpToDate() {
+	ctx := context.TODO()
+	user := &entity.User{ID: uuid.New(), Password: "current"}
+
+	suite.mocksPasswordHasher.On("NeedsRehash", []byte("current")).Return(false)
+
+	suite.userService.RehashPassword(ctx, user, "secret")
+
+	suite.Equal("current", user.Password)
+	suite.mocksPasswordHasher.AssertNotCalled(suite.T(), "GenerateFromPassword", mock.Anything)
+	suite.mocksRepo.AssertNotCalled(suite.T(), "ReplacePasswordHash", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *UserServiceTestSuite) TestGetSession_Rotated() {
+	ctx := context.TODO()
+
+	payload := &entity.Payload{ID: uuid.New(), UserID: uuid.New()}
+	familyID := uuid.New()
+
+	suite.mocksRepo.On("GetSession", ctx, payload.ID).Return(&entity.Session{ID: payload.ID, UserID: payload.UserID, FamilyID: familyID, RefreshToken: "token", IsRevoked: true, RevokedFor: entity.SessionRotated}, nil)
+	suite.mocksRepo.On("RevokeSessionFamily", ctx, familyID).Return(nil)
+	suite.mocksRepo.On("InsertSecurityEvent", ctx, mock.MatchedBy(func(event *entity.SecurityEvent) bool {
+		return event.Type == entity.SecurityEventRefreshTokenReuse && event.FamilyID == familyID
+	})).Return(nil)
+
+	session, err := suite.userService.GetSession(ctx, payload, "token")
+
+	suite.Equal(typesystem.TokenRevokedError, err)
+	suite.Nil(session)
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *UserServiceTestSuite) TestGetSession_SignedOut() {
+	ctx := context.TODO()
+
+	payload := &entity.Payload{ID: uuid.New(), UserID: uuid.New()}
+
+	suite.mocksRepo.On("GetSession", ctx, payload.ID).Return(&entity.Session{ID: payload.ID, UserID: payload.UserID, FamilyID: uuid.New(), RefreshToken: "token", IsRevoked: true, RevokedFor: entity.SessionSignedOut}, nil)
+
+	session, err := suite.userService.GetSession(ctx, payload, "token")
+
+	suite.Equal(typesystem.Unauthorized, err)
+	suite.Nil(session)
+	suite.mocksRepo.AssertNotCalled(suite.T(), "RevokeSessionFamily", mock.Anything, mock.Anything)
+	suite.mocksRepo.AssertNotCalled(suite.T(), "InsertSecurityEvent", mock.Anything, mock.Anything)
+}
+
+func (suite *UserServiceTestSuite) TestGetSession_OtherUser() {
+	ctx := context.TODO()
+
+	payload := &entity.Payload{ID: uuid.New(), UserID: uuid.New()}
+
+	suite.mocksRepo.On("GetSession", ctx, payload.ID).Return(&entity.Session{UserID: uuid.New(), RefreshToken: "token"}, nil)
+
+	session, err := suite.userService.GetSession(ctx, payload, "token")
+
+	suite.Equal(typesystem.Unauthorized, err)
+	suite.Nil(session)
+}
+
+func (suite *UserServiceTestSuite) TestListSessions() {
+	ctx := context.TODO()
+	userID := uuid.New()
+
+	sessions := []*entity.Session{{ID: uuid.New(), UserID: userID}}
+
+	suite.mocksRepo.On("FindSessionsByUser", ctx, userID, mock.AnythingOfType("time.Time")).Return(sessions, nil)
+
+	output, err := suite.userService.ListSessions(ctx, userID)
+
+	suite.Nil(err)
+	suite.Equal(sessions, output)
+}
+
+func (suite *UserServiceTestSuite) TestRevokeSession() {
+	ctx := context.TODO()
+	userID := uuid.New()
+	id := uuid.New()
+
+	suite.mocksRepo.On("RevokeSession", ctx, id, userID).Return(nil)
+
+	err := suite.userService.RevokeSession(ctx, userID, id)
+
+	suite.Nil(err)
+	suite.mocksRepo.AssertExpectations(suite.T())
+}
+
+func (suite *UserServiceTestSuite) TestRevokeSession_NotFound() {
+	ctx := context.TODO()
+	userID := uuid.New()
+	id := uuid.New()
+
+	suite.mocksRepo.On("RevokeSession", ctx, id, userID).Return(pgx.ErrNoRows)
+
+	err := suite.userService.RevokeSession(ctx, userID, id)
+
+	suite.Equal(typesystem.NotFound, err)
+}
+
+func (suite *UserServiceTestSuite) TestRevokeAllSessions_Error() {
+	ctx := context.TODO()
+	userID := uuid.New()
+
+	suite.mocksRepo.On("RevokeAllSessions", ctx, userID).Return(errors.New("error"))
+
+	err := suite.userService.RevokeAllSessions(ctx, userID)
+
+	suite.Equal(typesystem.ServerError, err)
+}
+
+func (suite *UserServiceTestSuite) TestRequestEmailVerification() {
+	ctx := context.TODO()
+	user := &entity.User{ID: uuid.New(), Name: "John", Email: "john@example.com"}
+
+	var token string
+
+	suite.mocksRepo.On(
+		"StoreEmailVerification",
+		ctx,
+		mock.MatchedBy(func(verification *entity.EmailVerification) bool {
+			return verification.UserID == user.ID && verification.ExpiresAt.After(time.Now())
+		}),
+		mock.MatchedBy(func(job *entity.Job) bool {
+			var email entity.Email
+			if json.Unmarshal(job.Payload, &email) != nil {
+				return false
+			}
+
+			token = email.Data.Code
+			return job.Queue == entity.EmailQueue &&
+				email.To == user.Email &&
+				email.Template == entity.EmailVerificationTemplate
+		}),
+	).Return(nil)
+
+	err := suite.userService.RequestEmailVerification(ctx, user)
+
+	suite.Nil(err)
+	suite.mocksRepo.AssertExpectations(suite.T())
+
+	verification := suite.mocksRepo.Calls[0].Arguments.Get(1).(*entity.EmailVerification)
+	suite.Equal(entity.HashToken(token), verification.TokenHash)
+}
+
+func (suite *UserServiceTestSuite) TestRequestPasswordReset() {
+	ctx := context.TODO()
+	user := &entity.User{ID: uuid.New(), Name: "John", Email: "john@example.com"}
+
+	suite.mocksRepo.On(
+		"StoreVerificationData",
+		ctx,
+		mock.AnythingOfType("*entity.VerificationData"),
+		mock.AnythingOfType("*entity.Job"),
+	).Return(nil)
+
+	err := suite.userService.RequestPasswordReset(ctx, user)
+
+	suite.Nil(err)
+
+	verificationData := suite.mocksRepo.Calls[0].Arguments.Get(1).(*entity.VerificationData)
+	job := suite.mocksRepo.Calls[0].Arguments.Get(2).(*entity.Job)
+
+	var email entity.Email
+	suite.NoError(json.Unmarshal(job.Payload, &email))
+	suite.Equal(verificationData.Code, email.Data.Code)
+	suite.Equal(user.ID, verificationData.UserID)
+	suite.Equal(entity.PasswordRecoveryTemplate, email.Template)
+}
+
+func (suite *UserServiceTestSuite) TestRequestPasswordReset_StoreError() {
+	ctx := context.TODO()
+	user := &entity.User{ID: uuid.New(), Name: "John", Email: "john@example.com"}
+
+	suite.mocksRepo.On("StoreVerificationData", ctx, mock.Anything, mock.Anything).Return(errors.New("error"))
+
+	err := suite.userService.RequestPasswordReset(ctx, user)
+
+	suite.Equal(typesystem.ServerError, err)
+}
+
+func (suite *UserServiceTestSuite) TestVerifyEmail() {
+	ctx := context.TODO()
+
+	suite.mocksRepo.On("VerifyEmail", ctx, entity.HashToken("token"), mock.AnythingOfType("time.Time")).Return(nil)
+
+	err := suite.userService.VerifyEmail(ctx, "token")
+
+	suite.Nil(err)
+}
+
+func (suite *UserServiceTestSuite) TestVerifyEmail_InvalidToken() {
+	ctx := context.TODO()
+
+	suite.mocksRepo.On("VerifyEmail", ctx, entity.HashToken("token"), mock.AnythingOfType("time.Time")).Return(pgx.ErrNoRows)
+
+	err := suite.userService.VerifyEmail(ctx, "token")
+
+	suite.Equal(typesystem.TokenInvalidError, err)
+}
+
+func (suite *UserServiceTestSuite) TestIsEmailVerified() {
+	ctx := context.TODO()
+	userID := uuid.New()
+
+	suite.mocksRepo.On("FindOneByID", ctx, userID).Return(&entity.User{ID: userID, EmailVerified: true}, nil)
+
+	verified, err := suite.userService.IsEmailVerified(ctx, userID)
+
+	suite.Nil(err)
+	suite.True(verified)
+}
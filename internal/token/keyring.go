@@ -0,0 +1,74 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// DefaultKeyID names the key given as TOKEN_SYMMETRIC_KEY. Tokens issued before key IDs
+// were added to the footer have no key ID and are verified with it.
+const DefaultKeyID = "default"
+
+// Keyring holds the symmetric keys tokens are verified with. New tokens are encrypted with
+// the active key; the others are retired and kept only so their tokens stay valid until they
+// expire. To rotate, add a new key, make it active, and remove the old one once
+// REFRESH_TOKEN_DURATION has passed.
+type Keyring struct {
+	activeID string
+	keys     map[string][]byte
+}
+
+// NewKeyring creates a keyring from keys by ID. activeID must be one of them.
+func NewKeyring(activeID string, keys map[string]string) (*Keyring, error) {
+	keyring := &Keyring{activeID: activeID, keys: make(map[string][]byte, len(keys))}
+
+	for id, key := range keys {
+		if id == "" {
+			return nil, fmt.Errorf("invalid key id: must not be empty")
+		}
+
+		if len(key) != chacha20poly1305.KeySize {
+			return nil, fmt.Errorf("invalid key size for %q: must be exactly %d characters", id, chacha20poly1305.KeySize)
+		}
+
+		keyring.keys[id] = []byte(key)
+	}
+
+	if _, ok := keyring.keys[activeID]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyring", activeID)
+	}
+
+	return keyring, nil
+}
+
+// ParseKeyring creates a keyring from entries written as <id>:<key>
+func ParseKeyring(activeID string, entries []string) (*Keyring, error) {
+	keys := make(map[string]string, len(entries))
+
+	for _, entry := range entries {
+		id, key, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid key entry: want <id>:<key>")
+		}
+
+		if _, ok := keys[id]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+
+		keys[id] = key
+	}
+
+	return NewKeyring(activeID, keys)
+}
+
+// ActiveID returns the ID of the key new tokens are encrypted with
+func (k *Keyring) ActiveID() string {
+	return k.activeID
+}
+
+func (k *Keyring) key(id string) ([]byte, bool) {
+	key, ok := k.keys[id]
+	return key, ok
+}
@@ -1,13 +1,11 @@
 package token
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/Caixetadev/snippet/internal/entity"
 	"github.com/Caixetadev/snippet/pkg/typesystem"
 	"github.com/o1egl/paseto"
-	"golang.org/x/crypto/chacha20poly1305"
 )
 
 type Maker interface {
@@ -18,23 +16,34 @@ type Maker interface {
 	VerifyToken(token string) (*entity.Payload, error)
 }
 
+// footer is sent in clear next to the encrypted payload. It names the key the token was
+// encrypted with; a forged key ID only makes decryption fail.
+type footer struct {
+	KeyID string `json:"kid"`
+}
+
 type PasetoMaker struct {
-	paseto       *paseto.V2
-	symmetricKey []byte
+	paseto  *paseto.V2
+	keyring *Keyring
 }
 
-// NewPasetoMaker creates a new PasetoMaker
+// NewPasetoMaker creates a new PasetoMaker with a single key
 func NewPasetoMaker(symmetricKey string) (Maker, error) {
-	if len(symmetricKey) != chacha20poly1305.KeySize {
-		return nil, fmt.Errorf("invalid key size: must be exactly %d characters", chacha20poly1305.KeySize)
+	keyring, err := NewKeyring(DefaultKeyID, map[string]string{DefaultKeyID: symmetricKey})
+	if err != nil {
+		return nil, err
 	}
 
-	maker := &PasetoMaker{
-		paseto:       paseto.NewV2(),
-		symmetricKey: []byte(symmetricKey),
-	}
+	return NewPasetoKeyringMaker(keyring), nil
+}
 
-	return maker, nil
+// NewPasetoKeyringMaker creates a new PasetoMaker that encrypts with the active key of
+// keyring and verifies with any of its keys
+func NewPasetoKeyringMaker(keyring *Keyring) Maker {
+	return &PasetoMaker{
+		paseto:  paseto.NewV2(),
+		keyring: keyring,
+	}
 }
 
 // CreateToken creates a new token for a specific username and duration
@@ -44,15 +53,34 @@ func (maker *PasetoMaker) CreateToken(user *entity.User, duration time.Duration)
 		return "", payload, err
 	}
 
-	token, err := maker.paseto.Encrypt(maker.symmetricKey, payload, nil)
+	keyID := maker.keyring.ActiveID()
+	key, _ := maker.keyring.key(keyID)
+
+	token, err := maker.paseto.Encrypt(key, payload, footer{KeyID: keyID})
 	return token, payload, err
 }
 
 // VerifyToken checks if the token is valid or not
 func (maker *PasetoMaker) VerifyToken(token string) (*entity.Payload, error) {
+	var tokenFooter footer
+
+	err := paseto.ParseFooter(token, &tokenFooter)
+	if err != nil {
+		return nil, typesystem.TokenInvalidError
+	}
+
+	if tokenFooter.KeyID == "" {
+		tokenFooter.KeyID = DefaultKeyID
+	}
+
+	key, ok := maker.keyring.key(tokenFooter.KeyID)
+	if !ok {
+		return nil, typesystem.TokenInvalidError
+	}
+
 	payload := &entity.Payload{}
 
-	err := maker.paseto.Decrypt(token, maker.symmetricKey, payload, nil)
+	err = maker.paseto.Decrypt(token, key, payload, nil)
 	if err != nil {
 		return nil, typesystem.TokenInvalidError
 	}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	cryptorand "crypto/rand"
+	"math/big"
 	"math/rand"
 	"strings"
 )
@@ -27,3 +29,22 @@ func StringToPtr(s string) *string {
 
 	return &s
 }
+
+// GenerateSecureRandomString generate a string of cryptographically secure random characters of given length
+func GenerateSecureRandomString(n int) (string, error) {
+	sb := strings.Builder{}
+	sb.Grow(n)
+
+	max := big.NewInt(int64(len(letterBytes)))
+
+	for i := 0; i < n; i++ {
+		idx, err := cryptorand.Int(cryptorand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteByte(letterBytes[idx.Int64()])
+	}
+
+	return sb.String(), nil
+}
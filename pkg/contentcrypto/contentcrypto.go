@@ -0,0 +1,161 @@
+// Package contentcrypto encrypts post bodies at rest. Each post has a random data key
+// that seals its bodies with XChaCha20-Poly1305. The data key is stored wrapped, either
+// with a key derived from the post password with Argon2id or with a master key.
+package contentcrypto
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var (
+	ErrDecrypt    = errors.New("contentcrypto: message authentication failed")
+	ErrInvalidKey = fmt.Errorf("contentcrypto: keys must be %d bytes", KeySize)
+)
+
+// KeySize is the length of data keys and master keys
+const KeySize = chacha20poly1305.KeySize
+
+const (
+	wrapVersion = 1
+	saltSize    = 16
+)
+
+// PasswordParams are the Argon2id cost parameters used to derive a key from a password
+type PasswordParams struct {
+	// Memory is given in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// Keys holds what is needed to wrap data keys
+type Keys struct {
+	// MasterKey wraps the data keys of private posts. Without it they are not encrypted.
+	MasterKey []byte
+	Password  PasswordParams
+}
+
+// NewKey returns a random data key
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// Seal encrypts plaintext with key. The random nonce is prepended to the ciphertext.
+func Seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts data sealed with Seal
+func Open(key, sealed []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
+
+// WrapWithPassword seals dataKey with a key derived from password. The salt and cost
+// parameters are stored with it, so changing params does not affect wrapped keys:
+// version (1) | memory (4) | iterations (4) | parallelism (1) | salt (16) | sealed key
+func WrapWithPassword(dataKey, password []byte, params PasswordParams) ([]byte, error) {
+	header := make([]byte, 10, 10+saltSize)
+	header[0] = wrapVersion
+	binary.BigEndian.PutUint32(header[1:5], params.Memory)
+	binary.BigEndian.PutUint32(header[5:9], params.Iterations)
+	header[9] = params.Parallelism
+
+	salt := make([]byte, saltSize)
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := Seal(deriveKey(password, salt, params), dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append(header, salt...), sealed...), nil
+}
+
+// UnwrapWithPassword returns the data key wrapped by WrapWithPassword
+func UnwrapWithPassword(wrapped, password []byte) ([]byte, error) {
+	if len(wrapped) < 10+saltSize || wrapped[0] != wrapVersion {
+		return nil, ErrDecrypt
+	}
+
+	params := PasswordParams{
+		Memory:      binary.BigEndian.Uint32(wrapped[1:5]),
+		Iterations:  binary.BigEndian.Uint32(wrapped[5:9]),
+		Parallelism: wrapped[9],
+	}
+
+	if params.Iterations == 0 || params.Parallelism == 0 {
+		return nil, ErrDecrypt
+	}
+
+	salt, sealed := wrapped[10:10+saltSize], wrapped[10+saltSize:]
+
+	return Open(deriveKey(password, salt, params), sealed)
+}
+
+// WrapWithKey seals dataKey with masterKey: version (1) | sealed key
+func WrapWithKey(dataKey, masterKey []byte) ([]byte, error) {
+	sealed, err := Seal(masterKey, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{wrapVersion}, sealed...), nil
+}
+
+// UnwrapWithKey returns the data key wrapped by WrapWithKey
+func UnwrapWithKey(wrapped, masterKey []byte) ([]byte, error) {
+	if len(wrapped) < 1 || wrapped[0] != wrapVersion {
+		return nil, ErrDecrypt
+	}
+
+	return Open(masterKey, wrapped[1:])
+}
+
+func deriveKey(password, salt []byte, params PasswordParams) []byte {
+	return argon2.IDKey(password, salt, params.Iterations, params.Memory, params.Parallelism, KeySize)
+}
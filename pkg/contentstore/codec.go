@@ -0,0 +1,111 @@
+package contentstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+const (
+	CodecIdentity = "identity"
+	CodecGzip     = "gzip"
+)
+
+type codec struct {
+	extension string
+	encode    func(data []byte) ([]byte, error)
+	decode    func(data []byte) ([]byte, error)
+}
+
+var codecs = map[string]codec{
+	CodecIdentity: {
+		encode: func(data []byte) ([]byte, error) { return data, nil },
+		decode: func(data []byte) ([]byte, error) { return data, nil },
+	},
+	CodecGzip: {
+		extension: ".gz",
+		encode:    gzipEncode,
+		decode:    gzipDecode,
+	},
+}
+
+// ValidCodec reports whether codec is known
+func ValidCodec(codec string) bool {
+	_, ok := codecs[codec]
+	return ok
+}
+
+// Encode encodes data with codec
+func Encode(data []byte, codec string) ([]byte, error) {
+	c, ok := codecs[codec]
+	if !ok {
+		return nil, fmt.Errorf("contentstore: unknown codec %q", codec)
+	}
+
+	return c.encode(data)
+}
+
+// Decode reverses Encode
+func Decode(data []byte, codec string) ([]byte, error) {
+	c, ok := codecs[codec]
+	if !ok {
+		return nil, fmt.Errorf("contentstore: unknown codec %q", codec)
+	}
+
+	return c.decode(data)
+}
+
+// Compression decides how content is encoded at rest. Content shorter than Threshold,
+// or that does not get smaller, is stored as is.
+type Compression struct {
+	Codec     string
+	Threshold int
+}
+
+// Encode returns data encoded for storage and the codec it was encoded with
+func (c Compression) Encode(data []byte) ([]byte, string, error) {
+	if c.Codec == "" || c.Codec == CodecIdentity || len(data) < c.Threshold {
+		return data, CodecIdentity, nil
+	}
+
+	encoded, err := Encode(data, c.Codec)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(encoded) >= len(data) {
+		return data, CodecIdentity, nil
+	}
+
+	return encoded, c.Codec, nil
+}
+
+func gzipEncode(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writer := gzip.NewWriter(&buf)
+
+	_, err := writer.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func gzipDecode(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
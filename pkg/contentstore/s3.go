@@ -0,0 +1,120 @@
+package contentstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// S3Store keeps objects as Prefix+name in Bucket. It works with any S3-compatible
+// service the client is configured for.
+type S3Store struct {
+	client s3iface.S3API
+	Bucket string
+	Prefix string
+}
+
+func NewS3Store(client s3iface.S3API, bucket string, prefix string) *S3Store {
+	return &S3Store{client: client, Bucket: bucket, Prefix: prefix}
+}
+
+func (s *S3Store) objectKey(name string) (*string, error) {
+	if !ValidName(name) {
+		return nil, fmt.Errorf("contentstore: invalid name %q", name)
+	}
+
+	return aws.String(s.Prefix + name), nil
+}
+
+func (s *S3Store) Put(ctx context.Context, name string, data []byte) error {
+	key, err := s.objectKey(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         key,
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/octet-stream"),
+	})
+
+	return err
+}
+
+func (s *S3Store) Get(ctx context.Context, name string) ([]byte, error) {
+	key, err := s.objectKey(name)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    key,
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	defer output.Body.Close()
+
+	return io.ReadAll(output.Body)
+}
+
+func (s *S3Store) Exists(ctx context.Context, name string) (bool, error) {
+	key, err := s.objectKey(name)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    key,
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, name string) error {
+	key, err := s.objectKey(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    key,
+	})
+
+	return err
+}
+
+// isNotFound reports whether err is a missing object. HEAD responses have no body, so
+// their error only carries the status code.
+func isNotFound(err error) bool {
+	if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+		return true
+	}
+
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() == s3.ErrCodeNoSuchKey || awsErr.Code() == "NotFound"
+	}
+
+	return false
+}
@@ -0,0 +1,73 @@
+// Package contentstore stores paste bodies by the SHA-256 of their content, so identical
+// bodies are stored once. Bodies may be compressed; the object name then carries the
+// extension of the codec.
+package contentstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+var ErrNotFound = errors.New("contentstore: content not found")
+
+// Store keeps objects by name. Names are built with Name.
+type Store interface {
+	// Put stores data under name, replacing what was stored there
+	Put(ctx context.Context, name string, data []byte) error
+
+	// Get returns the data stored under name, or ErrNotFound
+	Get(ctx context.Context, name string) ([]byte, error)
+
+	// Exists reports whether something is stored under name
+	Exists(ctx context.Context, name string) (bool, error)
+
+	// Delete removes the data stored under name. Deleting missing data is not an error.
+	Delete(ctx context.Context, name string) error
+}
+
+const (
+	DriverFile = "file"
+	DriverS3   = "s3"
+)
+
+// Key returns the key content is stored under: its hex encoded SHA-256
+func Key(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidKey reports whether key looks like a key returned by Key
+func ValidKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+
+	for _, c := range key {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Name returns the object name of content stored under key encoded with codec
+func Name(key string, codec string) string {
+	return key + codecs[codec].extension
+}
+
+// ValidName reports whether name was built by Name. Stores reject anything else so
+// names can never escape their directory or prefix.
+func ValidName(name string) bool {
+	for _, c := range codecs {
+		key, ok := strings.CutSuffix(name, c.extension)
+		if ok && ValidKey(key) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,79 @@
+package highlight
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// PlainText is the language used when none is given and none can be detected
+const PlainText = "text"
+
+// Style is the chroma style used to render highlighted HTML
+const Style = "github"
+
+// Normalize resolves a language name, alias or file extension to its canonical
+// language identifier. It reports false when the language is not supported.
+func Normalize(language string) (string, bool) {
+	lexer := lexers.Get(strings.TrimSpace(language))
+	if lexer == nil {
+		return "", false
+	}
+
+	return canonicalName(lexer), true
+}
+
+// Detect guesses the language of content, using filename first when it looks like one
+func Detect(filename string, content string) string {
+	lexer := lexers.Match(filename)
+	if lexer == nil {
+		lexer = lexers.Analyse(content)
+	}
+
+	if lexer == nil {
+		return PlainText
+	}
+
+	return canonicalName(lexer)
+}
+
+// RenderHTML renders content as a standalone HTML document highlighted for language
+func RenderHTML(content string, language string) (string, error) {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	formatter := html.New(
+		html.Standalone(true),
+		html.WithLineNumbers(true),
+		html.TabWidth(4),
+	)
+
+	var buffer bytes.Buffer
+
+	err = formatter.Format(&buffer, styles.Get(Style), iterator)
+	if err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
+
+func canonicalName(lexer chroma.Lexer) string {
+	config := lexer.Config()
+	if len(config.Aliases) > 0 {
+		return config.Aliases[0]
+	}
+
+	return strings.ToLower(config.Name)
+}
@@ -0,0 +1,52 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileTransport writes every message as an .eml file to Dir instead of sending it.
+// It is meant for development and tests.
+type FileTransport struct {
+	Dir string
+}
+
+func NewFileTransport(dir string) (*FileTransport, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileTransport{Dir: dir}, nil
+}
+
+func (t *FileTransport) Send(ctx context.Context, msg *Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	random := make([]byte, 4)
+
+	_, err = rand.Read(random)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(random))
+
+	// Write to a temporary name first so readers never see a partial message
+	tmp := filepath.Join(t.Dir, "."+name)
+
+	err = os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(t.Dir, name))
+}
@@ -0,0 +1,171 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is an email with a plain text body, an HTML body or both
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// NewMessage creates a message addressed to a single recipient
+func NewMessage(from, to, subject, text, html string) *Message {
+	return &Message{
+		From:    from,
+		To:      []string{to},
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+	}
+}
+
+// Bytes encodes the message in RFC 5322 format. When both bodies are set they are
+// sent as multipart/alternative so that clients can pick the one they render.
+func (m *Message) Bytes() ([]byte, error) {
+	if m.From == "" || len(m.To) == 0 {
+		return nil, fmt.Errorf("mail: message needs a sender and at least one recipient")
+	}
+
+	var buf bytes.Buffer
+
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+
+	messageID, err := newMessageID(m.From)
+	if err != nil {
+		return nil, err
+	}
+
+	header("From", m.From)
+	header("To", strings.Join(m.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID)
+	header("MIME-Version", "1.0")
+
+	switch {
+	case m.Text != "" && m.HTML != "":
+		writer := multipart.NewWriter(&buf)
+
+		header("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": writer.Boundary()}))
+		buf.WriteString("\r\n")
+
+		err = writePart(writer, "text/plain", m.Text)
+		if err != nil {
+			return nil, err
+		}
+
+		err = writePart(writer, "text/html", m.HTML)
+		if err != nil {
+			return nil, err
+		}
+
+		err = writer.Close()
+		if err != nil {
+			return nil, err
+		}
+	case m.HTML != "":
+		header("Content-Type", "text/html; charset=UTF-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+
+		err = writeQuotedPrintable(&buf, m.HTML)
+	default:
+		header("Content-Type", "text/plain; charset=UTF-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+
+		err = writeQuotedPrintable(&buf, m.Text)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Recipients returns the bare addresses of the recipients, as needed for the SMTP envelope
+func (m *Message) Recipients() ([]string, error) {
+	recipients := make([]string, 0, len(m.To))
+
+	for _, to := range m.To {
+		address, err := mail.ParseAddress(to)
+		if err != nil {
+			return nil, err
+		}
+
+		recipients = append(recipients, address.Address)
+	}
+
+	return recipients, nil
+}
+
+// Sender returns the bare address of the sender
+func (m *Message) Sender() (string, error) {
+	address, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return "", err
+	}
+
+	return address.Address, nil
+}
+
+func writePart(writer *multipart.Writer, contentType string, body string) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType+"; charset=UTF-8")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	return writeQuotedPrintable(part, body)
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+
+	_, err := qp.Write([]byte(body))
+	if err != nil {
+		return err
+	}
+
+	return qp.Close()
+}
+
+func newMessageID(from string) (string, error) {
+	random := make([]byte, 16)
+
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+
+	domain := "localhost"
+	if address, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(address.Address, "@"); at != -1 {
+			domain = address.Address[at+1:]
+		}
+	}
+
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(random), domain), nil
+}
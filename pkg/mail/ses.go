@@ -0,0 +1,30 @@
+package mail
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/service/ses"
+)
+
+// SESTransport sends messages through Amazon SES
+type SESTransport struct {
+	client *ses.SES
+}
+
+func NewSESTransport(client *ses.SES) *SESTransport {
+	return &SESTransport{client: client}
+}
+
+// Send delivers msg as a raw email so that multipart bodies are preserved
+func (t *SESTransport) Send(ctx context.Context, msg *Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	_, err = t.client.SendRawEmailWithContext(ctx, &ses.SendRawEmailInput{
+		RawMessage: &ses.RawMessage{Data: data},
+	})
+
+	return err
+}
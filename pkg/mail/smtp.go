@@ -0,0 +1,125 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// ErrStartTLSUnsupported is returned when STARTTLS is required but the server does not offer it
+var ErrStartTLSUnsupported = errors.New("mail: smtp server does not support STARTTLS")
+
+// SMTPTransport sends messages to an SMTP server, upgrading the connection with STARTTLS
+type SMTPTransport struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+
+	// RequireTLS fails delivery when the server does not offer STARTTLS. When false,
+	// STARTTLS is still used if it is offered.
+	RequireTLS bool
+
+	// TLSConfig overrides the TLS settings used for STARTTLS
+	TLSConfig *tls.Config
+
+	// Timeout bounds dialing the server. Zero means 30 seconds.
+	Timeout time.Duration
+}
+
+func (t *SMTPTransport) Send(ctx context.Context, msg *Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	sender, err := msg.Sender()
+	if err != nil {
+		return err
+	}
+
+	recipients, err := msg.Recipients()
+	if err != nil {
+		return err
+	}
+
+	timeout := t.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(t.Host, strconv.Itoa(t.Port)))
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, t.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		tlsConfig := t.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{ServerName: t.Host}
+		}
+
+		err = client.StartTLS(tlsConfig)
+		if err != nil {
+			return err
+		}
+	} else if t.RequireTLS {
+		return ErrStartTLSUnsupported
+	}
+
+	if t.Username != "" {
+		// smtp.PlainAuth refuses to send credentials over an unencrypted connection
+		// unless the server is on localhost
+		err = client.Auth(smtp.PlainAuth("", t.Username, t.Password, t.Host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(sender)
+	if err != nil {
+		return err
+	}
+
+	for _, recipient := range recipients {
+		err = client.Rcpt(recipient)
+		if err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(data)
+	if err != nil {
+		return err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,14 @@
+package mail
+
+import "context"
+
+// Transport delivers messages
+type Transport interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+const (
+	DriverSES  = "ses"
+	DriverSMTP = "smtp"
+	DriverFile = "file"
+)
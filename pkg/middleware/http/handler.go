@@ -2,6 +2,7 @@ package http
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/Caixetadev/snippet/pkg/typesystem"
 	"github.com/gin-gonic/gin"
@@ -15,6 +16,9 @@ func ErrorHandler() gin.HandlerFunc {
 			switch e := err.Err.(type) {
 			case typesystem.Http:
 				c.AbortWithStatusJSON(e.StatusCode, e)
+			case typesystem.TooManyRequests:
+				c.Header("Retry-After", strconv.Itoa(e.RetryAfterSeconds()))
+				c.AbortWithStatusJSON(e.StatusCode, e.Http)
 			default:
 				c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{"message": "Service Unavailable"})
 			}
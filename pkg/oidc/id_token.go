@@ -0,0 +1,117 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// ClockSkew is the leeway given to the provider's clock when checking exp and iat
+const ClockSkew = time.Minute
+
+// Claims are the ID token claims used to identify a user
+type Claims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        audience `json:"aud"`
+	AuthorizedParty string   `json:"azp"`
+	ExpiresAt       int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	Nonce           string   `json:"nonce"`
+	Email           string   `json:"email"`
+	EmailVerified   bool     `json:"email_verified"`
+	Name            string   `json:"name"`
+}
+
+// Valid is called by the jwt parser. The checks that need the provider's configuration
+// are done in VerifyIDToken.
+func (c *Claims) Valid() error {
+	now := time.Now()
+
+	if c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(ClockSkew)) {
+		return fmt.Errorf("token is expired")
+	}
+
+	if c.IssuedAt != 0 && now.Add(ClockSkew).Before(time.Unix(c.IssuedAt, 0)) {
+		return fmt.Errorf("token used before issued")
+	}
+
+	return nil
+}
+
+// audience accepts both forms of the aud claim, a single string or an array
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var many []string
+	err := json.Unmarshal(data, &many)
+	if err != nil {
+		return err
+	}
+
+	*a = many
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// VerifyIDToken checks the signature and claims of an ID token as described in
+// OpenID Connect Core 1.0, section 3.1.3.7, and returns its claims
+func (p *Provider) VerifyIDToken(ctx context.Context, raw string, nonce string) (*Claims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &jwt.Parser{ValidMethods: []string{"RS256", "RS384", "RS512"}}
+
+	var claims Claims
+
+	_, err = parser.ParseWithClaims(raw, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.keys.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidIDToken, err)
+	}
+
+	if claims.Issuer != metadata.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, claims.Issuer)
+	}
+
+	if !claims.Audience.contains(p.config.ClientID) {
+		return nil, fmt.Errorf("%w: token is not meant for this client", ErrInvalidIDToken)
+	}
+
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID {
+		return nil, fmt.Errorf("%w: unexpected authorized party %q", ErrInvalidIDToken, claims.AuthorizedParty)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, ErrNonceMismatch
+	}
+
+	return &claims, nil
+}
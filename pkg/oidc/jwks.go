@@ -0,0 +1,133 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// keyRefreshInterval limits how often an unknown kid triggers a new fetch of the key set
+const keyRefreshInterval = time.Minute
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// keySet caches the provider's signing keys and refreshes them when a token is signed
+// with a key it does not know, which is how providers roll their keys
+type keySet struct {
+	uri    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+func newKeySet(uri string, client *http.Client) *keySet {
+	return &keySet{uri: uri, client: client}
+}
+
+func (ks *keySet) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if key, ok := ks.lookup(kid); ok {
+		return key, nil
+	}
+
+	if time.Since(ks.fetchedAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	err := ks.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, ok := ks.lookup(kid); ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// lookup finds the key with the given id. A token without kid is accepted when the
+// provider publishes a single key.
+func (ks *keySet) lookup(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+func (ks *keySet) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.uri, nil)
+	if err != nil {
+		return err
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	err = doJSON(ks.client, req, &set)
+	if err != nil {
+		return fmt.Errorf("fetch keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+
+		key, err := parseRSAKey(jwk)
+		if err != nil {
+			return err
+		}
+
+		keys[jwk.Kid] = key
+	}
+
+	ks.keys = keys
+	ks.fetchedAt = time.Now()
+
+	return nil
+}
+
+func parseRSAKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("key %q: exponent too large", jwk.Kid)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(exponent.Int64()),
+	}, nil
+}
@@ -0,0 +1,214 @@
+// Package oidc implements the parts of OpenID Connect needed to sign users in with an
+// external identity provider: discovery, the authorization code flow with PKCE and
+// verification of RS256 signed ID tokens.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrInvalidIDToken = errors.New("oidc: invalid id token")
+	ErrNonceMismatch  = errors.New("oidc: nonce mismatch")
+)
+
+// DefaultScopes are requested when Config.Scopes is empty
+var DefaultScopes = []string{"openid", "email", "profile"}
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Token is the response of the token endpoint
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is an OpenID Connect identity provider. Its metadata is discovered on first
+// use, so that an unreachable provider does not keep the application from starting.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *discovery
+	keys     *keySet
+}
+
+func NewProvider(config Config, client *http.Client) *Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = DefaultScopes
+	}
+
+	return &Provider{config: config, client: client}
+}
+
+// AuthCodeURL returns the URL of the provider's consent page. The challenge is
+// derived from verifier, which must be sent again to Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, nonce string, verifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", ChallengeS256(verifier))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades an authorization code for the provider's tokens
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string) (*Token, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	var token Token
+
+	err = doJSON(p.client, req, &token)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token exchange: %w", err)
+	}
+
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("oidc: token response has no id_token")
+	}
+
+	return &token, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	endpoint := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata discovery
+
+	err = doJSON(p.client, req, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+
+	// OpenID Connect Discovery 1.0, section 4.3
+	if metadata.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("oidc: discovery: issuer %q does not match %q", metadata.Issuer, p.config.Issuer)
+	}
+
+	p.metadata = &metadata
+	p.keys = newKeySet(metadata.JWKSURI, p.client)
+
+	return p.metadata, nil
+}
+
+func doJSON(client *http.Client, req *http.Request, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, body)
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+// GenerateVerifier returns a random PKCE code verifier
+func GenerateVerifier() (string, error) {
+	return randomString(32)
+}
+
+// GenerateNonce returns a random value suitable for the state and nonce parameters
+func GenerateNonce() (string, error) {
+	return randomString(24)
+}
+
+// ChallengeS256 derives the PKCE code challenge of verifier, RFC 7636 section 4.2
+func ChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,135 @@
+package passwordhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var ErrInvalidHash = errors.New("passwordhash: invalid hash")
+
+// Argon2Params are the Argon2id cost parameters
+type Argon2Params struct {
+	// Memory is given in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the OWASP password storage recommendation
+var DefaultArgon2Params = Argon2Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2idPasswordHasher hashes passwords with Argon2id and encodes them in the PHC
+// string format, $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2idPasswordHasher struct {
+	Params Argon2Params
+}
+
+// GenerateFromPassword generates an Argon2id hash from password
+func (h *Argon2idPasswordHasher) GenerateFromPassword(password []byte) ([]byte, error) {
+	salt := make([]byte, h.Params.SaltLength)
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey(password, salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, h.Params.KeyLength)
+
+	return []byte(encodeArgon2id(h.Params, salt, key)), nil
+}
+
+// CompareHashAndPassword compares a hash with a plaintext password.
+func (h *Argon2idPasswordHasher) CompareHashAndPassword(hashedPassword, password []byte) error {
+	return Compare(hashedPassword, password)
+}
+
+// NeedsRehash reports whether hashedPassword is not an Argon2id hash or uses weaker
+// parameters than the configured ones
+func (h *Argon2idPasswordHasher) NeedsRehash(hashedPassword []byte) bool {
+	params, _, key, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory < h.Params.Memory ||
+		params.Iterations < h.Params.Iterations ||
+		uint32(len(key)) < h.Params.KeyLength
+}
+
+func compareArgon2id(hashedPassword, password []byte) error {
+	params, salt, key, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	other := argon2.IDKey(password, salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatchedHashAndPassword
+	}
+
+	return nil
+}
+
+func encodeArgon2id(params Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		params.Memory,
+		params.Iterations,
+		params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func decodeArgon2id(hashedPassword []byte) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(string(hashedPassword), "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
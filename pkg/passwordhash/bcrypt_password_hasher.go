@@ -2,21 +2,40 @@ package passwordhash
 
 import "golang.org/x/crypto/bcrypt"
 
-// PasswordHasher is a password hashing interface
-type PasswordHasher interface {
-	GenerateFromPassword(password []byte, cost int) ([]byte, error)
-	CompareHashAndPassword(hashedPassword, password []byte) error
-}
-
 // BcryptPasswordHasher is a password hashing implementation
-type BcryptPasswordHasher struct{}
+type BcryptPasswordHasher struct {
+	// Cost is the bcrypt work factor. Zero means bcrypt.DefaultCost.
+	Cost int
+}
 
 // GenerateFromPassword generates a bcrypt hash from password
-func (h *BcryptPasswordHasher) GenerateFromPassword(password []byte, cost int) ([]byte, error) {
-	return bcrypt.GenerateFromPassword(password, cost)
+func (h *BcryptPasswordHasher) GenerateFromPassword(password []byte) ([]byte, error) {
+	return bcrypt.GenerateFromPassword(password, h.cost())
 }
 
-// CompareHashAndPassword compares a bcrypt hash with a plaintext password.
+// CompareHashAndPassword compares a hash with a plaintext password.
 func (h *BcryptPasswordHasher) CompareHashAndPassword(hashedPassword, password []byte) error {
-	return bcrypt.CompareHashAndPassword(hashedPassword, password)
+	return Compare(hashedPassword, password)
+}
+
+// NeedsRehash reports whether hashedPassword is not a bcrypt hash or uses a lower cost
+func (h *BcryptPasswordHasher) NeedsRehash(hashedPassword []byte) bool {
+	if Identify(hashedPassword) != AlgorithmBcrypt {
+		return true
+	}
+
+	cost, err := bcrypt.Cost(hashedPassword)
+	if err != nil {
+		return true
+	}
+
+	return cost < h.cost()
+}
+
+func (h *BcryptPasswordHasher) cost() int {
+	if h.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+
+	return h.Cost
 }
@@ -0,0 +1,63 @@
+package passwordhash
+
+import (
+	"bytes"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// ErrMismatchedHashAndPassword is returned when a password does not match its hash
+var ErrMismatchedHashAndPassword = bcrypt.ErrMismatchedHashAndPassword
+
+// PasswordHasher is a password hashing interface. Implementations verify hashes made
+// by any supported algorithm, so that the algorithm can be changed without locking
+// anyone out, and report through NeedsRehash which hashes should be upgraded.
+type PasswordHasher interface {
+	GenerateFromPassword(password []byte) ([]byte, error)
+	CompareHashAndPassword(hashedPassword, password []byte) error
+	NeedsRehash(hashedPassword []byte) bool
+}
+
+// New returns the hasher for algorithm
+func New(algorithm string, bcryptCost int, argon2Params Argon2Params) (PasswordHasher, error) {
+	switch algorithm {
+	case AlgorithmArgon2id:
+		return &Argon2idPasswordHasher{Params: argon2Params}, nil
+	case AlgorithmBcrypt:
+		return &BcryptPasswordHasher{Cost: bcryptCost}, nil
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", algorithm)
+	}
+}
+
+// Identify returns the algorithm a hash was made with, or an empty string
+func Identify(hashedPassword []byte) string {
+	switch {
+	case bytes.HasPrefix(hashedPassword, []byte("$argon2id$")):
+		return AlgorithmArgon2id
+	case bytes.HasPrefix(hashedPassword, []byte("$2a$")),
+		bytes.HasPrefix(hashedPassword, []byte("$2b$")),
+		bytes.HasPrefix(hashedPassword, []byte("$2y$")):
+		return AlgorithmBcrypt
+	default:
+		return ""
+	}
+}
+
+// Compare compares a hash made by any supported algorithm with a plaintext password
+func Compare(hashedPassword, password []byte) error {
+	switch Identify(hashedPassword) {
+	case AlgorithmArgon2id:
+		return compareArgon2id(hashedPassword, password)
+	case AlgorithmBcrypt:
+		return bcrypt.CompareHashAndPassword(hashedPassword, password)
+	default:
+		return ErrInvalidHash
+	}
+}
@@ -0,0 +1,205 @@
+// Package pastecrypt encrypts and decrypts client-side encrypted pastes. The server only
+// ever sees the envelope: the ciphertext and the non-secret parameters needed to decrypt
+// it. The key travels in the URL fragment, which browsers never send to the server.
+//
+// The content key is derived with PBKDF2-SHA256 from the random key, followed by the
+// optional paste password, and the content is sealed with AES-256-GCM. Both are
+// available in the browser's Web Crypto API.
+package pastecrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	Version      = 1
+	CipherAESGCM = "aes-256-gcm"
+	KDFPBKDF2    = "pbkdf2-sha256"
+
+	// KeySize is the length of the random key kept in the URL fragment
+	KeySize = 32
+	// DefaultIterations is the PBKDF2 iteration count used by Encrypt
+	DefaultIterations = 600000
+	MinIterations     = 10000
+	MaxIterations     = 10000000
+	MinSaltSize       = 16
+	MaxSaltSize       = 64
+	IVSize            = 12
+)
+
+var (
+	ErrInvalidEnvelope = errors.New("pastecrypt: invalid envelope")
+	ErrDecrypt         = errors.New("pastecrypt: wrong key or password")
+	ErrInvalidKey      = fmt.Errorf("pastecrypt: keys must be %d bytes", KeySize)
+)
+
+// Envelope is a client-side encrypted paste body. Byte fields are base64 encoded in JSON.
+type Envelope struct {
+	Version    int    `json:"v"`
+	Cipher     string `json:"cipher"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	IV         []byte `json:"iv"`
+	// Ciphertext ends with the GCM tag
+	Ciphertext []byte `json:"ct"`
+}
+
+// Parse decodes and validates an envelope. Unknown fields are rejected.
+func Parse(data []byte) (*Envelope, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var envelope Envelope
+
+	err := decoder.Decode(&envelope)
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, ErrInvalidEnvelope
+	}
+
+	err = envelope.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &envelope, nil
+}
+
+// Validate checks that the envelope uses a supported format and sane parameters
+func (e *Envelope) Validate() error {
+	if e.Version != Version || e.Cipher != CipherAESGCM || e.KDF != KDFPBKDF2 {
+		return ErrInvalidEnvelope
+	}
+
+	if e.Iterations < MinIterations || e.Iterations > MaxIterations {
+		return ErrInvalidEnvelope
+	}
+
+	if len(e.Salt) < MinSaltSize || len(e.Salt) > MaxSaltSize || len(e.IV) != IVSize {
+		return ErrInvalidEnvelope
+	}
+
+	// GCM tag
+	if len(e.Ciphertext) < 16 {
+		return ErrInvalidEnvelope
+	}
+
+	return nil
+}
+
+// Marshal encodes the envelope as JSON
+func (e *Envelope) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// NewKey returns a random key for a new paste
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// EncodeKey encodes a key for the URL fragment
+func EncodeKey(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// DecodeKey decodes a key taken from the URL fragment
+func DecodeKey(s string) ([]byte, error) {
+	key, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	return key, nil
+}
+
+// Encrypt seals plaintext with key and an optional password
+func Encrypt(plaintext []byte, key []byte, password []byte) (*Envelope, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	envelope := &Envelope{
+		Version:    Version,
+		Cipher:     CipherAESGCM,
+		KDF:        KDFPBKDF2,
+		Iterations: DefaultIterations,
+		Salt:       make([]byte, MinSaltSize),
+		IV:         make([]byte, IVSize),
+	}
+
+	_, err := rand.Read(envelope.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = rand.Read(envelope.IV)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := envelope.aead(key, password)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope.Ciphertext = aead.Seal(nil, envelope.IV, plaintext, nil)
+
+	return envelope, nil
+}
+
+// Decrypt opens the envelope with key and the password it was encrypted with
+func Decrypt(envelope *Envelope, key []byte, password []byte) ([]byte, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	err := envelope.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := envelope.aead(key, password)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, envelope.IV, envelope.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
+
+func (e *Envelope) aead(key []byte, password []byte) (cipher.AEAD, error) {
+	secret := append(append([]byte{}, key...), password...)
+
+	block, err := aes.NewCipher(pbkdf2.Key(secret, e.Salt, e.Iterations, 32, sha256.New))
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,93 @@
+// Package ratelimit implements the token bucket algorithm. A bucket holds up to Burst
+// tokens and is refilled at Burst tokens per Period; every request takes one token.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// ParseLimit parses limits written as <burst>/<period>, for example 100/1h
+func ParseLimit(s string) (Limit, error) {
+	burst, period, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("ratelimit: invalid limit %q, want <burst>/<period>", s)
+	}
+
+	n, err := strconv.Atoi(burst)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("ratelimit: invalid burst in %q", s)
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("ratelimit: invalid period in %q", s)
+	}
+
+	return Limit{Burst: n, Period: d}, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Burst, l.Period)
+}
+
+// Bucket is the state of one bucket. A zero Bucket is full.
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Result describes the outcome of taking a token
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until a token is available, zero when Allowed
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Take refills bucket up to now and takes a token from it if one is available
+func (l Limit) Take(bucket Bucket, now time.Time) (Bucket, Result) {
+	tokens := float64(l.Burst)
+
+	if !bucket.UpdatedAt.IsZero() {
+		elapsed := now.Sub(bucket.UpdatedAt)
+		if elapsed < 0 {
+			elapsed = 0
+		}
+
+		tokens = math.Min(tokens, bucket.Tokens+l.tokensFor(elapsed))
+	}
+
+	result := Result{Limit: l.Burst}
+
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.durationFor(1 - tokens)
+	}
+
+	result.Remaining = int(tokens)
+	result.Reset = l.durationFor(float64(l.Burst) - tokens)
+
+	return Bucket{Tokens: tokens, UpdatedAt: now}, result
+}
+
+func (l Limit) tokensFor(d time.Duration) float64 {
+	return float64(d) / float64(l.Period) * float64(l.Burst)
+}
+
+func (l Limit) durationFor(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / float64(l.Burst) * float64(l.Period)))
+}
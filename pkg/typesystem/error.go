@@ -3,6 +3,7 @@ package typesystem
 import (
 	"fmt"
 	"net/http"
+	"time"
 )
 
 var (
@@ -42,3 +43,32 @@ func NewHttpError(description, metadata string, statusCode int) Http {
 		StatusCode:  statusCode,
 	}
 }
+
+// TooManyRequests is returned while a caller is locked out or rate limited. The error handler sends
+// RetryAfter in the Retry-After header.
+type TooManyRequests struct {
+	Http
+	RetryAfter time.Duration
+}
+
+// NewTooManyRequestsError returns a 429 error asking to retry after retryAfter
+func NewTooManyRequestsError(message string, retryAfter time.Duration) TooManyRequests {
+	return TooManyRequests{
+		Http: NewHttpError(
+			"Too many requests",
+			message,
+			http.StatusTooManyRequests,
+		),
+		RetryAfter: retryAfter,
+	}
+}
+
+// RetryAfterSeconds returns RetryAfter rounded up to whole seconds, as sent in Retry-After
+func (e TooManyRequests) RetryAfterSeconds() int {
+	seconds := int((e.RetryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+
+	return seconds
+}